@@ -0,0 +1,341 @@
+// Package a11y audits a rendered giom.Element tree for accessibility
+// problems. The tree is structured, so the checks read tags, attributes and
+// text directly, with no browser:
+//
+//	img-alt         an img has no alt attribute (alt="" marks it decorative)
+//	input-label     an input, select or textarea has no label
+//	heading-order   a heading skips a level after the previous one (h2 → h4)
+//	empty-link      a link has no text, image alt or aria-label
+//	empty-button    a button has no text, image alt or aria-label
+//	duplicate-id    two elements have the same id
+//	html-lang       the html element has no lang attribute
+//	aria            an unknown aria-* attribute or role, an invalid value, or
+//	                an id reference to no element
+//
+// Audit returns a Report whose issues tests can assert on:
+//
+//	require.NoError(t, a11y.Audit(root).Err())
+package a11y
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gad-lang/gad"
+
+	"github.com/gad-lang/gad/giom"
+)
+
+// Rule IDs.
+const (
+	RuleImgAlt       = "img-alt"
+	RuleInputLabel   = "input-label"
+	RuleHeadingOrder = "heading-order"
+	RuleEmptyLink    = "empty-link"
+	RuleEmptyButton  = "empty-button"
+	RuleDuplicateID  = "duplicate-id"
+	RuleHTMLLang     = "html-lang"
+	RuleARIA         = "aria"
+)
+
+// Issue is a problem found on an element.
+type Issue struct {
+	Rule string
+	// Path addresses the element by child indexes from the root, as the Path
+	// of a giom.Patch: anonymous fragments are transparent.
+	Path []int
+	// Element describes the element as a selector, e.g. `img#logo.brand`.
+	Element string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %v: %s (%s)", i.Element, i.Path, i.Message, i.Rule)
+}
+
+// Report holds the issues of an audit, in document order.
+type Report struct {
+	Issues []Issue
+}
+
+// Rule returns the issues of rule.
+func (r *Report) Rule(rule string) (issues []Issue) {
+	for _, i := range r.Issues {
+		if i.Rule == rule {
+			issues = append(issues, i)
+		}
+	}
+	return
+}
+
+// Err returns nil when the report has no issues, else an error listing them.
+func (r *Report) Err() error {
+	if len(r.Issues) == 0 {
+		return nil
+	}
+	return errors.New(r.String())
+}
+
+// String returns one line per issue.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, i := range r.Issues {
+		b.WriteString(i.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Audit checks the tree of root as it is: call giom.ResolveStacks first on a
+// tree built by running a template directly.
+func Audit(root giom.Element) *Report {
+	a := &auditor{report: &Report{}, ids: map[string]bool{}, labelFor: map[string]bool{}}
+	a.collect(root)
+	a.element(nil, root, false)
+	return a.report
+}
+
+// auditor holds the state of one Audit.
+type auditor struct {
+	report *Report
+	// ids holds the ids of the tree; labelFor the `for` of its labels.
+	ids, labelFor map[string]bool
+	// seen holds the ids already met by the walk.
+	seen map[string]bool
+	// heading is the level of the previous heading, 0 before the first.
+	heading int
+}
+
+func (a *auditor) add(rule string, path []int, t *giom.Tag, format string, args ...any) {
+	a.report.Issues = append(a.report.Issues, Issue{
+		Rule:    rule,
+		Path:    append([]int(nil), path...),
+		Element: selector(t),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// collect gathers the ids and label targets of the tree.
+func (a *auditor) collect(el giom.Element) {
+	t, ok := el.(*giom.Tag)
+	if !ok {
+		return
+	}
+	if id, ok := attr(t, "id"); ok && id != "" {
+		a.ids[id] = true
+	}
+	if strings.EqualFold(t.Name, "label") {
+		if f, ok := attr(t, "for"); ok {
+			a.labelFor[f] = true
+		}
+	}
+	for _, c := range t.Children {
+		a.collect(c)
+	}
+}
+
+// element checks el at path; inLabel reports that a label encloses it.
+func (a *auditor) element(path []int, el giom.Element, inLabel bool) {
+	t, ok := el.(*giom.Tag)
+	if !ok {
+		return
+	}
+	if t.Name != "" {
+		a.tag(path, t, inLabel)
+		inLabel = inLabel || strings.EqualFold(t.Name, "label")
+	}
+	for i, c := range children(t) {
+		a.element(append(path[:len(path):len(path)], i), c, inLabel)
+	}
+}
+
+var rgxHeading = regexp.MustCompile(`^h([1-6])$`)
+
+func (a *auditor) tag(path []int, t *giom.Tag, inLabel bool) {
+	name := strings.ToLower(t.Name)
+
+	if id, ok := attr(t, "id"); ok && id != "" {
+		if a.seen == nil {
+			a.seen = map[string]bool{}
+		}
+		if a.seen[id] {
+			a.add(RuleDuplicateID, path, t, "duplicate id %q", id)
+		}
+		a.seen[id] = true
+	}
+	a.aria(path, t)
+
+	switch {
+	case name == "html":
+		if lang, _ := attr(t, "lang"); strings.TrimSpace(lang) == "" {
+			a.add(RuleHTMLLang, path, t, "html has no lang attribute")
+		}
+	case name == "img":
+		if _, ok := attr(t, "alt"); !ok && !presentational(t) {
+			a.add(RuleImgAlt, path, t, "img has no alt attribute")
+		}
+	case name == "input" || name == "select" || name == "textarea":
+		a.control(path, t, name, inLabel)
+	case name == "a":
+		if _, ok := attr(t, "href"); ok && !named(t) {
+			a.add(RuleEmptyLink, path, t, "link has no text")
+		}
+	case name == "button":
+		if !named(t) {
+			a.add(RuleEmptyButton, path, t, "button has no text")
+		}
+	case rgxHeading.MatchString(name):
+		level, _ := strconv.Atoi(name[1:])
+		if a.heading > 0 && level > a.heading+1 {
+			a.add(RuleHeadingOrder, path, t, "h%d follows h%d, skipping a level", level, a.heading)
+		}
+		a.heading = level
+	}
+}
+
+// unlabelledInputs are the input types that need no label.
+var unlabelledInputs = map[string]bool{"hidden": true, "submit": true, "reset": true, "image": true}
+
+// control checks the form control t, named name.
+func (a *auditor) control(path []int, t *giom.Tag, name string, inLabel bool) {
+	typ := ""
+	if name == "input" {
+		typ, _ = attr(t, "type")
+		typ = strings.ToLower(typ)
+	}
+	switch {
+	case unlabelledInputs[typ]:
+		if typ == "image" {
+			if alt, _ := attr(t, "alt"); strings.TrimSpace(alt) == "" {
+				a.add(RuleImgAlt, path, t, "image input has no alt attribute")
+			}
+		}
+		return
+	case typ == "button":
+		if value, _ := attr(t, "value"); strings.TrimSpace(value) == "" && !ariaNamed(t) {
+			a.add(RuleEmptyButton, path, t, "button has no text")
+		}
+		return
+	}
+	if inLabel || ariaNamed(t) {
+		return
+	}
+	if title, _ := attr(t, "title"); strings.TrimSpace(title) != "" {
+		return
+	}
+	if id, ok := attr(t, "id"); ok && a.labelFor[id] {
+		return
+	}
+	a.add(RuleInputLabel, path, t, "%s has no label", name)
+}
+
+// presentational reports that t is hidden from assistive technology or has
+// no semantics.
+func presentational(t *giom.Tag) bool {
+	if hidden, _ := attr(t, "aria-hidden"); hidden == "true" {
+		return true
+	}
+	role, _ := attr(t, "role")
+	return role == "presentation" || role == "none"
+}
+
+// ariaNamed reports that t is named by aria-label or aria-labelledby.
+func ariaNamed(t *giom.Tag) bool {
+	if label, _ := attr(t, "aria-label"); strings.TrimSpace(label) != "" {
+		return true
+	}
+	_, ok := attr(t, "aria-labelledby")
+	return ok
+}
+
+// named reports that the link or button t has an accessible name: its text,
+// the alt of an image in it, aria-label, aria-labelledby or title.
+func named(t *giom.Tag) bool {
+	if ariaNamed(t) {
+		return true
+	}
+	if title, _ := attr(t, "title"); strings.TrimSpace(title) != "" {
+		return true
+	}
+	return strings.TrimSpace(text(t)) != ""
+}
+
+var rgxRawTag = regexp.MustCompile(`<[^>]*>`)
+
+// text returns the text of el, with the alt of its images.
+func text(el giom.Element) string {
+	var b strings.Builder
+	switch e := el.(type) {
+	case giom.Text:
+		for _, v := range e {
+			switch s := v.(type) {
+			case gad.RawStr:
+				b.WriteString(rgxRawTag.ReplaceAllString(string(s), " "))
+			case gad.Str:
+				b.WriteString(string(s))
+			default:
+				b.WriteString(v.ToString())
+			}
+		}
+	case *giom.Tag:
+		if strings.EqualFold(e.Name, "img") {
+			alt, _ := attr(e, "alt")
+			return alt
+		}
+		for _, c := range e.Children {
+			b.WriteString(text(c))
+		}
+	}
+	return b.String()
+}
+
+// attr returns the value of the attribute name of t, and whether it is set.
+// A true flag reads as its name; false and nil values are not set.
+func attr(t *giom.Tag, name string) (string, bool) {
+	v, ok := t.Attrs[name]
+	if !ok || v == nil {
+		return "", false
+	}
+	switch s := v.(type) {
+	case gad.Str:
+		return string(s), true
+	case gad.RawStr:
+		return string(s), true
+	case gad.Flag:
+		if s {
+			return name, true
+		}
+		return "", false
+	}
+	if v.IsFalsy() {
+		return "", false
+	}
+	return v.ToString(), true
+}
+
+// children returns the children of t with anonymous fragments expanded.
+func children(t *giom.Tag) (out []giom.Element) {
+	for _, c := range t.Children {
+		if ct, ok := c.(*giom.Tag); ok && ct.Name == "" {
+			out = append(out, children(ct)...)
+		} else {
+			out = append(out, c)
+		}
+	}
+	return
+}
+
+// selector describes t as its name, id and classes.
+func selector(t *giom.Tag) string {
+	s := t.Name
+	if id, ok := attr(t, "id"); ok && id != "" {
+		s += "#" + id
+	}
+	for _, c := range t.ClassList {
+		s += "." + strings.Join(strings.Fields(c), ".")
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package a11y
+
+import (
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gad-lang/gad/giom"
+)
+
+// el returns a tag; attrs are name/value pairs, a "" value is a true flag.
+func el(name string, attrs []string, children ...giom.Element) *giom.Tag {
+	var kva gad.KeyValueArray
+	for i := 0; i < len(attrs); i += 2 {
+		var v gad.Object = gad.Str(attrs[i+1])
+		if attrs[i+1] == "" {
+			v = gad.Flag(true)
+		}
+		kva = append(kva, &gad.KeyValue{K: gad.Str(attrs[i]), V: v})
+	}
+	return giom.NewTag(nil, name, children, kva)
+}
+
+func txt(s string) giom.Text { return giom.Text{gad.Str(s)} }
+
+func a(attrs ...string) []string { return attrs }
+
+// issues returns the rule and element of each issue.
+func issues(r *Report) (out [][2]string) {
+	for _, i := range r.Issues {
+		out = append(out, [2]string{i.Rule, i.Element})
+	}
+	return
+}
+
+func TestAudit(t *testing.T) {
+	root := el("html", nil,
+		el("body", nil,
+			el("h1", nil, txt("Title")),
+			el("img", a("src", "logo.png", "id", "logo")),
+			el("img", a("src", "spacer.png", "alt", "")),
+			el("img", a("src", "deco.png", "role", "presentation")),
+			el("h3", nil, txt("Skipped")),
+			el("form", nil,
+				el("input", a("type", "text", "id", "name")),
+				el("label", a("for", "email"), txt("Email")),
+				el("input", a("type", "email", "id", "email")),
+				el("label", nil, txt("Age "), el("input", a("type", "number"))),
+				el("input", a("type", "hidden", "name", "token")),
+				el("textarea", a("aria-label", "Comment")),
+				el("select", a("class", "pick")),
+				el("button", nil),
+				el("button", nil, el("img", a("src", "go.png", "alt", "Go"))),
+				el("input", a("type", "submit")),
+			),
+			el("a", a("href", "/home")),
+			el("a", a("href", "/home", "aria-label", "Home")),
+			el("a", a("name", "anchor")),
+			el("p", a("id", "logo")),
+			el("div", a("role", "navigation banner", "aria-hidden", "yes", "aria-foo", "1",
+				"aria-labelledby", "logo missing", "aria-level", "2")),
+			el("div", a("role", "widget")),
+		),
+	)
+
+	r := Audit(root)
+	require.Equal(t, [][2]string{
+		{"html-lang", "html"},
+		{"img-alt", "img#logo"},
+		{"heading-order", "h3"},
+		{"input-label", "input#name"},
+		{"input-label", "select.pick"},
+		{"empty-button", "button"},
+		{"empty-link", "a"},
+		{"duplicate-id", "p#logo"},
+		{"aria", "div"},
+		{"aria", "div"},
+		{"aria", "div"},
+		{"aria", "div"},
+	}, issues(r))
+
+	require.Equal(t, []int{0, 4}, r.Issues[2].Path)
+	require.Equal(t, "h3 follows h1, skipping a level", r.Issues[2].Message)
+	require.Equal(t, []int{0, 5, 0}, r.Issues[3].Path)
+
+	aria := r.Rule(RuleARIA)
+	require.Len(t, aria, 4)
+	require.Equal(t, []string{
+		`unknown ARIA attribute "aria-foo"`,
+		`aria-hidden must be true, false or undefined, got "yes"`,
+		`aria-labelledby refers to no element with id "missing"`,
+		`unknown role "widget"`,
+	}, []string{aria[0].Message, aria[1].Message, aria[2].Message, aria[3].Message})
+	require.Error(t, r.Err())
+	require.Contains(t, r.String(), "img#logo [0 1]: img has no alt attribute (img-alt)\n")
+}
+
+func TestAuditClean(t *testing.T) {
+	// Anonymous fragments, as components build, are transparent.
+	root := giom.NewTag(nil, "", []giom.Element{
+		el("html", a("lang", "en"),
+			el("body", nil,
+				giom.NewTag(nil, "", []giom.Element{el("h1", nil, txt("Title"))}, nil),
+				el("h2", nil, txt("Section")),
+				el("a", a("href", "/"), el("img", a("src", "home.png", "alt", "Home"))),
+				el("button", a("aria-expanded", "false", "aria-controls", "menu"), txt("Menu")),
+				el("ul", a("id", "menu", "role", "menu")),
+			),
+		),
+	}, nil)
+	r := Audit(root)
+	require.NoError(t, r.Err())
+	require.Empty(t, r.Rule(RuleImgAlt))
+}
@@ -0,0 +1,196 @@
+package a11y
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gad-lang/gad/giom"
+)
+
+// ariaValue is the kind of value of an aria-* attribute (WAI-ARIA 1.2).
+type ariaValue int
+
+const (
+	ariaString ariaValue = iota
+	ariaBool
+	ariaTristate
+	ariaBoolUndefined
+	ariaInteger
+	ariaNumber
+	ariaToken
+	ariaTokens
+	ariaIDRef
+	ariaIDRefs
+)
+
+// ariaAttr describes an aria-* attribute: its kind of value and, for tokens,
+// the values it takes.
+type ariaAttr struct {
+	value  ariaValue
+	tokens []string
+}
+
+var ariaAttrs = map[string]ariaAttr{
+	"aria-activedescendant":       {value: ariaIDRef},
+	"aria-atomic":                 {value: ariaBool},
+	"aria-autocomplete":           {value: ariaToken, tokens: []string{"inline", "list", "both", "none"}},
+	"aria-braillelabel":           {value: ariaString},
+	"aria-brailleroledescription": {value: ariaString},
+	"aria-busy":                   {value: ariaBool},
+	"aria-checked":                {value: ariaTristate},
+	"aria-colcount":               {value: ariaInteger},
+	"aria-colindex":               {value: ariaInteger},
+	"aria-colindextext":           {value: ariaString},
+	"aria-colspan":                {value: ariaInteger},
+	"aria-controls":               {value: ariaIDRefs},
+	"aria-current":                {value: ariaToken, tokens: []string{"page", "step", "location", "date", "time", "true", "false"}},
+	"aria-describedby":            {value: ariaIDRefs},
+	"aria-description":            {value: ariaString},
+	"aria-details":                {value: ariaIDRef},
+	"aria-disabled":               {value: ariaBool},
+	"aria-dropeffect":             {value: ariaTokens, tokens: []string{"copy", "execute", "link", "move", "none", "popup"}},
+	"aria-errormessage":           {value: ariaIDRef},
+	"aria-expanded":               {value: ariaBoolUndefined},
+	"aria-flowto":                 {value: ariaIDRefs},
+	"aria-grabbed":                {value: ariaBoolUndefined},
+	"aria-haspopup":               {value: ariaToken, tokens: []string{"false", "true", "menu", "listbox", "tree", "grid", "dialog"}},
+	"aria-hidden":                 {value: ariaBoolUndefined},
+	"aria-invalid":                {value: ariaToken, tokens: []string{"grammar", "false", "spelling", "true"}},
+	"aria-keyshortcuts":           {value: ariaString},
+	"aria-label":                  {value: ariaString},
+	"aria-labelledby":             {value: ariaIDRefs},
+	"aria-level":                  {value: ariaInteger},
+	"aria-live":                   {value: ariaToken, tokens: []string{"assertive", "off", "polite"}},
+	"aria-modal":                  {value: ariaBool},
+	"aria-multiline":              {value: ariaBool},
+	"aria-multiselectable":        {value: ariaBool},
+	"aria-orientation":            {value: ariaToken, tokens: []string{"horizontal", "undefined", "vertical"}},
+	"aria-owns":                   {value: ariaIDRefs},
+	"aria-placeholder":            {value: ariaString},
+	"aria-posinset":               {value: ariaInteger},
+	"aria-pressed":                {value: ariaTristate},
+	"aria-readonly":               {value: ariaBool},
+	"aria-relevant":               {value: ariaTokens, tokens: []string{"additions", "all", "removals", "text"}},
+	"aria-required":               {value: ariaBool},
+	"aria-roledescription":        {value: ariaString},
+	"aria-rowcount":               {value: ariaInteger},
+	"aria-rowindex":               {value: ariaInteger},
+	"aria-rowindextext":           {value: ariaString},
+	"aria-rowspan":                {value: ariaInteger},
+	"aria-selected":               {value: ariaBoolUndefined},
+	"aria-setsize":                {value: ariaInteger},
+	"aria-sort":                   {value: ariaToken, tokens: []string{"ascending", "descending", "none", "other"}},
+	"aria-valuemax":               {value: ariaNumber},
+	"aria-valuemin":               {value: ariaNumber},
+	"aria-valuenow":               {value: ariaNumber},
+	"aria-valuetext":              {value: ariaString},
+}
+
+// roles are the WAI-ARIA 1.2 roles that are not abstract.
+var roles = map[string]bool{}
+
+func init() {
+	for _, r := range strings.Fields(`alert alertdialog application article
+		banner blockquote button caption cell checkbox code columnheader combobox
+		complementary contentinfo definition deletion dialog directory document
+		emphasis feed figure form generic grid gridcell group heading img
+		insertion link list listbox listitem log main marquee math menu menubar
+		menuitem menuitemcheckbox menuitemradio meter navigation none note option
+		paragraph presentation progressbar radio radiogroup region row rowgroup
+		rowheader scrollbar search searchbox separator slider spinbutton status
+		strong subscript superscript switch tab table tablist tabpanel term
+		textbox time timer toolbar tooltip tree treegrid treeitem`) {
+		roles[r] = true
+	}
+}
+
+// aria checks the role and aria-* attributes of t.
+func (a *auditor) aria(path []int, t *giom.Tag) {
+	if role, ok := attr(t, "role"); ok {
+		// A role may list fallbacks; each must be a role.
+		for _, r := range strings.Fields(role) {
+			if !roles[strings.ToLower(r)] {
+				a.add(RuleARIA, path, t, "unknown role %q", r)
+			}
+		}
+	}
+	var names []string
+	for name := range t.Attrs {
+		if strings.HasPrefix(name, "aria-") {
+			names = append(names, name)
+		}
+	}
+	// Attrs is a map: report in name order.
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := attr(t, name)
+		if !ok {
+			continue
+		}
+		spec, known := ariaAttrs[name]
+		if !known {
+			a.add(RuleARIA, path, t, "unknown ARIA attribute %q", name)
+			continue
+		}
+		if msg := a.ariaValue(spec, value); msg != "" {
+			a.add(RuleARIA, path, t, "%s %s", name, msg)
+		}
+	}
+}
+
+// ariaValue returns why value is not valid for an attribute spec describes,
+// or "" when it is.
+func (a *auditor) ariaValue(spec ariaAttr, value string) string {
+	value = strings.TrimSpace(value)
+	got := ", got " + strconv.Quote(value)
+	oneOf := func(tokens ...string) string {
+		for _, t := range tokens {
+			if value == t {
+				return ""
+			}
+		}
+		return "must be " + strings.Join(tokens[:len(tokens)-1], ", ") + " or " + tokens[len(tokens)-1] + got
+	}
+	switch spec.value {
+	case ariaBool:
+		return oneOf("true", "false")
+	case ariaTristate:
+		return oneOf("true", "false", "mixed", "undefined")
+	case ariaBoolUndefined:
+		return oneOf("true", "false", "undefined")
+	case ariaToken:
+		return oneOf(spec.tokens...)
+	case ariaTokens:
+		for _, v := range strings.Fields(value) {
+			for _, t := range spec.tokens {
+				if v == t {
+					v = ""
+					break
+				}
+			}
+			if v != "" {
+				return "must list " + strings.Join(spec.tokens, ", ") + got
+			}
+		}
+	case ariaInteger:
+		if _, err := strconv.Atoi(value); err != nil {
+			return "must be an integer" + got
+		}
+	case ariaNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number" + got
+		}
+	case ariaIDRef, ariaIDRefs:
+		ids := strings.Fields(value)
+		if len(ids) == 0 || spec.value == ariaIDRef && len(ids) > 1 {
+			return "must refer to an element id" + got
+		}
+		for _, id := range ids {
+			if !a.ids[id] {
+				return "refers to no element with id " + strconv.Quote(id)
+			}
+		}
+	}
+	return ""
+}
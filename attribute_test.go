@@ -87,3 +87,79 @@ func TestAttributeExpressionPosition(t *testing.T) {
 		t.Fatalf("attribute nil-call resolved to %d:%d, want 5:18\ntrace:\n%+v", line, col, re.StackTrace())
 	}
 }
+
+// TestClassToggles verifies in-place class toggles and interpolated class
+// names.
+func TestClassToggles(t *testing.T) {
+	src := "@global current, variant\n" +
+		"@main\n" +
+		"    a.nav-link.active?(\"/\" == current)[href=\"/\"] Home\n" +
+		"    a.nav-link.active?(\"/about\" == current)[href=\"/about\"] About\n" +
+		"    div.card.{variant}.wide?(variant == \"big\")\n"
+	got := renderGiom(t, src, gad.Dict{"current": gad.Str("/"), "variant": gad.Str("big")})
+	want := `<a href="/" class="nav-link active">Home</a>` +
+		`<a href="/about" class="nav-link">About</a>` +
+		`<div class="card big wide"></div>`
+	if got != want {
+		t.Fatalf("render mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+// TestAttributeSpread verifies `&attributes(expr)` and `[...expr]` merge caller
+// attributes into a tag, appending classes and letting later attributes win.
+func TestAttributeSpread(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "component forwards named args",
+			src: "@comp button(label; **attrs)\n" +
+				"    button.btn&attributes(attrs)[type=\"button\"] {= label}\n" +
+				"@main\n" +
+				"    +button(\"Save\"; data-id=1)\n" +
+				"    +button(\"Go\"; class=\"primary\")\n",
+			want: `<button data-id="1" type="button" class="btn">Save</button>` +
+				`<button type="button" class="btn primary">Go</button>`,
+		},
+		{
+			name: "group spread then override",
+			src: "@main\n" +
+				"    ~ extra := (; title=\"a\", class=\"x\")\n" +
+				"    a.link[...extra, title=\"b\"] go\n",
+			want: `<a title="b" class="link x">go</a>`,
+		},
+		{
+			name: "attribute named merge",
+			src: "@main\n" +
+				"    ~ extra := (; title=\"a\")\n" +
+				"    a[merge=\"m\"][...extra] go\n",
+			want: `<a merge="m" title="a">go</a>`,
+		},
+		{
+			name: "dict spread in key order",
+			src: "@main\n" +
+				"    ~ extra := {title: \"t\", \"data-b\": 2, \"data-a\": 1, class: \"x\"}\n" +
+				"    a.link&attributes(extra) go\n" +
+				"    b[...extra] go\n",
+			want: `<a data-a="1" data-b="2" title="t" class="link x">go</a>` +
+				`<b data-a="1" data-b="2" title="t" class="x">go</b>`,
+		},
+		{
+			name: "conditional spread",
+			src: "@main\n" +
+				"    ~ extra := (; title=\"a\")\n" +
+				"    i[...extra] ? false\n",
+			want: `<i></i>`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := renderGiom(t, tc.src, gad.Dict{})
+			if got != tc.want {
+				t.Fatalf("render mismatch\n got: %s\nwant: %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package giom
 
 import (
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gad-lang/gad"
 )
@@ -301,15 +304,149 @@ var (
 			return call.VM.Builtins.Call(gad.BuiltinWrite, call)
 		},
 	}
+
+	// BuiltinToJSON implements giom.toJSON(value): the JSON form of a render tree
+	// element (or any other value) as a string.
+	BuiltinToJSON = &gad.Function{
+		FuncName: "giom.toJSON",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (_ gad.Object, err error) {
+			if err = call.Args.CheckLen(1); err != nil {
+				return
+			}
+			var b []byte
+			if b, err = json.Marshal(jsonValue(call.Args.GetOnly(0))); err != nil {
+				return
+			}
+			return gad.Str(b), nil
+		},
+	}
+
+	// BuiltinJS implements giom.js(value), the escaper of `{= expr}` inside a
+	// :script block: value JSON-encoded (so a string is quoted) with <, > and &
+	// written as \u escapes, which keeps `</script>` from closing the tag. A
+	// raw string is trusted and written as-is.
+	BuiltinJS = &gad.Function{
+		FuncName: "giom.js",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (_ gad.Object, err error) {
+			if err = call.Args.CheckLen(1); err != nil {
+				return
+			}
+			value := call.Args.GetOnly(0)
+			if rs, ok := value.(gad.RawStr); ok {
+				return rs, nil
+			}
+			var b []byte
+			if b, err = json.Marshal(jsonValue(value)); err != nil {
+				return
+			}
+			return gad.RawStr(b), nil
+		},
+	}
+
+	// BuiltinCSS implements giom.css(value), the escaper of `{= expr}` inside a
+	// :style block: every character of value's string form that could end a
+	// declaration, string or the style tag is written as a CSS hex escape
+	// (`\3c `). Letters, digits and ` _.,#%+-` are kept, so colors, lengths
+	// and names read as usual. A raw string is trusted and written as-is.
+	BuiltinCSS = &gad.Function{
+		FuncName: "giom.css",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (_ gad.Object, err error) {
+			if err = call.Args.CheckLen(1); err != nil {
+				return
+			}
+			var s string
+			switch t := call.Args.GetOnly(0).(type) {
+			case gad.RawStr:
+				return t, nil
+			case gad.Str:
+				s = string(t)
+			default:
+				var v gad.Str
+				if v, err = gad.ToStr(call.VM, t); err != nil {
+					return
+				}
+				s = string(v)
+			}
+			return gad.RawStr(cssEscape(s)), nil
+		},
+	}
+
+	// BuiltinMerge implements giom.merge(tag, *attrs; **attrs), merging
+	// attribute collections into tag as tag.merge does. Attribute spreads
+	// lower to it, so that an attribute named merge cannot shadow the method.
+	BuiltinMerge = &gad.Function{
+		FuncName: "giom.merge",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (gad.Object, error) {
+			if call.Args.Length() < 1 {
+				return nil, call.Args.CheckLen(1)
+			}
+			t, ok := call.Args.GetOnly(0).(*Tag)
+			if !ok {
+				return nil, fmt.Errorf("giom.merge: invalid tag %s", call.Args.GetOnly(0).ToString())
+			}
+			t.mergeArgs(call, 1)
+			return t, nil
+		},
+	}
+
+	// BuiltinToggle implements giom.toggle(cond, value): the keyed value
+	// cond=value, which tags and giom.attrs drop when cond is falsy. Conditional
+	// attributes (`[name=value ? cond]`, `.class?(cond)`) lower to it.
+	BuiltinToggle = &gad.Function{
+		FuncName: "giom.toggle",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (_ gad.Object, err error) {
+			if err = call.Args.CheckLen(2); err != nil {
+				return
+			}
+			return &gad.KeyValue{
+				K: gad.Bool(!call.Args.GetOnly(0).IsFalsy()),
+				V: call.Args.GetOnly(1),
+			}, nil
+		},
+	}
 )
 
 // AppendBuiltins registers the giom module as a non-loadable builtin namespace,
 // making giom.escape, giom.attr, giom.attrs, and giom.write available globally.
 func AppendBuiltins(b *gad.Builtins) *gad.Builtins {
+	return appendBuiltins(b, nil, nil)
+}
+
+// appendBuiltins is AppendBuiltins with giom.cover counting in cover and
+// giom.profEnter and giom.profExit recording in profiler, when not nil.
+func appendBuiltins(b *gad.Builtins, cover *Cover, profiler *Profiler) *gad.Builtins {
 	mod := newModule()
+	if cover != nil {
+		mod["cover"] = cover.builtin()
+	}
+	if profiler != nil {
+		mod["profEnter"] = profiler.builtin("profEnter", (*Profiler).enter)
+		mod["profExit"] = profiler.builtin("profExit", (*Profiler).exit)
+	}
 	b.Set(ModuleSpec.Name, mod)
 	for k, v := range mod {
 		b.Set(ModuleSpec.Name+"."+k, v)
 	}
 	return b
 }
+
+// cssEscape escapes s for a CSS value or string (see BuiltinCSS).
+func cssEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= utf8.RuneSelf,
+			r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			strings.ContainsRune(" _.,#%+-", r):
+			b.WriteRune(r)
+		default:
+			b.WriteString("\\" + strconv.FormatInt(int64(r), 16) + " ")
+		}
+	}
+	return b.String()
+}
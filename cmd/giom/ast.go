@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gad-lang/gad/parser/source"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+)
+
+var (
+	nodePkg = reflect.TypeOf(giomnode.File{}).PkgPath()
+	posType = reflect.TypeOf(source.NoPos)
+)
+
+// dumpFile writes file as an indented tree: one line per giom node with its
+// type and source span, then its non-zero fields. Gad expressions and
+// statements embedded in the nodes are written as source.
+//
+//	File page.giom
+//	  Stmts:
+//	    TagStmt 1:1-2:12
+//	      Name: "p"
+//	      Body:
+//	        TextStmt 1:3-1:8
+func dumpFile(w io.Writer, fileSet *source.FileSet, file *giomnode.File) {
+	d := &dumper{w: w, fileSet: fileSet}
+	d.line(0, "File %s", file.InputFile.Name)
+	if file.Whitespace != "" {
+		d.line(1, "Whitespace: %q", file.Whitespace)
+	}
+	d.value(1, "Stmts", reflect.ValueOf(file.Stmts))
+}
+
+type dumper struct {
+	w       io.Writer
+	fileSet *source.FileSet
+}
+
+func (d *dumper) line(depth int, format string, args ...any) {
+	fmt.Fprintf(d.w, "%s%s\n", strings.Repeat("  ", depth), fmt.Sprintf(format, args...))
+}
+
+// value writes v, labeled unless label is empty; nil values are skipped.
+func (d *dumper) value(depth int, label string, v reflect.Value) {
+	if v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	if k := v.Kind(); (k == reflect.Pointer || k == reflect.Slice) && v.IsNil() {
+		return
+	}
+	prefix := ""
+	if label != "" {
+		prefix = label + ": "
+	}
+	elem := v
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	switch {
+	case elem.Kind() == reflect.Struct && elem.Type().PkgPath() == nodePkg:
+		d.line(depth, "%s%s%s", prefix, elem.Type().Name(), d.span(v))
+		d.fields(depth+1, elem)
+	case v.Kind() == reflect.Slice:
+		if v.Len() == 0 {
+			return
+		}
+		d.line(depth, "%s:", label)
+		for i := 0; i < v.Len(); i++ {
+			d.value(depth+1, "", v.Index(i))
+		}
+	case v.Type() == posType:
+		d.line(depth, "%s%s", prefix, d.pos(source.Pos(v.Int())))
+	case v.Kind() == reflect.String:
+		d.line(depth, "%s%q", prefix, v.String())
+	default:
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			d.line(depth, "%s%s", prefix, s.String())
+		} else {
+			d.line(depth, "%s%v", prefix, v.Interface())
+		}
+	}
+}
+
+// fields writes the exported, non-zero fields of the node struct v, except
+// its embedded node data and its span.
+func (d *dumper) fields(depth int, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Anonymous || f.Name == "NodePos" || f.Name == "NodeEnd" {
+			continue
+		}
+		if fv := v.Field(i); !fv.IsZero() {
+			d.value(depth, f.Name, fv)
+		}
+	}
+}
+
+// span returns " line:col-line:col" for a node with positions.
+func (d *dumper) span(v reflect.Value) string {
+	n, ok := v.Interface().(interface {
+		Pos() source.Pos
+		End() source.Pos
+	})
+	if !ok || !n.Pos().IsValid() {
+		return ""
+	}
+	return " " + d.pos(n.Pos()) + "-" + d.pos(n.End())
+}
+
+func (d *dumper) pos(pos source.Pos) string {
+	p := d.fileSet.Position(pos)
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gad-lang/gad/giom"
+	"github.com/gad-lang/gad/parser/source"
+
+	"github.com/gad-lang/gad/giom/lint"
+	"github.com/gad-lang/gad/giom/lsp"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+// render implements `giom render file.giom`: the template runs with the
+// top-level keys of the --globals file as globals and its HTML is written to
+// stdout. -coverprofile and -coverhtml write the coverage of the render,
+// -profile and -folded where it spent its time.
+func (c *cli) render(args []string) error {
+	flags := c.flagSet("render", "file.giom")
+	globalsPath := flags.String("globals", "", "JSON or YAML `file` whose top-level keys are the template globals")
+	workDir := flags.String("workdir", "", "base `dir` of imports (default: the template's directory)")
+	markup := flags.String("markup", "", "output `mode`: html, html5, xhtml or xml (default: detected)")
+	coverProfile := flags.String("coverprofile", "", "write the block counts of the templates to `file`")
+	coverHTML := flags.String("coverhtml", "", "write the sources annotated with block counts to `file`")
+	profile := flags.String("profile", "", "write a pprof profile of the render to `file` (allocations are process-wide)")
+	foldedPath := flags.String("folded", "", "write the wall time of the render as folded stacks to `file`")
+	files, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return c.usageError(flags, "expected one template")
+	}
+	globals, err := loadGlobals(*globalsPath)
+	if err != nil {
+		return err
+	}
+	r := giom.NewRender(*workDir)
+	if *markup != "" {
+		m, err := parseMarkup(*markup)
+		if err != nil {
+			return c.usageError(flags, err.Error())
+		}
+		r.Markup = &m
+	}
+	if *coverProfile != "" || *coverHTML != "" {
+		r.Cover = giom.NewCover()
+	}
+	if *profile != "" || *foldedPath != "" {
+		r.Profiler = giom.NewProfiler()
+	}
+	// Buffer the output so that a failed render writes nothing.
+	var out bytes.Buffer
+	if err = r.Render(&out, files[0], globals); err != nil {
+		return err
+	}
+	if _, err = out.WriteTo(c.stdout); err != nil {
+		return err
+	}
+	if err = writeReport(*coverProfile, r.Cover.WriteReport); err != nil {
+		return err
+	}
+	if err = writeReport(*coverHTML, r.Cover.WriteHTML); err != nil {
+		return err
+	}
+	if err = writeReport(*profile, r.Profiler.WriteProfile); err != nil {
+		return err
+	}
+	return writeReport(*foldedPath, func(w io.Writer) error {
+		return r.Profiler.WriteFolded(w, giom.ProfileWall)
+	})
+}
+
+// writeReport writes a coverage or profiling report to path with write; an
+// empty path writes nothing.
+func writeReport(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// transpile implements `giom transpile file.giom`: the Gad source the template
+// compiles to is written to stdout, or to the -o file.
+func (c *cli) transpile(args []string) error {
+	flags := c.flagSet("transpile", "file.giom")
+	outPath := flags.String("o", "", "write the Gad source to `file` instead of stdout")
+	files, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return c.usageError(flags, "expected one template")
+	}
+	src, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	if *outPath != "" {
+		return giom.Transpile(files[0], src, *outPath)
+	}
+	return giom.TranspileTo(c.stdout, files[0], src)
+}
+
+// check implements `giom check path...`: every template given, or found under
+// a given directory, is compiled with its imports and the errors are written
+// to stderr.
+func (c *cli) check(args []string) error {
+	flags := c.flagSet("check", "[path...]")
+	globalsPath := flags.String("globals", "", "JSON or YAML `file` whose top-level keys are declared as globals")
+	workDir := flags.String("workdir", "", "base `dir` of imports (default: the directory of each template)")
+	verbose := flags.Bool("v", false, "list the templates that compile")
+	paths, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	globals, err := loadGlobals(*globalsPath)
+	if err != nil {
+		return err
+	}
+	names := sortedKeys(globals)
+	files, err := templateFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	r := giom.NewRender(*workDir)
+	failed := 0
+	for _, file := range files {
+		if err := r.Check(file, names...); err != nil {
+			failed++
+			fmt.Fprintln(c.stderr, err)
+		} else if *verbose {
+			fmt.Fprintf(c.stdout, "ok %s\n", file)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(c.stderr, "%d of %d templates failed\n", failed, len(files))
+		return errFailed
+	}
+	return nil
+}
+
+// lint implements `giom lint path...`: every template given, or found under
+// a given directory, is checked by the lint package and its findings are
+// written to stdout.
+func (c *cli) lint(args []string) error {
+	flags := c.flagSet("lint", "[path...]")
+	globalsPath := flags.String("globals", "", "JSON or YAML `file` whose top-level keys are declared as globals")
+	workDir := flags.String("workdir", "", "base `dir` of imports (default: the directory of each template)")
+	trusted := flags.String("trusted", "", "comma-separated `expressions` {=raw …} may write, e.g. Model.Page.Body")
+	paths, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	globals, err := loadGlobals(*globalsPath)
+	if err != nil {
+		return err
+	}
+	files, err := templateFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	l := &lint.Linter{Globals: sortedKeys(globals), WorkDir: *workDir}
+	for _, expr := range strings.Split(*trusted, ",") {
+		if expr = strings.TrimSpace(expr); expr != "" {
+			l.Trusted = append(l.Trusted, expr)
+		}
+	}
+	failed := false
+	for _, file := range files {
+		findings, err := l.LintFile(file)
+		if err != nil {
+			failed = true
+			fmt.Fprintln(c.stderr, err)
+			continue
+		}
+		for _, f := range findings {
+			failed = true
+			fmt.Fprintln(c.stdout, f)
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// templateFiles returns the files of paths, with each directory replaced by
+// the .giom files under it, sorted.
+func templateFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(p) == ".giom" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ast implements `giom ast file.giom`: the parsed node.File is written to
+// stdout as an indented tree (see dumpFile).
+func (c *cli) ast(args []string) error {
+	flags := c.flagSet("ast", "file.giom")
+	files, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return c.usageError(flags, "expected one template")
+	}
+	src, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	fileSet := source.NewFileSet()
+	file, err := giomparser.NewParser(fileSet.AddFileData(files[0], -1, src)).ParseFile()
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	dumpFile(&out, fileSet, file)
+	_, err = out.WriteTo(c.stdout)
+	return err
+}
+
+// format implements `giom fmt path...`: every template given, or found under a
+// given directory, is formatted (see giom.FormatSource) and written to stdout,
+// or back to its file with -w.
+func (c *cli) format(args []string) error {
+	flags := c.flagSet("fmt", "path...")
+	write := flags.Bool("w", false, "write the result to the template files instead of stdout")
+	list := flags.Bool("l", false, "list the templates whose formatting differs")
+	paths, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return c.usageError(flags, "expected templates")
+	}
+	files, err := templateFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, file := range files {
+		if err := c.formatFile(file, *write, *list); err != nil {
+			failed++
+			fmt.Fprintf(c.stderr, "%s: %v\n", file, err)
+		}
+	}
+	if failed > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// formatFile formats one template as the fmt command does.
+func (c *cli) formatFile(file string, write, list bool) error {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	out, err := giom.FormatSource(src)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(src, out)
+	if list && changed {
+		fmt.Fprintln(c.stdout, file)
+	}
+	if write {
+		if changed {
+			return os.WriteFile(file, out, 0644)
+		}
+		return nil
+	}
+	if !list {
+		_, err = c.stdout.Write(out)
+	}
+	return err
+}
+
+// convert implements `giom convert [file.html]`: the HTML file, or stdin, is
+// converted to a Giom template (see giom.HTMLConverter) written to stdout, or
+// to the -o file.
+func (c *cli) convert(args []string) error {
+	flags := c.flagSet("convert", "[file.html]")
+	outPath := flags.String("o", "", "write the template to `file` instead of stdout")
+	raw := flags.Bool("raw", false, "keep complex inline markup as raw HTML regions")
+	files, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		return c.usageError(flags, "expected at most one HTML file")
+	}
+	in := c.stdin
+	if len(files) == 1 {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	out, err := (&giom.HTMLConverter{RawInline: *raw}).Convert(in)
+	if err != nil {
+		return err
+	}
+	if *outPath != "" {
+		return os.WriteFile(*outPath, out, 0644)
+	}
+	_, err = c.stdout.Write(out)
+	return err
+}
+
+// lsp implements `giom lsp`: the language server of the lsp package serves
+// the editor over stdin and stdout.
+func (c *cli) lsp(args []string) error {
+	flags := c.flagSet("lsp", "")
+	globalsPath := flags.String("globals", "", "JSON or YAML `file` whose top-level keys are declared as globals")
+	rest, err := c.parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return c.usageError(flags, "unexpected arguments")
+	}
+	globals, err := loadGlobals(*globalsPath)
+	if err != nil {
+		return err
+	}
+	s := lsp.NewServer()
+	s.Globals = sortedKeys(globals)
+	return s.Serve(c.stdin, c.stdout)
+}
+
+// parseMarkup returns the markup of a --markup mode name.
+func parseMarkup(name string) (giom.Markup, error) {
+	switch name {
+	case "html":
+		return giom.Markup{Mode: giom.HTML}, nil
+	case "html5":
+		return giom.Markup{Mode: giom.HTML5}, nil
+	case "xhtml":
+		return giom.Markup{Mode: giom.XHTML}, nil
+	case "xml":
+		return giom.Markup{Mode: giom.XML}, nil
+	}
+	return giom.Markup{}, fmt.Errorf("unknown markup %q", name)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gad-lang/gad"
+	"gopkg.in/yaml.v3"
+)
+
+// loadGlobals reads the globals of a render from a JSON (.json) or YAML
+// (.yaml, .yml) file holding a mapping: each top-level key is a global. An
+// empty path yields no globals.
+func loadGlobals(path string) (gad.Dict, error) {
+	globals := gad.Dict{}
+	if path == "" {
+		return globals, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	switch filepath.Ext(path) {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		err = d.Decode(&v)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &v)
+	default:
+		return nil, fmt.Errorf("globals %s: expected a .json, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("globals %s: %w", path, err)
+	}
+	if v == nil {
+		return globals, nil
+	}
+	d, ok := toObject(v).(gad.Dict)
+	if !ok {
+		return nil, fmt.Errorf("globals %s: expected a mapping at the top level", path)
+	}
+	return d, nil
+}
+
+// toObject converts a decoded JSON or YAML value to a Gad value: mappings
+// become dicts, sequences arrays and YAML timestamps RFC 3339 strings.
+func toObject(v any) gad.Object {
+	switch t := v.(type) {
+	case nil:
+		return gad.Nil
+	case string:
+		return gad.Str(t)
+	case bool:
+		return gad.Bool(t)
+	case int:
+		return gad.Int(t)
+	case int64:
+		return gad.Int(t)
+	case uint64:
+		return gad.Uint(t)
+	case float64:
+		return gad.Float(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return gad.Int(i)
+		}
+		f, _ := t.Float64()
+		return gad.Float(f)
+	case time.Time:
+		return gad.Str(t.Format(time.RFC3339))
+	case []any:
+		arr := make(gad.Array, len(t))
+		for i, e := range t {
+			arr[i] = toObject(e)
+		}
+		return arr
+	case map[string]any:
+		d := make(gad.Dict, len(t))
+		for k, e := range t {
+			d[k] = toObject(e)
+		}
+		return d
+	case map[any]any:
+		// YAML mappings with non-string keys; the keys are written as strings.
+		d := make(gad.Dict, len(t))
+		for k, e := range t {
+			d[fmt.Sprint(k)] = toObject(e)
+		}
+		return d
+	default:
+		return gad.Str(fmt.Sprint(t))
+	}
+}
+
+// sortedKeys returns the keys of d in order.
+func sortedKeys(d gad.Dict) []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,142 @@
+// Command giom renders, transpiles and checks Giom templates without writing
+// Go:
+//
+//	giom render page.giom --globals data.yaml   write the HTML to stdout
+//	giom transpile page.giom [-o page.gad]      write the generated Gad source
+//	giom check templates/                       compile every template, report errors
+//	giom lint templates/                        report likely mistakes in templates
+//	giom ast page.giom                          dump the parsed template
+//	giom fmt -w templates/                      format templates in place
+//	giom convert page.html [-o page.giom]       convert HTML to a template
+//	giom lsp                                    serve editors over stdio
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"render", "file.giom", "render a template to HTML", (*cli).render},
+	{"transpile", "file.giom", "write the Gad source generated for a template", (*cli).transpile},
+	{"check", "path...", "compile templates and report their errors", (*cli).check},
+	{"lint", "path...", "report likely mistakes in templates", (*cli).lint},
+	{"ast", "file.giom", "dump the parsed template", (*cli).ast},
+	{"fmt", "path...", "format templates", (*cli).format},
+	{"convert", "[file.html]", "convert HTML to a Giom template", (*cli).convert},
+	{"lsp", "", "run the language server on stdin and stdout", (*cli).lsp},
+}
+
+var (
+	// errUsage reports a usage error whose message was already written.
+	errUsage = errors.New("usage")
+	// errFailed reports a failure whose errors were already written.
+	errFailed = errors.New("failed")
+)
+
+// cli holds the streams of a run.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code: 0 on success,
+// 1 when the command failed and 2 on a usage error.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		c.usage()
+		return 2
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		c.usage()
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(c, args[1:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		case !errors.Is(err, errFailed):
+			fmt.Fprintf(stderr, "giom %s: %v\n", cmd.name, err)
+		}
+		return 1
+	}
+	fmt.Fprintf(stderr, "giom: unknown command %q\n", args[0])
+	c.usage()
+	return 2
+}
+
+func (c *cli) usage() {
+	var b strings.Builder
+	b.WriteString("usage: giom <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	b.WriteString("\nRun \"giom <command> -h\" for the flags of a command.\n")
+	fmt.Fprint(c.stderr, b.String())
+}
+
+// flagSet returns the flag set of the named command, whose usage line lists
+// args after the flags.
+func (c *cli) flagSet(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet("giom "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: giom %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses args with flags and returns the positional arguments. Unlike
+// flags.Parse, flags may also follow the positional arguments
+// (`giom render page.giom --globals data.json`); "--" ends the flags.
+func (c *cli) parse(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		rest := flags.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// usageError writes msg and the usage of flags, and returns errUsage.
+func (c *cli) usageError(flags *flag.FlagSet, msg string) error {
+	fmt.Fprintf(c.stderr, "%s: %s\n", flags.Name(), msg)
+	flags.Usage()
+	return errUsage
+}
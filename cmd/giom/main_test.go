@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gad-lang/gad/giom"
+)
+
+// runCLI runs the command line args and returns the exit code and outputs.
+func runCLI(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(""), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// writeFiles writes name → content files into a new temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+const pageSrc = "@main\n    h1 {= Title}\n    @for tag in Tags\n        span {= tag}\n"
+
+func TestRender(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.giom":  pageSrc,
+		"data.json":  `{"Title": "Home", "Tags": ["a", 1]}`,
+		"data.yaml":  "Title: Home\nTags:\n  - a\n  - 1\n",
+		"list.yaml":  "- a\n",
+		"plain.giom": "@main\n    br\n",
+	})
+	page := filepath.Join(dir, "page.giom")
+	want := "<h1>Home</h1><span>a</span><span>1</span>"
+
+	for _, data := range []string{"data.json", "data.yaml"} {
+		t.Run(data, func(t *testing.T) {
+			code, out, errOut := runCLI("render", page, "--globals", filepath.Join(dir, data))
+			require.Equal(t, 0, code, errOut)
+			require.Equal(t, want, out)
+		})
+	}
+
+	code, out, _ := runCLI("render", "-markup", "html5", filepath.Join(dir, "plain.giom"))
+	require.Equal(t, 0, code)
+	require.Equal(t, "<br>", out)
+
+	code, _, errOut := runCLI("render", page, "--globals", filepath.Join(dir, "list.yaml"))
+	require.Equal(t, 1, code)
+	require.Contains(t, errOut, "expected a mapping at the top level")
+
+	code, out, errOut = runCLI("render", page)
+	require.Equal(t, 1, code)
+	require.Empty(t, out)
+	require.Contains(t, errOut, "giom render: ")
+}
+
+func TestRenderCover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.giom": pageSrc,
+		"data.json": `{"Title": "Home", "Tags": ["a", 1]}`,
+	})
+	page := filepath.Join(dir, "page.giom")
+	profile, html := filepath.Join(dir, "cover.txt"), filepath.Join(dir, "cover.html")
+
+	code, out, errOut := runCLI("render", "-coverprofile", profile, "-coverhtml", html, page, "--globals", filepath.Join(dir, "data.json"))
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "<h1>Home</h1><span>a</span><span>1</span>", out)
+
+	report, err := os.ReadFile(profile)
+	require.NoError(t, err)
+	require.Equal(t, page+": 2/2 blocks covered (100.0%)\n"+
+		"\t"+page+":1:1\t@main\t1\n"+
+		"\t"+page+":4:9\t@for\t2\n", string(report))
+	annotated, err := os.ReadFile(html)
+	require.NoError(t, err)
+	require.Contains(t, string(annotated), `<span class="c">2</span>        span {= tag}`)
+}
+
+func TestRenderProfile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.giom": pageSrc,
+		"data.json": `{"Title": "Home", "Tags": ["a", 1]}`,
+	})
+	page := filepath.Join(dir, "page.giom")
+	profile, folded := filepath.Join(dir, "prof.pb.gz"), filepath.Join(dir, "prof.folded")
+
+	code, out, errOut := runCLI("render", "-profile", profile, "-folded", folded, page, "--globals", filepath.Join(dir, "data.json"))
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "<h1>Home</h1><span>a</span><span>1</span>", out)
+
+	info, err := os.Stat(profile)
+	require.NoError(t, err)
+	require.NotZero(t, info.Size())
+	stacks, err := os.ReadFile(folded)
+	require.NoError(t, err)
+	require.Regexp(t, "^"+regexp.QuoteMeta(page+":2 {=}")+" \\d+\n"+
+		regexp.QuoteMeta(page+":3 @for")+" \\d+\n"+
+		regexp.QuoteMeta(page+":3 @for;"+page+":4 {=}")+" \\d+\n$", string(stacks))
+}
+
+func TestTranspile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"page.giom": "@main\n    p hi\n"})
+	page := filepath.Join(dir, "page.giom")
+
+	code, out, errOut := runCLI("transpile", page)
+	require.Equal(t, 0, code, errOut)
+	require.Contains(t, out, `giom.Tag(tag, "p")`)
+
+	outPath := filepath.Join(dir, "out", "page.gad")
+	code, _, errOut = runCLI("transpile", page, "-o", outPath)
+	require.Equal(t, 0, code, errOut)
+	written, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	require.Equal(t, out, string(written))
+	m, err := giom.ReadSourceMap(outPath + ".map")
+	require.NoError(t, err)
+	require.Equal(t, []string{"../page.giom"}, m.Sources)
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.giom":           "@main\n    p hi\n",
+		"lib/card.giom":     "@comp card(title)\n    h2 {= title}\n",
+		"lib/readme.txt":    "not a template",
+		"model.giom":        "@main\n    p {= Model.Title}\n",
+		"globals.json":      `{"Model": {}}`,
+		"broken/bad.giom":   "@main\n    p {= undefinedVar }\n",
+		"broken/parse.giom": "@main\n    @else\n",
+	})
+
+	code, out, errOut := runCLI("check", "-v", "-globals", filepath.Join(dir, "globals.json"),
+		filepath.Join(dir, "ok.giom"), filepath.Join(dir, "model.giom"), filepath.Join(dir, "lib"))
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "ok "+filepath.Join(dir, "lib", "card.giom")+"\n"+
+		"ok "+filepath.Join(dir, "model.giom")+"\n"+
+		"ok "+filepath.Join(dir, "ok.giom")+"\n", out)
+
+	code, out, errOut = runCLI("check", filepath.Join(dir, "broken"))
+	require.Equal(t, 1, code)
+	require.Empty(t, out)
+	require.Contains(t, errOut, "bad.giom")
+	require.Contains(t, errOut, "unexpected ELSE without matching @if")
+	require.Contains(t, errOut, "2 of 2 templates failed")
+}
+
+func TestLint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.giom":           "@main\n    img[src=\"a.png\", alt=\"A\"]\n    div {=raw Model.Body}\n",
+		"globals.yaml":      "Model: {}\n",
+		"broken/img.giom":   "@main\n    img[src=\"a.png\"]\n",
+		"broken/parse.giom": "@main\n    @else\n",
+	})
+
+	code, out, errOut := runCLI("lint", "-globals", filepath.Join(dir, "globals.yaml"), "-trusted", "Model.Body",
+		filepath.Join(dir, "ok.giom"))
+	require.Equal(t, 0, code, errOut)
+	require.Empty(t, out)
+
+	code, out, errOut = runCLI("lint", filepath.Join(dir, "broken"))
+	require.Equal(t, 1, code)
+	require.Equal(t, filepath.Join(dir, "broken", "img.giom")+":2:5: img has no alt attribute (img-alt)\n", out)
+	require.Contains(t, errOut, "unexpected ELSE without matching @if")
+}
+
+func TestAST(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"page.giom": "div.a\n    p {= x}\n"})
+
+	code, out, errOut := runCLI("ast", filepath.Join(dir, "page.giom"))
+	require.Equal(t, 0, code, errOut)
+	require.Contains(t, out, "File "+filepath.Join(dir, "page.giom")+"\n  Stmts:\n    TagStmt 1:1-")
+	require.Contains(t, out, "      Name: \"div\"\n      Attributes:\n        TagAttribute\n          Name: \"class\"\n")
+	require.Contains(t, out, "TextStmt 2:")
+}
+
+func TestFmt(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.giom":       "p\n    span x\n",
+		"sub/page.giom": "p\n  span x\n",
+		"bad.txt":       "p\n  span x\n",
+	})
+	page := filepath.Join(dir, "sub", "page.giom")
+
+	code, out, errOut := runCLI("fmt", "-l", dir)
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, page+"\n", out)
+
+	code, out, errOut = runCLI("fmt", page)
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "p\n    span x\n", out)
+
+	code, out, errOut = runCLI("fmt", "-w", dir)
+	require.Equal(t, 0, code, errOut)
+	require.Empty(t, out)
+	written, err := os.ReadFile(page)
+	require.NoError(t, err)
+	require.Equal(t, "p\n    span x\n", string(written))
+
+	code, out, _ = runCLI("fmt", "-l", dir)
+	require.Equal(t, 0, code)
+	require.Empty(t, out)
+
+	broken := writeFiles(t, map[string]string{"bad.giom": "@main\n    @else\n"})
+	code, _, errOut = runCLI("fmt", broken)
+	require.Equal(t, 1, code)
+	require.Contains(t, errOut, "bad.giom: ")
+}
+
+func TestConvert(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.html": `<!DOCTYPE html><h1 id="title" class="big">Hello</h1><p>See <b>the <i>docs</i></b>.</p>`,
+	})
+	code, out, errOut := runCLI("convert", filepath.Join(dir, "page.html"))
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "!!! 5\nh1#title.big Hello\np See #[b the #[i docs]].\n", out)
+
+	outPath := filepath.Join(dir, "page.giom")
+	code, out, errOut = runCLI("convert", "-raw", "-o", outPath, filepath.Join(dir, "page.html"))
+	require.Equal(t, 0, code, errOut)
+	require.Empty(t, out)
+	written, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	require.Contains(t, string(written), "    <>See <b>the <i>docs</i></b>.</>\n")
+
+	var stdout, stderr bytes.Buffer
+	code = run([]string{"convert"}, strings.NewReader(`<ul><li><a href="/">Home</a></li></ul>`), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	require.Equal(t, "ul\n    li: a[href=\"/\"] Home\n", stdout.String())
+}
+
+func TestLSP(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+	in := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	var out, errOut bytes.Buffer
+	code := run([]string{"lsp"}, strings.NewReader(in), &out, &errOut)
+	require.Equal(t, 0, code, errOut.String())
+	require.True(t, strings.HasPrefix(out.String(), "Content-Length: "))
+	require.Contains(t, out.String(), `"id":1,"result":{"capabilities":`)
+	require.Contains(t, out.String(), `"serverInfo":{"name":"giom"}`)
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"no command", nil, 2, "usage: giom <command>"},
+		{"help", []string{"help"}, 0, "commands:"},
+		{"unknown command", []string{"serve"}, 2, `unknown command "serve"`},
+		{"command help", []string{"render", "-h"}, 0, "usage: giom render [flags] file.giom"},
+		{"unknown flag", []string{"render", "-x", "a.giom"}, 2, "flag provided but not defined: -x"},
+		{"missing file", []string{"ast"}, 2, "giom ast: expected one template"},
+		{"fmt without paths", []string{"fmt"}, 2, "giom fmt: expected templates"},
+		{"lsp with paths", []string{"lsp", "a.giom"}, 2, "giom lsp: unexpected arguments"},
+		{"convert with files", []string{"convert", "a.html", "b.html"}, 2, "giom convert: expected at most one HTML file"},
+		{"bad markup", []string{"render", "-markup", "svg", "a.giom"}, 2, `unknown markup "svg"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, errOut := runCLI(tt.args...)
+			require.Equal(t, tt.code, code)
+			require.Contains(t, errOut, tt.want)
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	c := &cli{stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}}
+	flags := c.flagSet("test", "args")
+	o := flags.String("o", "", "")
+	v := flags.Bool("v", false, "")
+	args, err := c.parse(flags, []string{"a", "-o", "x", "b", "-v", "--", "-c"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "-c"}, args)
+	require.Equal(t, "x", *o)
+	require.True(t, *v)
+}
@@ -5,21 +5,24 @@ import (
 	"fmt"
 
 	"github.com/gad-lang/gad"
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
 	gp "github.com/gad-lang/gad/parser"
 	"github.com/gad-lang/gad/parser/ast"
 	gnode "github.com/gad-lang/gad/parser/node"
 	"github.com/gad-lang/gad/parser/source"
 	"github.com/gad-lang/gad/token"
-	giomnode "github.com/gad-lang/gad/giom/node"
-	giomparser "github.com/gad-lang/gad/giom/parser"
 )
 
 // Compiler compiles giom v2 source into GAD bytecode. Construct one with
 // NewCompiler and call Compile; the same Compiler may compile multiple inputs
 // with the same symbol table and options.
 type Compiler struct {
-	st   *gad.SymbolTable
-	opts gad.CompileOptions
+	st       *gad.SymbolTable
+	opts     gad.CompileOptions
+	cover    *Cover
+	profiler *Profiler
+	builtins *gad.Builtins
 }
 
 // NewCompiler returns a Compiler bound to the given symbol table and compile
@@ -28,6 +31,31 @@ func NewCompiler(st *gad.SymbolTable, opts gad.CompileOptions) *Compiler {
 	return &Compiler{st: st, opts: opts}
 }
 
+// WithCover instruments the templates c compiles for coverage, counting in
+// cover (see Cover); a nil cover disables it. It returns c.
+func (c *Compiler) WithCover(cover *Cover) *Compiler {
+	c.cover = cover
+	return c
+}
+
+// WithProfiler instruments the templates c compiles for profiling, recording
+// in profiler (see Profiler); a nil profiler disables it. It returns c.
+func (c *Compiler) WithProfiler(profiler *Profiler) *Compiler {
+	c.profiler = profiler
+	return c
+}
+
+// AppendBuiltins adds the giom builtins to b and returns it, with the
+// giom.cover and giom.prof* counters bound to the Cover and Profiler of c.
+// Run the bytecode c compiles with these builtins.
+//
+// When c was created without a symbol table, it then compiles each input with
+// a new one holding the names of b, and the bytecode must run with b.Build().
+func (c *Compiler) AppendBuiltins(b *gad.Builtins) *gad.Builtins {
+	c.builtins = appendBuiltins(b, c.cover, c.profiler)
+	return c.builtins
+}
+
 // Compile parses giom v2 source and compiles it to GAD bytecode.
 func (c *Compiler) Compile(input []byte) (*giomnode.File, *gad.Bytecode, error) {
 	fs := source.NewFileSet()
@@ -41,7 +69,13 @@ func (c *Compiler) Compile(input []byte) (*giomnode.File, *gad.Bytecode, error)
 	if err != nil {
 		return nil, nil, err
 	}
-	bc, err := CompileFile(c.st, &gad.ModuleSpec{ModuleInfo: gad.ModuleInfo{Name: gad.MainName}, Main: true}, file, c.opts)
+	c.cover.instrument(f, input, file.Stmts)
+	file.Stmts = c.profiler.instrument(f, file.Stmts)
+	st := c.st
+	if st == nil && c.builtins != nil {
+		st = gad.NewSymbolTable(c.builtins.NameSet)
+	}
+	bc, err := CompileFile(st, &gad.ModuleSpec{ModuleInfo: gad.ModuleInfo{Name: gad.MainName}, Main: true}, file, c.opts)
 	return file, bc, err
 }
 
@@ -103,6 +137,13 @@ func CompileFallback(c *gad.Compiler, nd ast.Node) error {
 		*giomnode.SlotDecl,
 		*giomnode.SlotPassStmt,
 		*giomnode.ForStmt,
+		*giomnode.WhileStmt,
+		*giomnode.BranchStmt,
+		*giomnode.VerbatimStmt,
+		*giomnode.EmbedStmt,
+		*giomnode.StackStmt,
+		*giomnode.PushStmt,
+		*giomnode.OnceStmt,
 		*giomnode.IfStmt,
 		*giomnode.DoctypeStmt,
 		*giomnode.TextStmt,
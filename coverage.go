@@ -0,0 +1,313 @@
+package giom
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gad-lang/gad"
+	giomnode "github.com/gad-lang/gad/giom/node"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+)
+
+// Cover collects template coverage: the templates compiled with it are
+// instrumented so that each branch of @if, @for, @while and @match, each
+// component and each slot default counts its runs, as `go test -cover` does
+// for Go code. Set Render.Cover (or use Compiler.WithCover) before the first
+// render, render, then write the counts with WriteReport or WriteHTML. It is
+// safe for concurrent use.
+type Cover struct {
+	mu    sync.RWMutex
+	files map[string]*CoverFile
+	// blocks indexes the blocks of files by the id their giom.cover counter
+	// passes; next is the id of the next block.
+	blocks map[int]*CoverBlock
+	next   int
+}
+
+// NewCover returns an empty Cover.
+func NewCover() *Cover {
+	return &Cover{files: map[string]*CoverFile{}, blocks: map[int]*CoverBlock{}}
+}
+
+// CoverFile is an instrumented template and its blocks, in source order.
+type CoverFile struct {
+	Name   string
+	Source []byte
+	Blocks []*CoverBlock
+}
+
+// Covered returns the number of blocks of f that ran.
+func (f *CoverFile) Covered() (n int) {
+	for _, b := range f.Blocks {
+		if b.Count() > 0 {
+			n++
+		}
+	}
+	return
+}
+
+// CoverBlock is a counted block of a template: a branch body, from its first
+// statement to its last, or a whole component. Lines and columns are 1-based.
+type CoverBlock struct {
+	// Kind names the block, e.g. "@if", "@else if", "@case", "@comp card" or
+	// "@slot header".
+	Kind               string
+	Line, Column       int
+	EndLine, EndColumn int
+	id                 int
+	count              atomic.Int64
+}
+
+// Count returns the number of times the block ran.
+func (b *CoverBlock) Count() int64 { return b.count.Load() }
+
+// BuiltinCover implements giom.cover(id), the counter coverage
+// instrumentation adds at the start of a block. It counts nothing: the
+// builtins of Render and Compiler.AppendBuiltins replace it with the counter
+// of their Cover.
+var BuiltinCover = &gad.Function{
+	FuncName: "giom.cover",
+	Module:   ModuleSpec,
+	Value: func(call gad.Call) (gad.Object, error) {
+		return nil, errors.New("giom.cover: no Cover bound to the builtins (see Compiler.AppendBuiltins)")
+	},
+}
+
+// builtin returns the giom.cover counter of c's blocks. Blocks dropped when
+// their template was instrumented again no longer count.
+func (c *Cover) builtin() *gad.Function {
+	return &gad.Function{
+		FuncName: "giom.cover",
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (gad.Object, error) {
+			id, err := counterID(call, "giom.cover", "block")
+			if err != nil {
+				return nil, err
+			}
+			c.mu.RLock()
+			b, next := c.blocks[id], c.next
+			c.mu.RUnlock()
+			if b == nil {
+				if id < 0 || id >= next {
+					return nil, fmt.Errorf("giom.cover: unknown block %d", id)
+				}
+				return gad.Nil, nil
+			}
+			b.count.Add(1)
+			return gad.Nil, nil
+		},
+	}
+}
+
+// counterID returns the id argument of the fn counter call, the int literal
+// instrumentation passes as the id of a block or site (what).
+func counterID(call gad.Call, fn, what string) (int, error) {
+	if err := call.Args.CheckLen(1); err != nil {
+		return 0, err
+	}
+	arg := call.Args.GetOnly(0)
+	id, ok := arg.(gad.Int)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s id %s is not an int", fn, what, arg.ToString())
+	}
+	return int(id), nil
+}
+
+// Files returns the instrumented templates sorted by name.
+func (c *Cover) Files() []*CoverFile {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	files := make([]*CoverFile, 0, len(c.files))
+	for _, f := range c.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+// instrument registers the blocks of the parsed template stmts, read from
+// file with source src, and prepends their counters. Instrumenting a template
+// again, e.g. when Render recompiles it, replaces its blocks. A nil Cover does
+// nothing.
+func (c *Cover) instrument(file *source.File, src []byte, stmts gnode.Stmts) {
+	if c == nil {
+		return
+	}
+	f := &CoverFile{Name: file.Name, Source: src}
+	count := func(kind string, pos, end source.Pos, body gnode.Stmts) gnode.Stmts {
+		if len(body) == 0 {
+			return body
+		}
+		if !pos.IsValid() {
+			pos, end = body[0].Pos(), body[len(body)-1].End()
+		}
+		if !pos.IsValid() {
+			return body
+		}
+		if !end.IsValid() || end < pos {
+			end = pos
+		}
+		start, stop := source.MustFilePosition(file, pos), source.MustFilePosition(file, end)
+		b := &CoverBlock{Kind: kind, Line: start.Line, Column: start.Column, EndLine: stop.Line, EndColumn: stop.Column}
+		f.Blocks = append(f.Blocks, b)
+
+		c.mu.Lock()
+		b.id = c.next
+		c.next++
+		if c.blocks == nil {
+			c.blocks = map[int]*CoverBlock{}
+		}
+		c.blocks[b.id] = b
+		c.mu.Unlock()
+		return append(gnode.Stmts{coverStmt(b.id, pos)}, body...)
+	}
+	branch := func(kind string, body gnode.Stmts) gnode.Stmts {
+		return count(kind, 0, 0, body)
+	}
+
+	giomnode.Walk(stmts, func(stmt gnode.Stmt) bool {
+		switch s := stmt.(type) {
+		case *giomnode.CommentStmt:
+			return false
+		case *giomnode.IfStmt:
+			s.Body = branch("@if", s.Body)
+			for _, eif := range s.ElseIfs {
+				eif.Body = branch("@else if", eif.Body)
+			}
+			s.Else = branch("@else", s.Else)
+		case *giomnode.ForStmt:
+			s.Body = branch("@for", s.Body)
+			s.Else = branch("@else", s.Else)
+		case *giomnode.WhileStmt:
+			s.Body = branch("@while", s.Body)
+		case *giomnode.MatchStmt:
+			for _, cc := range s.Cases {
+				cc.Body = branch("@case", cc.Body)
+			}
+			s.Default = branch("@else", s.Default)
+		case *giomnode.CompDecl:
+			kind := "@comp " + s.Name
+			if s.Main {
+				kind = "@main"
+			}
+			s.Body = count(kind, s.Pos(), s.End(), s.Body)
+		case *giomnode.SlotDecl:
+			s.Body = branch("@slot "+s.Name, s.Body)
+		}
+		return true
+	})
+	sort.SliceStable(f.Blocks, func(i, j int) bool {
+		a, b := f.Blocks[i], f.Blocks[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	c.mu.Lock()
+	if c.files == nil {
+		c.files = map[string]*CoverFile{}
+	}
+	if old := c.files[f.Name]; old != nil {
+		for _, b := range old.Blocks {
+			delete(c.blocks, b.id)
+		}
+	}
+	c.files[f.Name] = f
+	c.mu.Unlock()
+}
+
+// coverStmt builds the `giom.cover(id)` counter of block id.
+func coverStmt(id int, pos source.Pos) gnode.Stmt {
+	call := gnode.ECall(gnode.ESelector(gnode.EIdent("giom", pos), gnode.Str("cover", 0)), 0, 0)
+	call.Args.Values = []gnode.Expr{&gnode.IntLit{Value: int64(id), ValuePos: pos, Literal: strconv.Itoa(id)}}
+	return &giomnode.CodeStmt{NodePos: pos, NodeEnd: pos, Stmts: gnode.Stmts{gnode.SExpr(call)}}
+}
+
+// WriteReport writes, for each template, the share of its blocks that ran
+// and the count of each block:
+//
+//	page.giom: 3/4 blocks covered (75.0%)
+//		page.giom:4:9	@if	2
+//		page.giom:6:9	@else	0
+func (c *Cover) WriteReport(w io.Writer) error {
+	var b strings.Builder
+	for _, f := range c.Files() {
+		fmt.Fprintf(&b, "%s: %d/%d blocks covered (%.1f%%)\n", f.Name, f.Covered(), len(f.Blocks), percent(f.Covered(), len(f.Blocks)))
+		for _, block := range f.Blocks {
+			fmt.Fprintf(&b, "\t%s:%d:%d\t%s\t%d\n", f.Name, block.Line, block.Column, block.Kind, block.Count())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes a page showing the source of each template with the
+// count of the innermost block of each line: lines that ran are green and
+// lines that never ran are red.
+func (c *Cover) WriteHTML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(coverHTMLHead)
+	for _, f := range c.Files() {
+		fmt.Fprintf(&b, "<h2>%s <small>%d/%d blocks covered (%.1f%%)</small></h2>\n<pre>",
+			html.EscapeString(f.Name), f.Covered(), len(f.Blocks), percent(f.Covered(), len(f.Blocks)))
+		for i, line := range strings.Split(strings.TrimSuffix(string(f.Source), "\n"), "\n") {
+			class, count := "", ""
+			if block := f.innermost(i + 1); block != nil {
+				class = " hit"
+				if block.Count() == 0 {
+					class = " miss"
+				}
+				count = strconv.FormatInt(block.Count(), 10)
+			}
+			fmt.Fprintf(&b, "<span class=\"line%s\"><span class=\"n\">%d</span><span class=\"c\">%s</span>%s</span>\n",
+				class, i+1, count, html.EscapeString(line))
+		}
+		b.WriteString("</pre>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// innermost returns the smallest block spanning line, or nil.
+func (f *CoverFile) innermost(line int) (in *CoverBlock) {
+	for _, b := range f.Blocks {
+		if b.Line <= line && line <= b.EndLine && (in == nil || b.EndLine-b.Line <= in.EndLine-in.Line) {
+			in = b
+		}
+	}
+	return
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+const coverHTMLHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { line-height: 1.3; }
+.line { display: block; }
+.n, .c { display: inline-block; text-align: right; color: #888; user-select: none; }
+.n { width: 4em; padding-right: 1em; }
+.c { width: 4em; padding-right: 1em; }
+.hit { background: #e6ffed; }
+.miss { background: #ffeef0; }
+</style>
+</head>
+<body>
+<h1>Template coverage</h1>
+`
@@ -0,0 +1,142 @@
+package giom
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCover(t *testing.T) {
+	src := "@comp card(title)\n" +
+		"    div.card\n" +
+		"        @slot header\n" +
+		"            h2 {= title}\n" +
+		"@main\n" +
+		"    @if n > 1\n" +
+		"        p many\n" +
+		"    @else if n == 1\n" +
+		"        p one\n" +
+		"    @else\n" +
+		"        p none\n" +
+		"    ul\n" +
+		"        @for x in xs\n" +
+		"            li {= x}\n" +
+		"        @else\n" +
+		"            li empty\n" +
+		"    +card(\"A\")\n"
+	dir := t.TempDir()
+	p := filepath.Join(dir, "t.giom")
+	require.NoError(t, os.WriteFile(p, []byte(src), 0644))
+
+	r := newTestRender(t, dir)
+	r.Cover = NewCover()
+	out, err := renderString(r, p, gad.Dict{"n": gad.Int(1), "xs": gad.Array{gad.Str("a"), gad.Str("b")}})
+	require.NoError(t, err)
+	require.Equal(t, `<p>one</p><ul><li>a</li><li>b</li></ul><div class="card"><h2>A</h2></div>`, out)
+	_, err = renderString(r, p, gad.Dict{"n": gad.Int(3), "xs": gad.Array{}})
+	require.NoError(t, err)
+
+	files := r.Cover.Files()
+	require.Len(t, files, 1)
+	var blocks []string
+	for _, b := range files[0].Blocks {
+		blocks = append(blocks, fmt.Sprintf("%d:%d %s %d", b.Line, b.Column, b.Kind, b.Count()))
+	}
+	require.Equal(t, []string{
+		"1:1 @comp card 2",
+		"4:13 @slot header 2",
+		"5:1 @main 2",
+		"7:9 @if 1",
+		"9:9 @else if 1",
+		"11:9 @else 0",
+		"14:13 @for 2",
+		"16:13 @else 1",
+	}, blocks)
+	require.Equal(t, 7, files[0].Covered())
+
+	var report bytes.Buffer
+	require.NoError(t, r.Cover.WriteReport(&report))
+	require.Contains(t, report.String(), p+": 7/8 blocks covered (87.5%)\n")
+	require.Contains(t, report.String(), "\t"+p+":11:9\t@else\t0\n")
+
+	var page bytes.Buffer
+	require.NoError(t, r.Cover.WriteHTML(&page))
+	require.Contains(t, page.String(), `<span class="line miss"><span class="n">11</span><span class="c">0</span>        p none</span>`)
+	require.Contains(t, page.String(), `<span class="line hit"><span class="n">14</span><span class="c">2</span>            li {= x}</span>`)
+}
+
+func TestCoverImports(t *testing.T) {
+	got, err := portRunCover(t, "@import \"lib.giom\" as lib\n@main\n    +lib.badge(true)\n",
+		map[string]string{"lib": "@export comp badge(on)\n    @if on\n        b on\n    @else\n        i off\n"})
+	require.NoError(t, err)
+	require.Equal(t, "<b>on</b>", got.out)
+
+	var kinds []string
+	for _, f := range got.cover.Files() {
+		for _, b := range f.Blocks {
+			kinds = append(kinds, fmt.Sprintf("%s %s %d", filepath.Base(f.Name), b.Kind, b.Count()))
+		}
+	}
+	require.Equal(t, []string{
+		// The main template is named by its absolute path, sorted first.
+		"t.giom @main 1",
+		"lib.giom @comp badge 1",
+		"lib.giom @if 1",
+		"lib.giom @else 0",
+	}, kinds)
+}
+
+func TestCoverInstances(t *testing.T) {
+	src := []byte("@main\n    @if on\n        b on\n    @else\n        i off\n")
+	run := func(c *Compiler, on bool) {
+		t.Helper()
+		builtins := c.AppendBuiltins(gad.NewBuiltins())
+		_, bc, err := c.Compile(src)
+		require.NoError(t, err)
+		_, err = gad.NewVM(builtins.Build(), bc).RunOpts(&gad.RunOpts{Globals: gad.Dict{"on": gad.Bool(on)}})
+		require.NoError(t, err)
+	}
+	counts := func(cover *Cover) (out []string) {
+		for _, b := range cover.Files()[0].Blocks {
+			out = append(out, fmt.Sprintf("%s %d", b.Kind, b.Count()))
+		}
+		return
+	}
+	opts := gad.CompileOptions{CompilerOptions: gad.CompilerOptions{FallbackFunc: CompileFallback}}
+	a, b := NewCover(), NewCover()
+	ca := NewCompiler(nil, opts).WithCover(a)
+	run(ca, true)
+	run(NewCompiler(nil, opts).WithCover(b), false)
+	require.Equal(t, []string{"@main 1", "@if 1", "@else 0"}, counts(a))
+	require.Equal(t, []string{"@main 1", "@if 0", "@else 1"}, counts(b))
+
+	// Compiling the template again replaces its blocks.
+	run(ca, false)
+	require.Equal(t, []string{"@main 1", "@if 0", "@else 1"}, counts(a))
+	require.Len(t, a.blocks, 3)
+}
+
+type coverRun struct {
+	out   string
+	cover *Cover
+}
+
+// portRunCover renders tpl, with modules, under coverage.
+func portRunCover(t *testing.T, tpl string, modules map[string]string) (coverRun, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range modules {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".giom"), []byte(src), 0644))
+	}
+	p := filepath.Join(dir, "t.giom")
+	require.NoError(t, os.WriteFile(p, []byte(tpl), 0644))
+	r := newTestRender(t, dir)
+	r.Cover = NewCover()
+	out, err := renderString(r, p, nil)
+	return coverRun{out: out, cover: r.Cover}, err
+}
@@ -0,0 +1,285 @@
+package giom
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gad-lang/gad"
+)
+
+// PatchOp names a render tree patch operation.
+type PatchOp string
+
+const (
+	// PatchInsert inserts Node as child Index of the element at Path.
+	PatchInsert PatchOp = "insert"
+	// PatchRemove removes the element at Path.
+	PatchRemove PatchOp = "remove"
+	// PatchReplace replaces the element at Path with Node.
+	PatchReplace PatchOp = "replace"
+	// PatchSetAttr sets attribute Name of the tag at Path to Value, or removes
+	// it when Value is nil.
+	PatchSetAttr PatchOp = "setAttr"
+	// PatchSetText sets the text of the text node at Path to Value.
+	PatchSetText PatchOp = "setText"
+)
+
+// Patch is one operation of a render tree diff. Patches apply in order and a
+// Path addresses an element by child indexes from the root, as the tree stands
+// when the patch applies. Paths count children as the browser's DOM does:
+// anonymous fragments are transparent, their children counting as children of
+// the enclosing tag, and adjacent texts, which giom often writes as several
+// Text children, are one text node.
+type Patch struct {
+	Op PatchOp
+	// Path is the target element, or the parent for PatchInsert.
+	Path []int
+	// Index is the child position of PatchInsert.
+	Index int
+	// Name is the attribute of PatchSetAttr.
+	Name string
+	// Value is the attribute value of PatchSetAttr (nil removes it) or the text
+	// of PatchSetText.
+	Value *string
+	// Node is the element of PatchInsert and PatchReplace.
+	Node Element
+}
+
+// MarshalJSON implements json.Marshaler, writing only the fields of the
+// operation, e.g. {"op":"setAttr","path":[0,2],"name":"class","value":"on"}.
+// Nodes use the Tag/Text JSON form.
+func (p Patch) MarshalJSON() ([]byte, error) {
+	path := p.Path
+	if path == nil {
+		path = []int{}
+	}
+	m := map[string]any{"op": p.Op, "path": path}
+	switch p.Op {
+	case PatchInsert:
+		m["index"] = p.Index
+		m["node"] = p.Node
+	case PatchReplace:
+		m["node"] = p.Node
+	case PatchSetAttr:
+		m["name"] = p.Name
+		m["value"] = p.Value
+	case PatchSetText:
+		m["value"] = p.Value
+	}
+	return json.Marshal(m)
+}
+
+// Diff compares two render trees and returns the patches turning old into new.
+// Children are matched by their `key` attribute when they have one and by
+// position otherwise; matched tags of the same name are patched in place
+// (attributes, then children), anything else is replaced. The trees are
+// compared as they are: call ResolveStacks on each first when they were built
+// by running a template directly.
+func Diff(old, new Element) []Patch {
+	var d differ
+	d.node(nil, old, new)
+	return d.patches
+}
+
+type differ struct {
+	patches []Patch
+}
+
+func (d *differ) add(p Patch) { d.patches = append(d.patches, p) }
+
+func (d *differ) node(path []int, a, b Element) {
+	switch at := a.(type) {
+	case *Tag:
+		if bt, ok := b.(*Tag); ok && at.Name == bt.Name {
+			if at.Name != "" {
+				d.attrs(path, at, bt)
+			}
+			d.children(path, flatChildren(at), flatChildren(bt))
+			return
+		}
+	case Text:
+		if bt, ok := b.(Text); ok {
+			if at.Equal(bt) {
+				return
+			}
+			if _, ok := plainText(at); ok {
+				if s, ok := plainText(bt); ok {
+					d.add(Patch{Op: PatchSetText, Path: path, Value: &s})
+					return
+				}
+			}
+		}
+	}
+	d.add(Patch{Op: PatchReplace, Path: path, Node: b})
+}
+
+// attrs emits setAttr patches for the attributes that differ between a and b.
+func (d *differ) attrs(path []int, a, b *Tag) {
+	av, bv := diffAttrs(a), diffAttrs(b)
+	for _, kv := range bv {
+		if v, ok := findAttr(av, kv[0]); !ok || v != kv[1] {
+			value := kv[1]
+			d.add(Patch{Op: PatchSetAttr, Path: path, Name: kv[0], Value: &value})
+		}
+	}
+	for _, kv := range av {
+		if _, ok := findAttr(bv, kv[0]); !ok {
+			d.add(Patch{Op: PatchSetAttr, Path: path, Name: kv[0]})
+		}
+	}
+}
+
+// children patches the child list olds into news.
+func (d *differ) children(path []int, olds, news []Element) {
+	// Match each new child to an old one: by key, or else to the next unkeyed
+	// old child.
+	keyed := make(map[string]int)
+	for i, c := range olds {
+		if k := elementKey(c); k != "" {
+			if _, dup := keyed[k]; !dup {
+				keyed[k] = i
+			}
+		}
+	}
+	used := make([]bool, len(olds))
+	match := make([]int, len(news))
+	next := 0
+	for j, c := range news {
+		match[j] = -1
+		if k := elementKey(c); k != "" {
+			if i, ok := keyed[k]; ok && !used[i] {
+				match[j], used[i] = i, true
+			}
+			continue
+		}
+		for next < len(olds) && (used[next] || elementKey(olds[next]) != "") {
+			next++
+		}
+		if next < len(olds) {
+			match[j], used[next] = next, true
+			next++
+		}
+	}
+
+	// Remove the unmatched old children, last first so the earlier indexes stay
+	// valid; cur tracks which old child sits at each position afterwards (-1 for
+	// an inserted one).
+	var cur []int
+	for i := len(olds) - 1; i >= 0; i-- {
+		if !used[i] {
+			d.add(Patch{Op: PatchRemove, Path: childPath(path, i)})
+		}
+	}
+	for i := range olds {
+		if used[i] {
+			cur = append(cur, i)
+		}
+	}
+
+	for j, c := range news {
+		i := match[j]
+		if i >= 0 && j < len(cur) && cur[j] == i {
+			d.node(childPath(path, j), olds[i], c)
+			continue
+		}
+		if i >= 0 {
+			// A moved child is removed from its position and inserted anew.
+			for pos, o := range cur {
+				if o == i {
+					d.add(Patch{Op: PatchRemove, Path: childPath(path, pos)})
+					cur = append(cur[:pos], cur[pos+1:]...)
+					break
+				}
+			}
+		}
+		d.add(Patch{Op: PatchInsert, Path: path, Index: j, Node: c})
+		cur = append(cur[:j], append([]int{-1}, cur[j:]...)...)
+	}
+}
+
+// childPath returns a new path of path's child i.
+func childPath(path []int, i int) []int {
+	p := make([]int, len(path)+1)
+	copy(p, path)
+	p[len(path)] = i
+	return p
+}
+
+// flatChildren returns the children of t as the DOM holds them: anonymous
+// fragments expanded and adjacent texts merged into one text node.
+func flatChildren(t *Tag) (out []Element) {
+	for _, c := range t.Children {
+		switch c := c.(type) {
+		case *Tag:
+			if c.Name == "" {
+				for _, fc := range flatChildren(c) {
+					out = appendChild(out, fc)
+				}
+				continue
+			}
+		case Text:
+			if len(c) == 0 {
+				continue
+			}
+		}
+		out = appendChild(out, c)
+	}
+	return
+}
+
+// appendChild appends el to children, merging it into a preceding text.
+func appendChild(children []Element, el Element) []Element {
+	if t, ok := el.(Text); ok && len(children) > 0 {
+		if prev, ok := children[len(children)-1].(Text); ok {
+			children[len(children)-1] = append(append(Text{}, prev...), t...)
+			return children
+		}
+	}
+	return append(children, el)
+}
+
+// elementKey returns the `key` attribute of a tag element.
+func elementKey(el Element) string {
+	if t, ok := el.(*Tag); ok {
+		return attrString(t, "key")
+	}
+	return ""
+}
+
+// plainText returns the text of t when it holds no raw HTML.
+func plainText(t Text) (string, bool) {
+	var b strings.Builder
+	for _, v := range t {
+		if _, ok := v.(gad.RawStr); ok {
+			return "", false
+		}
+		b.WriteString(xmlString(nil, v))
+	}
+	return b.String(), true
+}
+
+// diffAttrs returns the rendered attributes of t as ordered name/value pairs,
+// with the class list and styles joined as in the HTML output.
+func diffAttrs(t *Tag) (out [][2]string) {
+	for _, name := range t.attrOrder {
+		if v, ok := xmlAttrValue(nil, name, t.Attrs[name]); ok {
+			out = append(out, [2]string{name, v})
+		}
+	}
+	if len(t.ClassList) > 0 {
+		out = append(out, [2]string{"class", strings.Join(t.ClassList, " ")})
+	}
+	if len(t.Styles) > 0 {
+		out = append(out, [2]string{"style", strings.Join(t.Styles, "; ")})
+	}
+	return
+}
+
+func findAttr(attrs [][2]string, name string) (string, bool) {
+	for _, kv := range attrs {
+		if kv[0] == name {
+			return kv[1], true
+		}
+	}
+	return "", false
+}
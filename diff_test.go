@@ -0,0 +1,106 @@
+package giom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gad-lang/gad"
+)
+
+func diffJSON(t *testing.T, a, b Element) string {
+	t.Helper()
+	out, err := json.Marshal(Diff(a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestDiff(t *testing.T) {
+	attrs := func(kv ...string) (arr gad.KeyValueArray) {
+		for i := 0; i < len(kv); i += 2 {
+			arr = append(arr, &gad.KeyValue{K: gad.Str(kv[i]), V: gad.Str(kv[i+1])})
+		}
+		return
+	}
+	li := func(text string, kv ...string) *Tag {
+		return NewTag(nil, "li", []Element{Text{gad.Str(text)}}, attrs(kv...))
+	}
+	ul := func(kv []string, items ...Element) *Tag {
+		return NewTag(nil, "ul", items, attrs(kv...))
+	}
+
+	tests := []struct {
+		name     string
+		old, new Element
+		want     string
+	}{
+		{
+			name: "equal",
+			old:  ul(nil, li("a")),
+			new:  ul(nil, li("a")),
+			want: `null`,
+		},
+		{
+			name: "attributes and text",
+			old:  ul([]string{"id", "a", "title", "t"}, li("x")),
+			new:  ul([]string{"id", "b", "class", "c"}, li("y")),
+			want: `[{"name":"id","op":"setAttr","path":[],"value":"b"},` +
+				`{"name":"class","op":"setAttr","path":[],"value":"c"},` +
+				`{"name":"title","op":"setAttr","path":[],"value":null},` +
+				`{"op":"setText","path":[0,0],"value":"y"}]`,
+		},
+		{
+			name: "append and truncate by position",
+			old:  ul(nil, li("a"), li("b"), li("c")),
+			new:  ul(nil, li("a"), li("b")),
+			want: `[{"op":"remove","path":[2]}]`,
+		},
+		{
+			name: "insert",
+			old:  ul(nil, li("a")),
+			new:  ul(nil, li("a"), li("b")),
+			want: `[{"index":1,"node":{"type":"tag","name":"li","children":[{"type":"text","values":["b"]}]},"op":"insert","path":[]}]`,
+		},
+		{
+			name: "keyed move",
+			old:  ul(nil, li("a", "key", "1"), li("b", "key", "2"), li("c", "key", "3")),
+			new:  ul(nil, li("c", "key", "3"), li("a", "key", "1"), li("b", "key", "2")),
+			want: `[{"op":"remove","path":[2]},` +
+				`{"index":0,"node":{"type":"tag","name":"li","attrs":[{"name":"key","value":"3"}],"children":[{"type":"text","values":["c"]}]},"op":"insert","path":[]}]`,
+		},
+		{
+			name: "keyed removal keeps siblings",
+			old:  ul(nil, li("a", "key", "1"), li("b", "key", "2")),
+			new:  ul(nil, li("b", "key", "2")),
+			want: `[{"op":"remove","path":[0]}]`,
+		},
+		{
+			name: "replace on name change",
+			old:  ul(nil, li("a")),
+			new:  ul(nil, NewTag(nil, "p", nil, nil)),
+			want: `[{"node":{"type":"tag","name":"p"},"op":"replace","path":[0]}]`,
+		},
+		{
+			name: "fragments are transparent",
+			old:  ul(nil, NewTag(nil, "", []Element{li("a"), li("b")}, nil)),
+			new:  ul(nil, li("a"), li("B")),
+			want: `[{"op":"setText","path":[1,0],"value":"B"}]`,
+		},
+		{
+			name: "adjacent texts are one node",
+			old: ul(nil, Text{gad.Str("a")}, NewTag(nil, "", []Element{Text{gad.Str("b")}}, nil),
+				li("x"), Text{gad.Str("c")}),
+			new: ul(nil, Text{gad.Str("a"), gad.Str("B")}, li("y"), Text{gad.Str("c")}),
+			want: `[{"op":"setText","path":[0],"value":"aB"},` +
+				`{"op":"setText","path":[1,0],"value":"y"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffJSON(t, tt.old, tt.new); got != tt.want {
+				t.Fatalf("\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,13 @@
 package giom
 
 import (
+	"fmt"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/gad-lang/gad"
-	giomnode "github.com/gad-lang/gad/giom/node"
 )
 
 // ElementType classifies the kind of a rendered Element.
@@ -64,12 +65,21 @@ type Tag struct {
 	// attrOrder preserves the insertion order of Attrs keys, since gad.Dict (a
 	// Go map) is unordered and attribute output order is significant.
 	attrOrder []string
+	// parent is the tag this one was linked into (constructor, append, insert
+	// or wrap), used by Detach and Wrap.
+	parent *Tag
+	// stack, push and once mark the anonymous fragments of @stack, @push and
+	// @once blocks (see ResolveStacks).
+	stack, push, once string
 }
 
 // NewTag returns a tag with the given name and children, classifying attrs into
 // the tag's structured attribute state (regular attributes, class list, styles).
 func NewTag(parent *Tag, name string, children []Element, attrs gad.KeyValueArray) *Tag {
-	t := &Tag{Name: name, Children: children}
+	t := &Tag{Name: name, parent: parent}
+	for _, c := range children {
+		t.Children = append(t.Children, t.adopt(c))
+	}
 	if parent != nil {
 		parent.Children = append(parent.Children, t)
 	}
@@ -144,7 +154,7 @@ func (t *Tag) append(child gad.Object) {
 	if child == nil || child == gad.Nil {
 		return
 	}
-	t.Children = append(t.Children, toElement(child))
+	t.Children = append(t.Children, t.adopt(toElement(child)))
 }
 
 // appendMany adds each element of an iterable value as a child.
@@ -189,9 +199,13 @@ func (t *Tag) SelfAssignOpInc(vm *gad.VM, value gad.Object) (gad.Object, error)
 }
 
 // IndexGet implements `tag.attrs` (the attribute collection as a KeyValueArray),
-// `tag.name`, `tag.children` and single attribute reads `tag[name]`.
+// `tag.name`, `tag.children`, single attribute reads `tag[name]`, and
+// `tag.parent` and the tree methods (see tagMethod). An attribute shadows
+// `parent` or a method of the same name, so that `tag.wrap` reads what
+// `tag["wrap"] = v` set.
 func (t *Tag) IndexGet(_ *gad.VM, index gad.Object) (gad.Object, error) {
-	switch index.ToString() {
+	name := index.ToString()
+	switch name {
 	case "attrs":
 		return t.attrsKeyValueArray(), nil
 	case "name":
@@ -202,12 +216,20 @@ func (t *Tag) IndexGet(_ *gad.VM, index gad.Object) (gad.Object, error) {
 			arr[i] = c
 		}
 		return arr, nil
-	default:
-		if v, ok := t.Attrs[index.ToString()]; ok {
-			return v, nil
+	}
+	if v, ok := t.Attrs[name]; ok {
+		return v, nil
+	}
+	if name == "parent" {
+		if t.parent == nil {
+			return gad.Nil, nil
 		}
-		return gad.Nil, nil
+		return t.parent, nil
+	}
+	if m := t.tagMethod(name); m != nil {
+		return m, nil
 	}
+	return gad.Nil, nil
 }
 
 // IndexSet implements `tag.attrs = kva` / `tag.attrs += kva` (re-classify the
@@ -230,10 +252,17 @@ func (t *Tag) IndexSet(_ *gad.VM, index, value gad.Object) error {
 // WriteTo renders the tag and its subtree as HTML. An anonymous tag (empty
 // Name) writes only its children; a named tag writes its open tag with rendered
 // attributes, then either self-closes (for void elements) or writes its
-// children and a close tag.
+// children and a close tag. Use Markup.Write for the other dialects. The tree
+// is written as it is: call ResolveStacks first on a tree built by running a
+// template directly.
 func (t *Tag) WriteTo(vm *gad.VM, w io.Writer) (n int64, err error) {
+	return t.writeMarkup(vm, w, Markup{})
+}
+
+// writeMarkup renders the tag and its subtree in markup m.
+func (t *Tag) writeMarkup(vm *gad.VM, w io.Writer, m Markup) (n int64, err error) {
 	if t.Name == "" {
-		return t.writeChildren(vm, w)
+		return t.writeChildren(vm, w, m)
 	}
 
 	var wc writeCounter
@@ -242,12 +271,17 @@ func (t *Tag) WriteTo(vm *gad.VM, w io.Writer) (n int64, err error) {
 		return wc.n, err
 	}
 
-	if err = t.writeAttrs(vm, w, &wc); err != nil {
+	if m.isXML() {
+		err = t.writeXMLAttrs(vm, w, &wc)
+	} else {
+		err = t.writeAttrs(vm, w, &wc)
+	}
+	if err != nil {
 		return wc.n, err
 	}
 
-	if giomnode.IsSelfClosing(t.Name) {
-		wc.writeString(w, " />")
+	if m.isVoid(t.Name) || (m.isXML() && len(t.Children) == 0) {
+		wc.writeString(w, m.selfClose())
 		return wc.n, wc.err
 	}
 
@@ -257,7 +291,7 @@ func (t *Tag) WriteTo(vm *gad.VM, w io.Writer) (n int64, err error) {
 	}
 
 	var cn int64
-	if cn, err = t.writeChildren(vm, w); err != nil {
+	if cn, err = t.writeChildren(vm, w, m); err != nil {
 		return wc.n + cn, err
 	}
 	wc.n += cn
@@ -266,10 +300,10 @@ func (t *Tag) WriteTo(vm *gad.VM, w io.Writer) (n int64, err error) {
 	return wc.n, wc.err
 }
 
-func (t *Tag) writeChildren(vm *gad.VM, w io.Writer) (n int64, err error) {
+func (t *Tag) writeChildren(vm *gad.VM, w io.Writer, m Markup) (n int64, err error) {
 	for _, c := range t.Children {
 		var cn int64
-		if cn, err = c.WriteTo(vm, w); err != nil {
+		if cn, err = writeWithMarkup(vm, w, c, m); err != nil {
 			return n + cn, err
 		}
 		n += cn
@@ -277,6 +311,261 @@ func (t *Tag) writeChildren(vm *gad.VM, w io.Writer) (n int64, err error) {
 	return n, nil
 }
 
+// =============================================================================
+// Tree manipulation
+// =============================================================================
+
+// Parent returns the tag t is linked into, or nil.
+func (t *Tag) Parent() *Tag { return t.parent }
+
+// adopt records t as the parent of a tag child and returns the child. A tag
+// linked into a tree is detached from its parent first, so that inserting it
+// moves it, as in the DOM.
+func (t *Tag) adopt(el Element) Element {
+	if ct, ok := el.(*Tag); ok {
+		ct.Detach()
+		ct.parent = t
+	}
+	return el
+}
+
+// release clears the parent of a removed tag child.
+func (t *Tag) release(el Element) {
+	if ct, ok := el.(*Tag); ok && ct.parent == t {
+		ct.parent = nil
+	}
+}
+
+// IndexOf returns the position of child among t's children, or -1. Tags match
+// by identity, text nodes by value.
+func (t *Tag) IndexOf(child Element) int {
+	for i, c := range t.Children {
+		if sameElement(c, child) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert inserts elements at child position i (0 ≤ i ≤ len(Children)). A tag
+// already in a tree moves: when it was a child of t before position i, i
+// counts the children without it.
+func (t *Tag) Insert(i int, elements ...Element) error {
+	if i < 0 || i > len(t.Children) {
+		return fmt.Errorf("giom: insert index %d out of range [0, %d]", i, len(t.Children))
+	}
+	for _, el := range elements {
+		if j := t.IndexOf(el); j >= 0 && j < i {
+			if _, ok := el.(*Tag); ok {
+				i--
+			}
+		}
+		t.adopt(el)
+	}
+	t.Children = slices.Insert(t.Children, i, elements...)
+	return nil
+}
+
+// Remove removes child from t, reporting whether it was found.
+func (t *Tag) Remove(child Element) bool {
+	return t.RemoveAt(t.IndexOf(child)) != nil
+}
+
+// RemoveAt removes and returns the child at position i, or nil if i is out of
+// range.
+func (t *Tag) RemoveAt(i int) Element {
+	if i < 0 || i >= len(t.Children) {
+		return nil
+	}
+	el := t.Children[i]
+	t.Children = slices.Delete(t.Children, i, i+1)
+	t.release(el)
+	return el
+}
+
+// Replace replaces child old with new, reporting whether old was found.
+func (t *Tag) Replace(old, new Element) bool {
+	if t.IndexOf(old) < 0 {
+		return false
+	}
+	if sameElement(old, new) {
+		return true
+	}
+	t.adopt(new)
+	i := t.IndexOf(old)
+	t.release(t.Children[i])
+	t.Children[i] = new
+	return true
+}
+
+// Detach removes t from its parent, if any.
+func (t *Tag) Detach() {
+	if t.parent != nil {
+		t.parent.Remove(t)
+		t.parent = nil
+	}
+}
+
+// Empty removes all children.
+func (t *Tag) Empty() {
+	for _, c := range t.Children {
+		t.release(c)
+	}
+	t.Children = nil
+}
+
+// Wrap encloses t in a new tag with the given name and attributes, which takes
+// t's place in its parent, and returns the wrapper.
+func (t *Tag) Wrap(name string, attrs gad.KeyValueArray) *Tag {
+	w := NewTag(nil, name, nil, attrs)
+	if p := t.parent; p != nil {
+		if i := p.IndexOf(t); i >= 0 {
+			p.Children[i] = w
+			w.parent = p
+		}
+		t.parent = nil
+	}
+	w.Children = []Element{w.adopt(t)}
+	return w
+}
+
+// Clone returns a deep copy of t, detached from any parent. Attribute values
+// are shared; text nodes are copied.
+func (t *Tag) Clone() *Tag {
+	c := &Tag{
+		Name:      t.Name,
+		ClassList: append([]string(nil), t.ClassList...),
+		Styles:    append([]string(nil), t.Styles...),
+		attrOrder: append([]string(nil), t.attrOrder...),
+		stack:     t.stack,
+		push:      t.push,
+		once:      t.once,
+	}
+	if t.Attrs != nil {
+		c.Attrs = make(gad.Dict, len(t.Attrs))
+		for k, v := range t.Attrs {
+			c.Attrs[k] = v
+		}
+	}
+	for _, el := range t.Children {
+		switch e := el.(type) {
+		case *Tag:
+			el = e.Clone()
+		case Text:
+			el = append(Text(nil), e...)
+		}
+		c.Children = append(c.Children, c.adopt(el))
+	}
+	return c
+}
+
+// tagMethod returns the Gad method name of t, or nil:
+//
+//	tag.remove(child)  tag.remove(i)     // remove a child (or index); yields a bool
+//	tag.insert(i, *elements)             // insert children at i; yields tag
+//	tag.replace(old, new)                // replace a child; yields a bool
+//	tag.clone()                          // deep copy
+//	tag.wrap(name; **attrs)              // wrap tag in place; yields the wrapper
+//	tag.detach()                         // remove tag from its parent; yields tag
+//	tag.empty()                          // remove all children; yields tag
+//	tag.merge(*attrs; **attrs)           // merge attribute collections; yields tag
+func (t *Tag) tagMethod(name string) *gad.Function {
+	var fn func(c gad.Call) (gad.Object, error)
+	switch name {
+	case "remove":
+		fn = func(c gad.Call) (gad.Object, error) {
+			if err := c.Args.CheckLen(1); err != nil {
+				return nil, err
+			}
+			if i, ok := c.Args.GetOnly(0).(gad.Int); ok {
+				return gad.Bool(t.RemoveAt(int(i)) != nil), nil
+			}
+			return gad.Bool(t.Remove(toElement(c.Args.GetOnly(0)))), nil
+		}
+	case "insert":
+		fn = func(c gad.Call) (gad.Object, error) {
+			if c.Args.Length() < 1 {
+				return nil, c.Args.CheckLen(1)
+			}
+			i, ok := c.Args.GetOnly(0).(gad.Int)
+			if !ok {
+				return nil, fmt.Errorf("giom.Tag.insert: invalid index %s", c.Args.GetOnly(0).ToString())
+			}
+			var els []Element
+			for j := 1; j < c.Args.Length(); j++ {
+				els = append(els, toElement(c.Args.Get(j)))
+			}
+			return t, t.Insert(int(i), els...)
+		}
+	case "replace":
+		fn = func(c gad.Call) (gad.Object, error) {
+			if err := c.Args.CheckLen(2); err != nil {
+				return nil, err
+			}
+			return gad.Bool(t.Replace(toElement(c.Args.GetOnly(0)), toElement(c.Args.GetOnly(1)))), nil
+		}
+	case "clone":
+		fn = func(gad.Call) (gad.Object, error) { return t.Clone(), nil }
+	case "wrap":
+		fn = func(c gad.Call) (gad.Object, error) {
+			if err := c.Args.CheckLen(1); err != nil {
+				return nil, err
+			}
+			return t.Wrap(c.Args.GetOnly(0).ToString(), c.NamedArgs.Join()), nil
+		}
+	case "detach":
+		fn = func(gad.Call) (gad.Object, error) {
+			t.Detach()
+			return t, nil
+		}
+	case "empty":
+		fn = func(gad.Call) (gad.Object, error) {
+			t.Empty()
+			return t, nil
+		}
+	case "merge":
+		fn = func(c gad.Call) (gad.Object, error) {
+			t.mergeArgs(c, 0)
+			return t, nil
+		}
+	default:
+		return nil
+	}
+	return &gad.Function{FuncName: "giom.Tag." + name, Module: ModuleSpec, Value: fn}
+}
+
+// mergeArgs merges the positional arguments of c from index from, attribute
+// collections or toggled ones (giom.toggle(cond, attrs)), then its named
+// arguments into t.
+func (t *Tag) mergeArgs(c gad.Call, from int) {
+	for i := from; i < c.Args.Length(); i++ {
+		v := c.Args.Get(i)
+		if kv, ok := v.(*gad.KeyValue); ok {
+			if kv.K.IsFalsy() {
+				continue
+			}
+			v = kv.V
+		}
+		t.Merge(toKeyValueArray(v))
+	}
+	t.Merge(c.NamedArgs.Join())
+}
+
+// sameElement reports whether a and b are the same child: tags by identity,
+// text nodes by value.
+func sameElement(a, b Element) bool {
+	switch at := a.(type) {
+	case *Tag:
+		bt, ok := b.(*Tag)
+		return ok && at == bt
+	case Text:
+		bt, ok := b.(Text)
+		return ok && at.Equal(bt)
+	default:
+		return a == b
+	}
+}
+
 // =============================================================================
 // Text
 // =============================================================================
@@ -376,9 +665,15 @@ func toKeyValueArray(value gad.Object) gad.KeyValueArray {
 	case *gad.NamedArgs:
 		return v.Join()
 	case gad.Dict:
+		// A dict has no order: its keys are sorted so the output is stable.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
 		arr := make(gad.KeyValueArray, 0, len(v))
-		for k, val := range v {
-			arr = append(arr, &gad.KeyValue{K: gad.Str(k), V: val})
+		for _, k := range keys {
+			arr = append(arr, &gad.KeyValue{K: gad.Str(k), V: v[k]})
 		}
 		return arr
 	default:
@@ -395,6 +690,10 @@ func (t *Tag) mergeAttrs(attrs gad.KeyValueArray) {
 	}
 }
 
+// Merge merges attrs into the tag like its constructor does: regular
+// attributes are set (later ones win), classes and styles are appended.
+func (t *Tag) Merge(attrs gad.KeyValueArray) { t.mergeAttrs(attrs) }
+
 // mergeAttr applies the outer giom.attrs entry handling: a false Bool key skips
 // the entry, a true Bool key unwraps to its value, and Array / KeyValue /
 // KeyValueArray values are flattened into individual attribute pairs.
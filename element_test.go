@@ -92,3 +92,77 @@ func TestTagOperators(t *testing.T) {
 		t.Fatalf("attrs\n got: %s\nwant: %s", got, want)
 	}
 }
+
+func TestTagTreeManipulation(t *testing.T) {
+	li := func(s string) *Tag { return NewTag(nil, "li", []Element{Text{gad.RawStr(s)}}, nil) }
+
+	ul := NewTag(nil, "ul", nil, nil)
+	a, b := li("a"), li("b")
+	if err := ul.Insert(0, a, b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Parent() != ul {
+		t.Fatal("inserted child is not linked to its parent")
+	}
+	if err := ul.Insert(3, li("x")); err == nil {
+		t.Fatal("expected an out of range error")
+	}
+
+	c := ul.Clone()
+	if !ul.Replace(b, li("B")) || b.Parent() != nil {
+		t.Fatal("replace did not release the old child")
+	}
+	a.Detach()
+	if got, want := writeElement(t, ul), `<ul><li>B</li></ul>`; got != want {
+		t.Fatalf("original\n got: %s\nwant: %s", got, want)
+	}
+	if got, want := writeElement(t, c), `<ul><li>a</li><li>b</li></ul>`; got != want {
+		t.Fatalf("clone\n got: %s\nwant: %s", got, want)
+	}
+
+	w := c.Children[0].(*Tag).Wrap("b", nil)
+	if w.Parent() != c {
+		t.Fatal("wrapper does not take the wrapped tag's place")
+	}
+	if got, want := writeElement(t, c), `<ul><b><li>a</li></b><li>b</li></ul>`; got != want {
+		t.Fatalf("wrap\n got: %s\nwant: %s", got, want)
+	}
+	if c.RemoveAt(5) != nil || !c.Remove(w) {
+		t.Fatal("remove")
+	}
+	c.Empty()
+	if len(c.Children) != 0 {
+		t.Fatal("empty left children")
+	}
+}
+
+// TestTagInsertMoves verifies that inserting a tag already in a tree moves it
+// out of its old parent, as DOM insertion does.
+func TestTagInsertMoves(t *testing.T) {
+	li := func(s string) *Tag { return NewTag(nil, "li", []Element{Text{gad.RawStr(s)}}, nil) }
+	a, b, c := li("a"), li("b"), li("c")
+	ul := NewTag(nil, "ul", []Element{a, b, c}, nil)
+	ol := NewTag(nil, "ol", nil, nil)
+
+	if err := ol.Insert(0, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ul.Insert(2, a); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := writeElement(t, ul), `<ul><li>c</li><li>a</li></ul>`; got != want {
+		t.Fatalf("ul\n got: %s\nwant: %s", got, want)
+	}
+	if got, want := writeElement(t, ol), `<ol><li>b</li></ol>`; got != want {
+		t.Fatalf("ol\n got: %s\nwant: %s", got, want)
+	}
+
+	b.Detach()
+	if len(ol.Children) != 0 || b.Parent() != nil {
+		t.Fatal("detach left the moved tag in its new parent")
+	}
+	ul.Replace(c, a)
+	if got, want := writeElement(t, ul), `<ul><li>a</li></ul>`; got != want {
+		t.Fatalf("replace\n got: %s\nwant: %s", got, want)
+	}
+}
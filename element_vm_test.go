@@ -180,3 +180,75 @@ func TestTreeBuildBlocks(t *testing.T) {
 		})
 	}
 }
+
+// TestTreeManipulationMethods exercises the Gad-side tree methods of a tag.
+func TestTreeManipulationMethods(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "remove and insert",
+			src: `
+				tag := giom.Tag(nil)
+				ul := giom.Tag(tag, "ul")
+				a := giom.Tag(ul, "li", raw "a")
+				giom.Tag(ul, "li", raw "b")
+				ul.remove(a)
+				ul.insert(1, giom.Tag("li", raw "c"))
+				ul.insert(0, giom.Tag("li", raw "z"))
+				return tag`,
+			want: `<ul><li>z</li><li>b</li><li>c</li></ul>`,
+		},
+		{
+			name: "clone and wrap in place",
+			src: `
+				tag := giom.Tag(nil)
+				p := giom.Tag(tag, "p", raw "hi"; class="x")
+				tag += p.clone()
+				p.wrap("div"; id="w")
+				return tag`,
+			want: `<div id="w"><p class="x">hi</p></div><p class="x">hi</p>`,
+		},
+		{
+			name: "empty, detach and replace",
+			src: `
+				tag := giom.Tag(nil)
+				d := giom.Tag(tag, "div", raw "x")
+				s := giom.Tag(tag, "span")
+				b := giom.Tag(tag, "b")
+				d.empty()
+				s.detach()
+				tag.replace(b, giom.Tag("i"))
+				return tag`,
+			want: `<div></div><i></i>`,
+		},
+		{
+			name: "attributes shadow methods",
+			src: `
+				tag := giom.Tag(nil)
+				t := giom.Tag(tag, "textarea"; wrap="soft")
+				giom.Tag(tag, "b", t.wrap)
+				return tag`,
+			want: `<textarea wrap="soft"></textarea><b>soft</b>`,
+		},
+		{
+			name: "appending an attached tag moves it",
+			src: `
+				tag := giom.Tag(nil)
+				a := giom.Tag(tag, "div"; id="a")
+				x := giom.Tag(a, "i")
+				tag += x
+				return tag`,
+			want: `<div id="a"></div><i></i>`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runGadReturningTag(t, tc.src); got != tc.want {
+				t.Fatalf("mismatch\n got: %s\nwant: %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package giom
+
+import (
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedBlocks(t *testing.T) {
+	globals := gad.Dict{
+		"name":  gad.Str(`</script><b>"Ann"`),
+		"items": gad.Array{gad.Int(1), gad.Str("a")},
+		"color": gad.Str("red;}</style>"),
+		"width": gad.Int(10),
+	}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "script",
+			src: "@global name, items\n" +
+				"@main\n" +
+				"    :script\n" +
+				"        const user = {= name};\n" +
+				"        if (a < b) { go({= items}, \"{x}\") }\n",
+			want: `<script>const user = "\u003c/script\u003e\u003cb\u003e\"Ann\"";` + "\n" +
+				`if (a < b) { go([1,"a"], "{x}") }</script>`,
+		},
+		{
+			name: "inline css alias",
+			src: "@global color, width\n" +
+				"@main\n" +
+				"    :css .a { color: {= color}; width: {= width}px }\n",
+			want: `<style>.a { color: red\3b \7d \3c \2f style\3e ; width: 10px }</style>`,
+		},
+		{
+			name: "raw string is trusted",
+			src:  "@main\n    :js f({= giom.escape(\"<b>\")})\n",
+			want: `<script>f(<b>)</script>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := portRun(t, tt.src, globals, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package giom
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gad-lang/gad/parser/source"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+// formatIndent is the indentation of one level in formatted source.
+const formatIndent = "    "
+
+// FormatSource returns the canonical form of Giom source, like gofmt does for
+// Go:
+//
+//   - blocks are indented with four spaces, and runs of blank lines between
+//     statements become one blank line;
+//   - tag heads are written in the shorthand `name#id.class[attr=value]`,
+//     with a single line of text on the tag line (`a.btn[href=x] text`);
+//   - `~~` Gad blocks are formatted, unless they hold comments;
+//   - comments, directive lines and text are kept as written.
+//
+// Formatting formatted source returns it unchanged. The source must parse;
+// the result is parsed again before it is returned.
+func FormatSource(src []byte) ([]byte, error) {
+	fileSet := source.NewFileSet()
+	file := fileSet.AddFileData("", -1, src)
+	parsed, err := giomparser.NewParser(file).ParseFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	ctx := giomnode.NewGiomCodeContext(&buf)
+	ctx.Prefix = formatIndent
+	ctx.Source = file
+	parsed.WriteGiom(ctx)
+
+	if _, err = parseSource("", buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("format: the formatted source does not parse: %w", err)
+	}
+	return buf.Bytes(), nil
+}
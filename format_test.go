@@ -0,0 +1,87 @@
+package giom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"indentation", "div\n  p\n    span x\n", "div\n    p\n        span x\n"},
+		{"inline text", "a.btn[href=\"/\"]\n\t| Go\n", "a.btn[href=\"/\"] Go\n"},
+		{"piped text kept", "p\n    | - item\n    |+ more\n", "p\n    | - item\n    |+ more\n"},
+		{"attribute shorthand", "div[id=\"top\"][class=\"a\"] hi\n", "div#top.a hi\n"},
+		{"inline nest", "li: a[href=\"/\"]\n  | Home\n", "li: a[href=\"/\"] Home\n"},
+		{"blank lines", "p a\n\n\n\np b\n\n", "p a\n\np b\n"},
+		{"comments", "// header\ndiv\n  //- note\n  p x\n", "// header\ndiv\n    //- note\n    p x\n"},
+		{"directives", "@if len(items) > 0\n  ul\n@else if x\n  p x\n@else\n  p none\n",
+			"@if len(items) > 0\n    ul\n@else if x\n    p x\n@else\n    p none\n"},
+		{"code line", "@main\n  ~ title := \"x\"  // kept\n  h1 {= title}\n",
+			"@main\n    ~ title := \"x\"  // kept\n    h1 {= title}\n"},
+		{"code block with comments", "~~\n        // sum\n        a := 1\n          b := a\n~~\n",
+			"~~\n    // sum\n    a := 1\n      b := a\n~~\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatSource([]byte(tt.src))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+
+			again, err := FormatSource(got)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(again), "not idempotent")
+		})
+	}
+}
+
+func TestFormatSourceCodeBlock(t *testing.T) {
+	got, err := FormatSource([]byte("@main\n  ~~\n    x:=1\n    y:=x+2\n  ~~\n  p {= y}\n"))
+	require.NoError(t, err)
+	require.Contains(t, string(got), "@main\n    ~~\n        x := 1\n        y := x + 2\n    ~~\n    p {= y}\n")
+
+	// Slashes in strings are not comments: the block is still formatted.
+	got, err = FormatSource([]byte("~~\n  u:=\"http://x\"\n  v:=u+\"/*\"\n~~\n"))
+	require.NoError(t, err)
+	require.Equal(t, "~~\n    u := \"http://x\"\n    v := u + \"/*\"\n~~\n", string(got))
+
+	again, err := FormatSource(got)
+	require.NoError(t, err)
+	require.Equal(t, string(got), string(again))
+}
+
+func TestFormatSourceRender(t *testing.T) {
+	src := "@export comp card(title; slots={})\n" +
+		"  div.card\n" +
+		"    h2\n" +
+		"      | {= title}\n" +
+		"    @slot main\n" +
+		"\n" +
+		"@main\n" +
+		"  +card(\"A\")\n" +
+		"    ~ n := 2\n" +
+		"    p\n" +
+		"      | count {= n}\n" +
+		"  @for i in [1, 2]\n" +
+		"    span {= i}\n" +
+		"  @else\n" +
+		"    | none\n"
+	got, err := FormatSource([]byte(src))
+	require.NoError(t, err)
+	require.Contains(t, string(got), "        h2 {= title}\n        @slot main\n\n@main\n    +card(\"A\")\n        ~ n := 2\n        p count {= n}\n")
+
+	want, err := portRun(t, src, nil, nil)
+	require.NoError(t, err)
+	formatted, err := portRun(t, string(got), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, want, formatted)
+}
+
+func TestFormatSourceError(t *testing.T) {
+	_, err := FormatSource([]byte("@main\n    @else\n"))
+	require.ErrorContains(t, err, "unexpected ELSE without matching @if")
+}
@@ -0,0 +1,615 @@
+package giom
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gad-lang/gad/giom/internal/htmltree"
+	giomnode "github.com/gad-lang/gad/giom/node"
+	"github.com/gad-lang/gad/parser/ast"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+)
+
+// FromHTML converts the HTML document read from r to Giom source, as an
+// HTMLConverter with the default options does.
+func FromHTML(r io.Reader) ([]byte, error) {
+	return (&HTMLConverter{}).Convert(r)
+}
+
+// HTMLConverter converts HTML, such as mockups or the output of html/template
+// pages, to idiomatic Giom source:
+//
+//   - elements become tag lines with `#id.class[name="value"]` heads, a single
+//     inline child nests as `li: a[href="/"] Home` and text goes on the tag
+//     line or on `|` text lines;
+//   - text mixing with inline elements becomes one text line with `#[tag …]`
+//     spans, and the spaces between inline elements become whitespace
+//     modifiers (`a>`) or `|+` lines;
+//   - the doctype becomes `!!!`, comments `//` lines, script and style
+//     elements `:script` and `:style` blocks, and pre and textarea content
+//     `@verbatim` blocks.
+//
+// The converted nodes are written by the GiomCoder writer FormatSource uses,
+// so the source is in canonical form. Text whitespace is collapsed as
+// browsers render it, outside pre and textarea.
+type HTMLConverter struct {
+	// RawInline keeps complex inline markup, the content of an element that
+	// mixes text with inline elements holding further elements, as a raw HTML
+	// region (`<>…</>`) under the element instead of nested spans or lines.
+	RawInline bool
+}
+
+// Convert converts the HTML document read from r to Giom source.
+func (c *HTMLConverter) Convert(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// HTML reads a CRLF line break as LF.
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+	b := &htmlBuilder{raw: c.RawInline}
+	stmts := b.nodes(htmltree.Parse(src).Children)
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	var buf bytes.Buffer
+	ctx := giomnode.NewGiomCodeContext(&buf)
+	ctx.Prefix = formatIndent
+	ctx.WriteStmts(stmts)
+
+	if _, err = parseSource("", buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("convert HTML: the giom source does not parse: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	// htmlInline are the text-level elements, which flow with the text
+	// around them.
+	htmlInline = htmlSet("a abbr b bdi bdo br button cite code data del dfn em i img input ins kbd label mark q s samp small span strong sub sup time u var wbr")
+	// htmlPreformatted elements keep the whitespace of their content.
+	htmlPreformatted = htmlSet("pre script style textarea")
+)
+
+func htmlSet(names string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range strings.Fields(names) {
+		set[name] = true
+	}
+	return set
+}
+
+// htmlBuilder builds the giom nodes of HTML nodes, which the GiomCoder
+// writer then writes without a source: in canonical form.
+type htmlBuilder struct {
+	raw bool
+	err error
+}
+
+func (b *htmlBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// htmlTextPiece is a piece of text content, already escaped for a giom text
+// line, or a span's text; the writer writes it as its String.
+type htmlTextPiece struct {
+	ast.NodeData
+	text string
+}
+
+func (p *htmlTextPiece) Pos() source.Pos { return 0 }
+func (p *htmlTextPiece) End() source.Pos { return 0 }
+func (p *htmlTextPiece) StmtNode()       {}
+func (p *htmlTextPiece) String() string  { return p.text }
+
+// WriteCode writes nothing: the converted nodes are only written as giom
+// source.
+func (p *htmlTextPiece) WriteCode(*gnode.CodeWriteContext) {}
+
+// htmlRegion is the raw HTML region (`<>…</>`) of a RawInline conversion. A
+// parsed region is a HtmlStmt, which needs its source to be written back.
+type htmlRegion struct {
+	ast.NodeData
+	html string
+}
+
+func (r *htmlRegion) Pos() source.Pos                   { return 0 }
+func (r *htmlRegion) End() source.Pos                   { return 0 }
+func (r *htmlRegion) StmtNode()                         {}
+func (r *htmlRegion) String() string                    { return "<>" + r.html + "</>" }
+func (r *htmlRegion) WriteCode(*gnode.CodeWriteContext) {}
+
+func (r *htmlRegion) WriteGiom(ctx *giomnode.GiomCodeWriteContext) {
+	ctx.WriteLine(r.String())
+}
+
+// content returns the children of n with their text collapsed, dropping
+// empty text and, when text mixes with them, comments.
+func content(n *htmltree.Node) (kids []*htmltree.Node, mixed bool) {
+	hasText, hasElement := false, false
+	for _, c := range n.Children {
+		switch c.Kind {
+		case htmltree.Text:
+			hasText = hasText || strings.TrimSpace(htmltree.Collapse(c.Data)) != ""
+		case htmltree.Element:
+			hasElement = true
+		}
+	}
+	for _, c := range n.Children {
+		switch {
+		case c.Kind == htmltree.Comment && hasText:
+			continue
+		case c.Kind == htmltree.Text:
+			data := htmltree.Collapse(c.Data)
+			if data == "" {
+				continue
+			}
+			if k := len(kids) - 1; k >= 0 && kids[k].Kind == htmltree.Text {
+				kids[k] = &htmltree.Node{Kind: htmltree.Text, Data: htmltree.Collapse(kids[k].Data + data)}
+				continue
+			}
+			c = &htmltree.Node{Kind: htmltree.Text, Data: data}
+		}
+		kids = append(kids, c)
+	}
+	return kids, hasText && hasElement
+}
+
+// nodes returns the statements of the children of an element.
+func (b *htmlBuilder) nodes(children []*htmltree.Node) gnode.Stmts {
+	kids, _ := content(&htmltree.Node{Children: children})
+	return b.lines(kids)
+}
+
+// lines returns kids as one statement each; the spaces that separate inline
+// elements from their siblings become whitespace modifiers and `|+` lines.
+func (b *htmlBuilder) lines(kids []*htmltree.Node) gnode.Stmts {
+	inline := func(i int) bool {
+		return i >= 0 && i < len(kids) && (kids[i].Kind == htmltree.Text || htmlInline[kids[i].Lower()])
+	}
+	var stmts gnode.Stmts
+	before := map[int]bool{}
+	for i, n := range kids {
+		switch n.Kind {
+		case htmltree.Decl:
+			stmts = append(stmts, &giomnode.DoctypeStmt{Value: doctypeKey(n.Data)})
+		case htmltree.Comment:
+			stmts = append(stmts, &giomnode.CommentStmt{Text: strings.TrimSpace(htmltree.Collapse(n.Data))})
+		case htmltree.Text:
+			text := strings.TrimSpace(n.Data)
+			if text == "" {
+				// The space between two elements, written as a modifier.
+				continue
+			}
+			if strings.HasSuffix(n.Data, " ") && i+1 < len(kids) && inline(i+1) {
+				before[i+1] = true
+			}
+			stmts = append(stmts, &giomnode.TextStmt{
+				Stmts:  gnode.Stmts{&htmlTextPiece{text: giomText(text, false)}},
+				Marker: i > 0 && n.Data[0] == ' ' && inline(i-1),
+			})
+		case htmltree.Element:
+			modifier := ""
+			if before[i] {
+				modifier = "<"
+			}
+			if i+1 < len(kids) && kids[i+1].Kind == htmltree.Text && strings.TrimSpace(kids[i+1].Data) == "" &&
+				i+2 < len(kids) && inline(i) && inline(i+2) {
+				modifier += ">"
+			}
+			if stmt := b.element(n, modifier); stmt != nil {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	return stmts
+}
+
+// element returns the statement of n with the whitespace modifier.
+func (b *htmlBuilder) element(n *htmltree.Node, modifier string) gnode.Stmt {
+	tag, err := htmlTag(n)
+	if err != nil {
+		b.fail(err)
+		return nil
+	}
+	tag.Modifier = modifier
+	if name := n.Lower(); name == "script" || name == "style" {
+		return rawText(tag, n)
+	}
+	// A single inline child nests on the line: `li: a[href="/"] Home`. A
+	// modifier would apply to the child, and pre writes its own content.
+	last := tag
+	for modifier == "" && !htmlPreformatted[n.Lower()] {
+		kids, _ := content(n)
+		if len(kids) != 1 || kids[0].Kind != htmltree.Element || !htmlInline[kids[0].Lower()] || len(kids[0].Children) == 0 {
+			break
+		}
+		child, err := htmlTag(kids[0])
+		if err != nil {
+			break
+		}
+		child.Inline = true
+		last.Body = gnode.Stmts{child}
+		last, n = child, kids[0]
+	}
+	last.Body = b.body(last, n)
+	return tag
+}
+
+// body returns the content of n, the element of tag.
+func (b *htmlBuilder) body(tag *giomnode.TagStmt, n *htmltree.Node) gnode.Stmts {
+	switch n.Lower() {
+	case "pre", "textarea":
+		return preformatted(tag, n)
+	}
+	kids, mixed := content(n)
+	if !mixed {
+		return b.lines(kids)
+	}
+	pieces, nested, ok := spanPieces(kids)
+	switch {
+	case ok && !(b.raw && nested):
+		return gnode.Stmts{&giomnode.TextStmt{Stmts: pieces}}
+	case b.raw && rawHTMLOK(kids):
+		var s strings.Builder
+		for _, k := range kids {
+			writeRawHTML(&s, k)
+		}
+		return gnode.Stmts{&htmlRegion{html: s.String()}}
+	default:
+		return b.lines(kids)
+	}
+}
+
+// rawText returns the content of a script or style element as a `:script` or
+// `:style` block, or, when the element has attributes or the content holds
+// `{=`, tag with a `@verbatim raw` block.
+func rawText(tag *giomnode.TagStmt, n *htmltree.Node) gnode.Stmt {
+	if len(n.Children) == 0 {
+		return tag
+	}
+	lines := giomnode.Dedent(strings.Split(n.Children[0].Data, "\n"))
+	if len(lines) == 0 {
+		return tag
+	}
+	src := strings.Join(lines, "\n")
+	if len(n.Attrs) == 0 && !strings.Contains(src, "{=") && !strings.Contains(src, "\\\n") {
+		return &giomnode.EmbedStmt{Name: n.Lower(), Parts: []gnode.Expr{gnode.Str(src, 0)}}
+	}
+	tag.Body = verbatim(src, true)
+	return tag
+}
+
+// preformatted returns the content of pre and textarea, where whitespace is
+// significant, as a `@verbatim` block; a pre holding a single code element
+// writes as `pre: code`.
+func preformatted(tag *giomnode.TagStmt, n *htmltree.Node) gnode.Stmts {
+	var b strings.Builder
+	textOnly := true
+	for _, c := range n.Children {
+		switch c.Kind {
+		case htmltree.Text:
+			b.WriteString(c.Data)
+		case htmltree.Element:
+			textOnly = false
+		}
+	}
+	if textOnly {
+		// A newline right after the start tag is not content.
+		return verbatim(strings.TrimPrefix(b.String(), "\n"), false)
+	}
+	if len(n.Children) == 1 && n.Children[0].Lower() == "code" {
+		code, err := htmlTag(n.Children[0])
+		if err == nil {
+			codeText := true
+			for _, c := range n.Children[0].Children {
+				codeText = codeText && c.Kind == htmltree.Text
+			}
+			if codeText {
+				// A modifier of pre would apply to code in the chain.
+				code.Inline = tag.Modifier == ""
+				code.Body = preformatted(code, n.Children[0])
+				return gnode.Stmts{code}
+			}
+		}
+	}
+	var raw strings.Builder
+	for _, c := range n.Children {
+		writeExactHTML(&raw, c)
+	}
+	return verbatim(strings.TrimPrefix(raw.String(), "\n"), true)
+}
+
+// verbatim returns text as a `@verbatim` block, or `@verbatim raw` when
+// isHTML or when the block could not keep the text: its first line indented,
+// or a line ending in `\`. Trailing blank lines are dropped.
+func verbatim(text string, isHTML bool) gnode.Stmts {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	keep := isHTML
+	for i, line := range lines {
+		keep = keep || i == 0 && strings.TrimLeft(line, " \t") != line || strings.HasSuffix(line, "\\")
+	}
+	if keep && !isHTML {
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		isHTML = true
+	}
+	if isHTML {
+		// Entities keep what the block syntax would drop.
+		lead := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+		lines[0] = strings.NewReplacer(" ", "&#32;", "\t", "&#9;").Replace(lead) + lines[0][len(lead):]
+		for i, line := range lines {
+			if strings.HasSuffix(line, "\\") {
+				lines[i] = line[:len(line)-1] + "&#92;"
+			}
+		}
+	}
+	return gnode.Stmts{&giomnode.VerbatimStmt{Text: strings.Join(lines, "\n"), Raw: isHTML}}
+}
+
+// spanPieces returns kids as the pieces of one text line, with the inline
+// elements as `#[tag …]` spans, and whether a span holds another element; ok
+// is false when an element cannot be a span.
+func spanPieces(kids []*htmltree.Node) (pieces gnode.Stmts, nested, ok bool) {
+	for _, k := range kids {
+		switch k.Kind {
+		case htmltree.Text:
+			pieces = append(pieces, &htmlTextPiece{text: giomText(k.Data, false)})
+		case htmltree.Element:
+			span, inner, ok := htmlSpan(k)
+			if !ok {
+				return nil, false, false
+			}
+			nested = nested || inner
+			pieces = append(pieces, span)
+		}
+	}
+	return trimPieces(pieces), nested, true
+}
+
+// htmlSpan returns the span of the inline element n, and whether it holds
+// another element.
+func htmlSpan(n *htmltree.Node) (span *giomnode.TagStmt, nested, ok bool) {
+	if !htmlInline[n.Lower()] {
+		return nil, false, false
+	}
+	span, err := htmlTag(n)
+	if err != nil {
+		return nil, false, false
+	}
+	var pieces gnode.Stmts
+	for _, c := range n.Children {
+		switch c.Kind {
+		case htmltree.Text:
+			pieces = append(pieces, &htmlTextPiece{text: giomText(htmltree.Collapse(c.Data), true)})
+		case htmltree.Element:
+			inner, _, ok := htmlSpan(c)
+			if !ok {
+				return nil, false, false
+			}
+			nested = true
+			pieces = append(pieces, inner)
+		}
+	}
+	if pieces = trimPieces(pieces); len(pieces) > 0 {
+		if p, ok := pieces[0].(*htmlTextPiece); ok {
+			p.text = guardTextStart(p.text)
+		}
+		span.Body = gnode.Stmts{&giomnode.TextStmt{Stmts: pieces}}
+	}
+	return span, nested, true
+}
+
+// trimPieces trims the space at both ends of a line of pieces, dropping the
+// text pieces it empties.
+func trimPieces(pieces gnode.Stmts) gnode.Stmts {
+	if len(pieces) > 0 {
+		if p, ok := pieces[0].(*htmlTextPiece); ok {
+			if p.text = strings.TrimLeftFunc(p.text, unicode.IsSpace); p.text == "" {
+				pieces = pieces[1:]
+			}
+		}
+	}
+	if len(pieces) > 0 {
+		if p, ok := pieces[len(pieces)-1].(*htmlTextPiece); ok {
+			if p.text = strings.TrimRightFunc(p.text, unicode.IsSpace); p.text == "" {
+				pieces = pieces[:len(pieces)-1]
+			}
+		}
+	}
+	return pieces
+}
+
+// guardTextStart writes the first character of text after a tag head as an
+// interpolation when it could read as part of the head.
+func guardTextStart(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '{' || r == '#' && strings.HasPrefix(text, "#[") {
+		return text
+	}
+	return "{" + strconv.Quote(string(r)) + "}" + text[size:]
+}
+
+// giomText escapes HTML text for a giom text line: `{` and `#[` would start an
+// interpolation or a span, a final `\` would continue the line and, in a span,
+// brackets would end it.
+func giomText(s string, span bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{':
+			b.WriteString(`{"{"}`)
+		case c == '#' && i+1 < len(s) && s[i+1] == '[':
+			b.WriteString(`#{"["}`)
+			i++
+		case span && (c == '[' || c == ']'):
+			b.WriteString(`{"` + string(c) + `"}`)
+		case c == '\\' && strings.TrimRight(s[i+1:], " ") == "":
+			b.WriteString(`{"\\"}`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var (
+	// rgxGiomTag matches the element names a tag line can hold.
+	rgxGiomTag = regexp.MustCompile(`^\w(?:[-:/\w]*[-/\w])?$`)
+	// rgxGiomShorthand matches the ids and classes written as `#id` and
+	// `.class`.
+	rgxGiomShorthand = regexp.MustCompile(`^[\w-]+$`)
+	// rgxGiomAttr matches the attribute names an attribute group can hold.
+	rgxGiomAttr = regexp.MustCompile(`^[\w:@.-]+$`)
+)
+
+// htmlTag returns the giom tag of the element n, without its content: its
+// id and classes in shorthand, then its other attributes in order.
+func htmlTag(n *htmltree.Node) (*giomnode.TagStmt, error) {
+	if !rgxGiomTag.MatchString(n.Name) {
+		return nil, fmt.Errorf("convert HTML: element name %q cannot be written in giom", n.Name)
+	}
+	var id, classes, attrs []*giomnode.TagAttribute
+	for _, a := range n.Attrs {
+		name, value := a[0], a[1]
+		if !rgxGiomAttr.MatchString(name) {
+			return nil, fmt.Errorf("convert HTML: attribute name %q of %s cannot be written in giom", name, n.Name)
+		}
+		switch {
+		case strings.EqualFold(name, "id") && rgxGiomShorthand.MatchString(value):
+			id = append(id, htmlAttr("id", value))
+			continue
+		case strings.EqualFold(name, "class"):
+			var rest []string
+			for _, class := range strings.Fields(value) {
+				if rgxGiomShorthand.MatchString(class) {
+					classes = append(classes, htmlAttr("class", class))
+				} else {
+					rest = append(rest, class)
+				}
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			value = strings.Join(rest, " ")
+		}
+		attrs = append(attrs, htmlAttr(name, value))
+	}
+	return &giomnode.TagStmt{Name: n.Name, Attributes: append(append(id, classes...), attrs...)}, nil
+}
+
+// htmlAttr returns the attribute name="value": a flag when the value is
+// empty, else a raw `"…"` string, or a Gad string when the value holds quotes
+// or line breaks.
+func htmlAttr(name, value string) *giomnode.TagAttribute {
+	if value == "" {
+		return &giomnode.TagAttribute{Name: name, IsRaw: true, IsFlag: true}
+	}
+	return &giomnode.TagAttribute{Name: name, Value: gnode.Str(value, 0), IsRaw: !strings.ContainsAny(value, "\"\n\r")}
+}
+
+// doctypeKey returns the `!!!` key of a declaration.
+func doctypeKey(decl string) string {
+	lower := strings.ToLower(decl)
+	switch {
+	case lower == "<!doctype html>":
+		return "5"
+	case strings.HasPrefix(lower, "<?xml"):
+		return "xml"
+	case strings.HasPrefix(lower, "<!doctype "):
+		return strings.TrimSuffix(decl[len("<!doctype "):], ">")
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(decl, "<!"), ">")
+}
+
+// rawHTMLOK reports whether kids can be written as a raw HTML region: one
+// without whitespace-sensitive or raw text elements, which the region would
+// collapse or misread.
+func rawHTMLOK(kids []*htmltree.Node) bool {
+	for _, k := range kids {
+		if k.Kind != htmltree.Element {
+			continue
+		}
+		switch k.Lower() {
+		case "pre", "textarea", "script", "style":
+			return false
+		}
+		if !rgxGiomTag.MatchString(k.Name) || !rawHTMLOK(k.Children) {
+			return false
+		}
+		for _, a := range k.Attrs {
+			if strings.ContainsAny(a[0], "{}\"'<>") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var rawHTMLEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "{", "&#123;")
+
+// writeRawHTML writes n as the markup of a raw HTML region, where `{` would
+// start an interpolation and comments are not allowed.
+func writeRawHTML(b *strings.Builder, n *htmltree.Node) {
+	switch n.Kind {
+	case htmltree.Text:
+		b.WriteString(rawHTMLEscaper.Replace(htmltree.Collapse(n.Data)))
+	case htmltree.Element:
+		b.WriteString("<" + n.Name)
+		for _, a := range n.Attrs {
+			b.WriteString(" " + a[0])
+			if a[1] != "" {
+				b.WriteString(`="` + rawHTMLEscaper.Replace(a[1]) + `"`)
+			}
+		}
+		b.WriteString(">")
+		if htmltree.Void[n.Lower()] {
+			return
+		}
+		for _, c := range n.Children {
+			writeRawHTML(b, c)
+		}
+		b.WriteString("</" + n.Name + ">")
+	}
+}
+
+// writeExactHTML writes n as HTML keeping its whitespace, for `@verbatim raw`.
+func writeExactHTML(b *strings.Builder, n *htmltree.Node) {
+	switch n.Kind {
+	case htmltree.Text:
+		b.WriteString(html.EscapeString(n.Data))
+	case htmltree.Comment:
+		b.WriteString("<!--" + n.Data + "-->")
+	case htmltree.Element:
+		b.WriteString("<" + n.Name)
+		for _, a := range n.Attrs {
+			b.WriteString(" " + a[0])
+			if a[1] != "" {
+				b.WriteString(`="` + html.EscapeString(a[1]) + `"`)
+			}
+		}
+		b.WriteString(">")
+		if htmltree.Void[n.Lower()] {
+			return
+		}
+		for _, c := range n.Children {
+			writeExactHTML(b, c)
+		}
+		b.WriteString("</" + n.Name + ">")
+	}
+}
@@ -0,0 +1,61 @@
+package giom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"doctype", "<!DOCTYPE html>\n<html></html>", "!!! 5\nhtml\n"},
+		{"shorthand", `<div id="top" class="a b x:y" data-n="1" hidden>hi</div>`,
+			"div#top.a.b[class=\"x:y\"][data-n=\"1\"][hidden] hi\n"},
+		{"quoted attribute", `<a title='say "hi"'>x</a>`, "a[title=(\"say \\\"hi\\\"\")] x\n"},
+		{"inline nest", `<ul><li><a href="/">Home</a></li></ul>`, "ul\n    li: a[href=\"/\"] Home\n"},
+		{"spans", "<p>Hello <b>big</b>  world</p>", "p Hello #[b big] world\n"},
+		{"escaped text", "<p>{x} #[y]</p>", "p {\"{\"}x} #{\"[\"}y]\n"},
+		{"inline spaces", "<div><span>a</span> <span>b</span></div>", "div\n    span> a\n    span b\n"},
+		{"comment", "<!-- nav -->\n<nav></nav>", "// nav\nnav\n"},
+		{"unclosed comment", "<nav></nav><!-- end of page", "nav\n// end of page\n"},
+		{"empty unclosed comment", "<!--", "//\n"},
+		{"style", "<style>\n  p { color: red; }\n</style>", ":style\n    p { color: red; }\n"},
+		{"pre", "<pre><code>a := 1\n  b</code></pre>", "pre: code\n    @verbatim\n        a := 1\n          b\n"},
+		{"void", `<p><img src="a.png" alt=""><br></p>`, "p\n    img[src=\"a.png\"][alt]\n    br\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromHTML(strings.NewReader(tt.html))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+
+			formatted, err := FormatSource(got)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(formatted))
+		})
+	}
+}
+
+func TestFromHTMLRawInline(t *testing.T) {
+	src := `<p>See <a href="/docs"><b>the</b> docs</a>.</p>`
+
+	got, err := FromHTML(strings.NewReader(src))
+	require.NoError(t, err)
+	require.Equal(t, "p See #[a[href=\"/docs\"] #[b the] docs].\n", string(got))
+
+	got, err = (&HTMLConverter{RawInline: true}).Convert(strings.NewReader(src))
+	require.NoError(t, err)
+	require.Equal(t, "p\n    <>See <a href=\"/docs\"><b>the</b> docs</a>.</>\n", string(got))
+}
+
+func TestFromHTMLRender(t *testing.T) {
+	src := `<div id="main" class="card"><h1>Title</h1><p>Some <em>text</em> &amp; more.</p><ul><li><a href="/a">A</a></li><li><a href="/b">B</a></li></ul></div>`
+	got, err := FromHTML(strings.NewReader(src))
+	require.NoError(t, err)
+	require.Equal(t, src, renderGiom(t, string(got), nil))
+}
@@ -0,0 +1,147 @@
+package giomtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around a change.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+	// at is the index of the line in want, or in got for a '+' line.
+	at int
+}
+
+// lineDiff returns the changes from want to got as hunks of '-', '+' and
+// context lines. Each hunk is headed by its line numbers and the path of the
+// elements enclosing its first change.
+func lineDiff(want, got []string) string {
+	ops := diffLines(want, got)
+	var b strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk while changes are within 2*diffContext lines.
+		start, end := max(i-diffContext, 0), i
+		for k := i; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		first := ops[i]
+		lines := want
+		if first.kind == '+' {
+			lines = got
+		}
+		wantLine, gotLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				wantLine++
+			}
+			if op.kind != '-' {
+				gotLine++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d +%d @@", wantLine, gotLine)
+		if path := elementPath(lines, first.at); path != "" {
+			b.WriteString(" " + path)
+		}
+		b.WriteByte('\n')
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(" " + op.line + "\n")
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// diffLines returns the edit script from want to got by longest common
+// subsequence, after trimming their common prefix and suffix.
+func diffLines(want, got []string) []diffOp {
+	var ops []diffOp
+	p := 0
+	for p < len(want) && p < len(got) && want[p] == got[p] {
+		ops = append(ops, diffOp{' ', want[p], p})
+		p++
+	}
+	s := 0
+	for s < len(want)-p && s < len(got)-p && want[len(want)-1-s] == got[len(got)-1-s] {
+		s++
+	}
+	w, g := want[p:len(want)-s], got[p:len(got)-s]
+
+	// lcs[i][j] is the length of the LCS of w[i:] and g[j:].
+	lcs := make([][]int, len(w)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(g)+1)
+	}
+	for i := len(w) - 1; i >= 0; i-- {
+		for j := len(g) - 1; j >= 0; j-- {
+			if w[i] == g[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(w) || j < len(g) {
+		switch {
+		case i < len(w) && j < len(g) && w[i] == g[j]:
+			ops = append(ops, diffOp{' ', w[i], p + i})
+			i++
+			j++
+		case i < len(w) && (j == len(g) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', w[i], p + i})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', g[j], p + j})
+			j++
+		}
+	}
+	for k := len(want) - s; k < len(want); k++ {
+		ops = append(ops, diffOp{' ', want[k], k})
+	}
+	return ops
+}
+
+// elementPath returns the names of the elements enclosing line i of a
+// normalised document, e.g. "html > body > ul".
+func elementPath(lines []string, i int) string {
+	if i >= len(lines) {
+		return ""
+	}
+	var path []string
+	depth := indentOf(lines[i])
+	for k := i - 1; k >= 0 && depth > 0; k-- {
+		d := indentOf(lines[k])
+		if d >= depth {
+			continue
+		}
+		depth = d
+		tag := strings.TrimSpace(lines[k])
+		if name, _, ok := strings.Cut(strings.TrimPrefix(tag, "<"), ">"); ok && strings.HasPrefix(tag, "<") {
+			name, _, _ = strings.Cut(name, " ")
+			path = append([]string{name}, path...)
+		}
+	}
+	return strings.Join(path, " > ")
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,107 @@
+// Package giomtest is a test harness for Giom templates. Render renders a
+// template source, Equal compares HTML ignoring attribute order and
+// insignificant whitespace, and Golden compares output with a golden file under
+// testdata that `go test -update` rewrites:
+//
+//	func TestPage(t *testing.T) {
+//		out := giomtest.Render(t, src, gad.Dict{"Model": model})
+//		giomtest.Golden(t, "page", out) // testdata/page.golden.html
+//	}
+//
+// A failed comparison reports a line diff of both documents in their
+// normalised form (see Normalize), headed by the path of the enclosing
+// elements.
+package giomtest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gad-lang/gad"
+
+	"github.com/gad-lang/gad/giom"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of giomtest.Golden")
+
+// Cover, when set, e.g. by TestMain, collects the coverage of the templates
+// Render and RenderFile compile.
+var Cover *giom.Cover
+
+// Render writes src to a temporary main.giom and renders it with globals. A
+// template that does not compile or run fails the test.
+func Render(t testing.TB, src string, globals gad.Dict) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.giom")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return RenderFile(t, path, globals)
+}
+
+// RenderFile renders the template at path with globals; its `@import` paths
+// are relative to its directory. A template that does not compile or run
+// fails the test.
+func RenderFile(t testing.TB, path string, globals gad.Dict) string {
+	t.Helper()
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := giom.NewRender(filepath.Dir(abs))
+	r.Cover = Cover
+	var out bytes.Buffer
+	if err := r.Render(&out, abs, globals); err != nil {
+		t.Fatalf("render %s: %v", path, err)
+	}
+	return out.String()
+}
+
+// Equal fails the test when the HTML got is not equivalent to want.
+func Equal(t testing.TB, want, got string) {
+	t.Helper()
+	if d := Diff(want, got); d != "" {
+		t.Errorf("HTML differs (-want +got):\n%s", d)
+	}
+}
+
+// Golden compares got with testdata/<name>.golden.html, as Equal does. With
+// `go test -update` it writes got to the file instead, in its normalised form
+// so that golden files read well in reviews.
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", filepath.FromSlash(name)+".golden.html")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(Normalize(got)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("%s does not exist; run `go test -update` to write it", path)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(string(want), got); d != "" {
+		t.Errorf("%s differs (-want +got):\n%s\nrun `go test -update` to accept the new output", path, d)
+	}
+}
+
+// Diff returns a line diff of the normalised forms of want and got, or "" when
+// they are equivalent.
+func Diff(want, got string) string {
+	w, g := Normalize(want), Normalize(got)
+	if w == g {
+		return ""
+	}
+	return lineDiff(splitLines(w), splitLines(g))
+}
@@ -0,0 +1,60 @@
+package giomtest
+
+import (
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"attribute order", `<a title="go" href="/x">link</a>`, "<a href=\"/x\" title=\"go\">link</a>\n"},
+		{"quotes and entities", `<a href='/?a=1&amp;b=2' class=" x  y ">Tom &amp; Jerry</a>`,
+			"<a class=\"x y\" href=\"/?a=1&amp;b=2\">Tom &amp; Jerry</a>\n"},
+		{"whitespace", "<ul>\n  <li>  a\n b </li>\n</ul>\n", "<ul>\n  <li>a b</li>\n</ul>\n"},
+		{"mixed content", `<p>a <b>b</b></p>`, "<p>a <b>b</b></p>\n"},
+		{"inline boundaries", "<p> a <b> b </b> c<br> d <img> e </p>", "<p>a <b>b </b>c<br>d <img> e</p>\n"},
+		{"spaced inline elements", "<div>\n  <span>a</span>\n  <span>b</span>\n</div>", "<div><span>a</span> <span>b</span></div>\n"},
+		{"void and self-closed", `<div><br /><input disabled="" type="text"/><span/></div>`,
+			"<div><br><input disabled type=\"text\"><span></span></div>\n"},
+		{"non-breaking space", `<p>a&nbsp; b</p>`, "<p>a&nbsp; b</p>\n"},
+		{"pre", "<pre>  a\n <b>b</b></pre>", "<pre>  a\n <b>b</b></pre>\n"},
+		{"script", `<script>if (a<b && c) {}</script>`, "<script>if (a<b && c) {}</script>\n"},
+		{"doctype and comment", "<!DOCTYPE html><!-- x --><html></html>", "<!doctype html>\n<!-- x -->\n<html></html>\n"},
+		{"unclosed", `<div><p>a</div></b>`, "<div>\n  <p>a</p>\n</div>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(tt.in)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, got, Normalize(got))
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	require.Empty(t, Diff(`<a href="/" class="x">A</a>`, "<a class=x href='/'>\n  A </a>"))
+	require.Equal(t, "@@ -1 +1 @@\n- <p>a <b>b</b></p>\n+ <p>a<b>b</b></p>\n", Diff(`<p>a <b>b</b></p>`, `<p>a<b>b</b></p>`))
+	require.Equal(t, "@@ -1 +1 @@ ul\n"+
+		"  <ul>\n"+
+		"    <li>a</li>\n"+
+		"-   <li>b</li>\n"+
+		"+   <li class=\"x\">c</li>\n"+
+		"  </ul>\n",
+		Diff(`<ul><li>a</li><li>b</li></ul>`, `<ul><li>a</li><li class="x">c</li></ul>`))
+}
+
+func TestRenderGolden(t *testing.T) {
+	out := Render(t, "@main\n"+
+		"    ul.nav[id=\"menu\"]\n"+
+		"        @for x in xs\n"+
+		"            li {= x}\n",
+		gad.Dict{"xs": gad.Array{gad.Str("a"), gad.Str("b")}})
+	Equal(t, `<ul id="menu" class="nav"><li>a</li><li>b</li></ul>`, out)
+	Golden(t, "menu", out)
+}
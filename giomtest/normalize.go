@@ -0,0 +1,256 @@
+package giomtest
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gad-lang/gad/giom/internal/htmltree"
+)
+
+// preserveSpace are the elements whose whitespace is significant.
+var preserveSpace = map[string]bool{"pre": true, "textarea": true, "script": true, "style": true}
+
+// blockElements start and end a line of text: the whitespace around them is
+// insignificant. Other elements, custom ones included, flow with the text.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true, "blockquote": true,
+	"body": true, "caption": true, "col": true, "colgroup": true, "dd": true,
+	"details": true, "dialog": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"head": true, "header": true, "hgroup": true, "hr": true, "html": true,
+	"legend": true, "li": true, "link": true, "main": true, "menu": true,
+	"meta": true, "nav": true, "noscript": true, "ol": true, "optgroup": true,
+	"option": true, "p": true, "pre": true, "script": true, "section": true,
+	"style": true, "summary": true, "table": true, "tbody": true, "td": true,
+	"template": true, "tfoot": true, "th": true, "thead": true, "title": true,
+	"tr": true, "ul": true,
+}
+
+// Normalize returns the canonical form of the HTML document s, the form
+// Equal and Golden compare: attributes sorted by name, class lists collapsed
+// and entities decoded. Whitespace in text collapses as a browser collapses
+// it: a run is one space, dropped at the start and end of a line (next to a
+// block element such as div or li) and after another space, even across
+// inline elements, so `a <b>` and `a<b>` stay apart. Whitespace in pre,
+// textarea, script and style is kept, as is the case of names. An element
+// whose children are all block elements has one child per line, indented by
+// depth; any other is written on one line:
+//
+//	<ul class="nav">
+//	  <li><a href="/">Home</a> page</li>
+//	</ul>
+//
+// Unclosed elements close with their parent, or where the next start tag
+// implies, as `<li>` closes an open li, and a stray close tag is ignored, so
+// any input has a canonical form.
+func Normalize(s string) string {
+	root := parse(s)
+	normalize(root)
+	var b strings.Builder
+	if stacked(root) {
+		for _, c := range root.Children {
+			format(&b, c, 0)
+		}
+	} else if len(root.Children) > 0 {
+		for _, c := range root.Children {
+			inline(&b, c)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// parse parses s and puts its attributes and doctype in canonical form.
+func parse(s string) *htmltree.Node {
+	root := htmltree.Parse(s)
+	var canonical func(n *htmltree.Node)
+	canonical = func(n *htmltree.Node) {
+		for _, c := range n.Children {
+			switch c.Kind {
+			case htmltree.Decl:
+				if lower := strings.ToLower(c.Data); strings.HasPrefix(lower, "<!doctype") {
+					c.Data = lower
+				}
+			case htmltree.Element:
+				for k, a := range c.Attrs {
+					if a[0] == "class" || a[0] == "style" {
+						c.Attrs[k][1] = collapse(a[1])
+					}
+				}
+				sort.SliceStable(c.Attrs, func(i, j int) bool { return c.Attrs[i][0] < c.Attrs[j][0] })
+				canonical(c)
+			}
+		}
+	}
+	canonical(root)
+	return root
+}
+
+// normalize collapses the whitespace of the text in the block n (or the
+// root) and drops the text left empty.
+func normalize(n *htmltree.Node) {
+	var l line
+	l.flow(n)
+	l.end()
+	prune(n)
+}
+
+// line collapses the whitespace of the text of a line: the inline content
+// between two block boundaries.
+type line struct {
+	// last is the last text of the line, nil at its start.
+	last *htmltree.Node
+	// started reports that the line has content and space that it ends with
+	// a space: until the line starts and after a space, the leading space of
+	// a text is dropped.
+	started, space bool
+}
+
+// flow adds the children of n to the line, ending it at block elements.
+func (l *line) flow(n *htmltree.Node) {
+	for _, c := range n.Children {
+		name := c.Lower()
+		switch {
+		case c.Kind == htmltree.Text && !c.Raw:
+			l.text(c)
+		case c.Kind != htmltree.Element:
+			// Comments and declarations do not take part in the text.
+		case blockElements[name]:
+			l.end()
+			if !preserveSpace[name] {
+				normalize(c)
+			}
+		case name == "br":
+			l.end()
+		case preserveSpace[name] || htmltree.Void[name]:
+			l.last, l.started, l.space = nil, true, false
+		default:
+			l.flow(c)
+		}
+	}
+}
+
+// text adds the text node c to the line.
+func (l *line) text(c *htmltree.Node) {
+	c.Data = htmltree.Collapse(c.Data)
+	if (l.space || !l.started) && strings.HasPrefix(c.Data, " ") {
+		c.Data = c.Data[1:]
+	}
+	if c.Data == "" {
+		return
+	}
+	l.last, l.started = c, true
+	l.space = strings.HasSuffix(c.Data, " ")
+}
+
+// end ends the line: its trailing space is dropped.
+func (l *line) end() {
+	if l.last != nil {
+		l.last.Data = strings.TrimSuffix(l.last.Data, " ")
+	}
+	*l = line{}
+}
+
+// prune drops the empty text nodes under n.
+func prune(n *htmltree.Node) {
+	children := n.Children[:0]
+	for _, c := range n.Children {
+		if c.Kind == htmltree.Text && c.Data == "" {
+			continue
+		}
+		if c.Kind == htmltree.Element && !preserveSpace[c.Lower()] {
+			prune(c)
+		}
+		children = append(children, c)
+	}
+	n.Children = children
+}
+
+// stacked reports whether the children of n are written one per line: n is
+// a block (or the root) and they are block elements, comments or
+// declarations, so the line breaks between them are insignificant.
+func stacked(n *htmltree.Node) bool {
+	if n.Name != "" && !blockElements[n.Lower()] || preserveSpace[n.Lower()] || len(n.Children) == 0 {
+		return false
+	}
+	for _, c := range n.Children {
+		if c.Kind == htmltree.Text || c.Kind == htmltree.Element && !blockElements[c.Lower()] {
+			return false
+		}
+	}
+	return true
+}
+
+// format writes n and its subtree at depth.
+func format(b *strings.Builder, n *htmltree.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	b.WriteString(indent)
+	if n.Kind != htmltree.Element || !stacked(n) {
+		inline(b, n)
+		b.WriteByte('\n')
+		return
+	}
+	openTag(b, n)
+	b.WriteByte('\n')
+	for _, c := range n.Children {
+		format(b, c, depth+1)
+	}
+	b.WriteString(indent + "</" + n.Name + ">\n")
+}
+
+// inline writes n and its subtree on the current line.
+func inline(b *strings.Builder, n *htmltree.Node) {
+	switch n.Kind {
+	case htmltree.Text:
+		if n.Raw {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(escape(n.Data, false))
+		}
+	case htmltree.Comment:
+		b.WriteString("<!--" + n.Data + "-->")
+	case htmltree.Decl:
+		b.WriteString(n.Data)
+	default:
+		openTag(b, n)
+		if htmltree.Void[n.Lower()] && len(n.Children) == 0 {
+			return
+		}
+		for _, c := range n.Children {
+			inline(b, c)
+		}
+		b.WriteString("</" + n.Name + ">")
+	}
+}
+
+func openTag(b *strings.Builder, n *htmltree.Node) {
+	b.WriteString("<" + n.Name)
+	for _, a := range n.Attrs {
+		b.WriteString(" " + a[0])
+		if a[1] != "" {
+			b.WriteString(`="` + escape(a[1], true) + `"`)
+		}
+	}
+	b.WriteByte('>')
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;", "\u00a0", "&nbsp;")
+)
+
+func escape(s string, attr bool) string {
+	if attr {
+		return attrEscaper.Replace(s)
+	}
+	return textEscaper.Replace(s)
+}
+
+// collapse trims s and collapses its runs of HTML whitespace to one space.
+// A non-breaking space is not whitespace.
+func collapse(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r < 0x80 && htmltree.IsSpace(byte(r))
+	}), " ")
+}
@@ -5,6 +5,7 @@ go 1.26.5
 require (
 	github.com/gad-lang/gad v0.0.4-0.20260717002044-7752b8fbcf85
 	github.com/stretchr/testify v1.11.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 // giom lives in the gad repository as the ./giom submodule; build against the
@@ -20,5 +21,4 @@ require (
 	github.com/shopspring/decimal v1.4.0 // indirect
 	github.com/xlab/treeprint v1.2.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
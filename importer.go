@@ -3,8 +3,10 @@ package giom
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,7 +27,11 @@ type FileImporter struct {
 	WorkDir       string
 	FileReader    func(string) (data []byte, uri string, err error)
 	TranspilePath func(srcPath string) string
-	name          string
+	// Cover, when set, instruments the imported templates for coverage.
+	Cover *Cover
+	// Profiler, when set, instruments the imported templates for profiling.
+	Profiler *Profiler
+	name     string
 }
 
 var _ gad.ExtImporter = (*FileImporter)(nil)
@@ -92,6 +98,8 @@ func (m *FileImporter) Import(ctx context.Context, module *gad.ModuleSpec) (data
 			}
 		}
 
+		m.Cover.instrument(file, src, parsed.Stmts)
+		parsed.Stmts = m.Profiler.instrument(file, parsed.Stmts)
 		if err = ctx.Compile(parsed.Stmts); err != nil {
 			return nil, err
 		}
@@ -110,6 +118,8 @@ func (m *FileImporter) Fork(moduleName string) gad.ExtImporter {
 		FileReader:    m.FileReader,
 		NameResolver:  m.NameResolver,
 		TranspilePath: m.TranspilePath,
+		Cover:         m.Cover,
+		Profiler:      m.Profiler,
 	}
 }
 
@@ -124,30 +134,88 @@ func (m *FileImporter) readFile(path string) ([]byte, string, error) {
 	return data, "file:" + path, nil
 }
 
-func writeTranspiled(outPath string, stmts gnode.Stmts) error {
+func writeTranspiled(outPath string, src []byte, m *SourceMap) error {
 	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
 		return fmt.Errorf("create transpile dir: %w", err)
 	}
-	converted := giomnode.ConvertFile(stmts)
-	var buf bytes.Buffer
-	gnode.CodeW(&buf, converted, gnode.CodeWithPrefix("\t"), gnode.CodeFormat())
 	if !strings.HasSuffix(outPath, ".gad") {
 		outPath += ".gad"
 	}
-	if err := os.WriteFile(outPath, buf.Bytes(), 0644); err != nil {
+	if err := os.WriteFile(outPath, src, 0644); err != nil {
 		return fmt.Errorf("write transpiled %s: %w", outPath, err)
 	}
+
+	// The map names the template relative to itself, as browsers expect.
+	m.File = filepath.Base(outPath)
+	for i, name := range m.Sources {
+		if abs, err := filepath.Abs(name); err == nil {
+			if rel, err := filepath.Rel(filepath.Dir(outPath), abs); err == nil {
+				m.Sources[i] = filepath.ToSlash(rel)
+			}
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath+".map", data, 0644); err != nil {
+		return fmt.Errorf("write source map %s.map: %w", outPath, err)
+	}
 	return nil
 }
 
-// Transpile parses Giom source and writes the converted Gad source to outPath.
+// gadSource returns the Gad source of parsed Giom statements, read from file
+// with source src, and its source map.
+func gadSource(file *source.File, src []byte, stmts gnode.Stmts) ([]byte, *SourceMap) {
+	stmts, positions := markSource(file, stmts)
+	var buf bytes.Buffer
+	gnode.CodeW(&buf, giomnode.ConvertFile(stmts), gnode.CodeWithPrefix("\t"), gnode.CodeFormat())
+	out, lines := mapSource(buf.Bytes(), positions)
+	return out, &SourceMap{
+		Version:        3,
+		Sources:        []string{file.Name},
+		SourcesContent: []string{string(src)},
+		Names:          []string{},
+		Mappings:       encodeMappings(lines),
+		lines:          lines,
+	}
+}
+
+// Transpile parses Giom source and writes the converted Gad source to outPath,
+// and its source map (see SourceMap) to outPath + ".map".
 func Transpile(name string, src []byte, outPath string) error {
+	out, m, err := TranspileMap(name, src)
+	if err != nil {
+		return err
+	}
+	return writeTranspiled(outPath, out, m)
+}
+
+// TranspileTo parses Giom source and writes the converted Gad source to w.
+func TranspileTo(w io.Writer, name string, src []byte) error {
+	out, _, err := TranspileMap(name, src)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// TranspileMap parses Giom source and returns the converted Gad source, as
+// TranspileTo writes it, and its source map.
+func TranspileMap(name string, src []byte) ([]byte, *SourceMap, error) {
 	fileSet := source.NewFileSet()
 	file := fileSet.AddFileData(name, -1, src)
-	p := giomparser.NewParser(file)
-	parsed, err := p.ParseFile()
+	parsed, err := giomparser.NewParser(file).ParseFile()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return writeTranspiled(outPath, parsed.Stmts)
+	out, m := gadSource(file, src, parsed.Stmts)
+	return out, m, nil
+}
+
+func parseSource(name string, src []byte) (*giomnode.File, error) {
+	fileSet := source.NewFileSet()
+	file := fileSet.AddFileData(name, -1, src)
+	return giomparser.NewParser(file).ParseFile()
 }
@@ -0,0 +1,274 @@
+// Package htmltree parses HTML into a tree forgivingly, as giom.FromHTML and
+// giomtest read it: any input parses, unclosed elements close with their
+// parent and a stray close tag is ignored.
+package htmltree
+
+import (
+	"html"
+	"strings"
+)
+
+// Kind is the kind of a Node.
+type Kind uint8
+
+const (
+	Element Kind = iota
+	Text
+	Comment
+	Decl // <!doctype …> and <?xml …?>
+)
+
+// Node is a node of a parsed HTML document.
+type Node struct {
+	Kind Kind
+	// Name is the element name as written; compare it with Lower.
+	Name string
+	// Attrs are the attributes in order; the first of repeated attributes
+	// wins, as in browsers, and a bare attribute has an empty value.
+	Attrs [][2]string
+	// Data is the unescaped text, the comment body or the declaration (with
+	// its whitespace collapsed).
+	Data string
+	// Raw marks the text of script and style, kept as written.
+	Raw      bool
+	Children []*Node
+}
+
+// Lower returns the element name in lower case.
+func (n *Node) Lower() string { return strings.ToLower(n.Name) }
+
+var (
+	// Void elements never have children.
+	Void = set("area base br col embed hr img input link meta param source track wbr")
+	// RawText elements hold text up to their close tag; the text of script
+	// and style is not unescaped.
+	RawText = set("script style textarea title")
+	// closesP are the elements whose start tag closes an open p.
+	closesP = set("address article aside blockquote details div dl fieldset figcaption figure footer form h1 h2 h3 h4 h5 h6 header hr main menu nav ol p pre section table ul")
+	// autoClose maps an element to the open elements its start tag closes,
+	// as `<li>` closes the previous li.
+	autoClose = map[string]map[string]bool{
+		"li":     set("li"),
+		"dt":     set("dt dd"),
+		"dd":     set("dt dd"),
+		"tr":     set("tr td th"),
+		"td":     set("td th"),
+		"th":     set("td th"),
+		"option": set("option"),
+	}
+)
+
+func set(names string) map[string]bool {
+	m := map[string]bool{}
+	for _, name := range strings.Fields(names) {
+		m[name] = true
+	}
+	return m
+}
+
+// Parse parses s into a tree under an anonymous root element. Besides
+// closing unclosed elements with their parent, a start tag closes the
+// elements it implies, as `<li>` closes an open li and `<div>` an open p.
+func Parse(s string) *Node {
+	root := &Node{}
+	stack := []*Node{root}
+	add := func(n *Node) {
+		top := stack[len(stack)-1]
+		if n.Kind == Text && len(top.Children) > 0 {
+			if last := top.Children[len(top.Children)-1]; last.Kind == Text && !last.Raw {
+				last.Data += n.Data
+				return
+			}
+		}
+		top.Children = append(top.Children, n)
+	}
+	text := func(t string) {
+		if t != "" {
+			add(&Node{Kind: Text, Data: html.UnescapeString(t)})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			j := strings.IndexByte(s[i:], '<')
+			if j < 0 {
+				j = len(s) - i
+			}
+			text(s[i : i+j])
+			i += j
+			continue
+		}
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				end = len(rest) - 4
+			}
+			add(&Node{Kind: Comment, Data: rest[4 : 4+end]})
+			i += min(4+end+3, len(rest))
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				text(rest)
+				i = len(s)
+				continue
+			}
+			name := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			for k := len(stack) - 1; k > 0; k-- {
+				if stack[k].Lower() == name {
+					stack = stack[:k]
+					break
+				}
+			}
+			i += end + 1
+		case len(rest) > 1 && (rest[1] == '!' || rest[1] == '?'):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			add(&Node{Kind: Decl, Data: strings.Join(strings.Fields(rest[:end+1]), " ")})
+			i += end + 1
+		case len(rest) > 1 && (rest[1] >= 'a' && rest[1] <= 'z' || rest[1] >= 'A' && rest[1] <= 'Z'):
+			n, end, selfClose := parseOpenTag(rest)
+			name := n.Lower()
+			for closes := autoClose[name]; ; {
+				top := stack[len(stack)-1]
+				if len(stack) == 1 || !closes[top.Lower()] && !(closesP[name] && top.Lower() == "p") {
+					break
+				}
+				stack = stack[:len(stack)-1]
+			}
+			add(n)
+			i += end
+			switch {
+			case selfClose || Void[name]:
+			case RawText[name]:
+				end := indexFold(s[i:], "</"+n.Name)
+				if end < 0 {
+					end = len(s) - i
+				}
+				if body := s[i : i+end]; body != "" {
+					if name == "script" || name == "style" {
+						n.Children = []*Node{{Kind: Text, Data: body, Raw: true}}
+					} else {
+						n.Children = []*Node{{Kind: Text, Data: html.UnescapeString(body)}}
+					}
+				}
+				i += end
+			default:
+				stack = append(stack, n)
+			}
+		default:
+			text("<")
+			i++
+		}
+	}
+	return root
+}
+
+// parseOpenTag parses the open tag at the start of s and returns its
+// element, the length of the tag and whether it is self-closed.
+func parseOpenTag(s string) (n *Node, end int, selfClose bool) {
+	n = &Node{Kind: Element}
+	i := 1
+	for i < len(s) && !IsSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	n.Name = s[1:i]
+	seen := map[string]bool{}
+	for i < len(s) {
+		for i < len(s) && IsSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			i++
+			break
+		}
+		if strings.HasPrefix(s[i:], "/>") {
+			i += 2
+			selfClose = true
+			break
+		}
+		start := i
+		for i < len(s) && !IsSpace(s[i]) && s[i] != '=' && s[i] != '>' && !strings.HasPrefix(s[i:], "/>") {
+			i++
+		}
+		if i == start {
+			// A lone `/` or `=`.
+			i++
+			continue
+		}
+		name, value := s[start:i], ""
+		for i < len(s) && IsSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && IsSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				j := strings.IndexByte(s[i+1:], q)
+				if j < 0 {
+					j = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+j]
+				i += j + 2
+			} else {
+				start := i
+				for i < len(s) && !IsSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+			value = html.UnescapeString(value)
+		}
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			n.Attrs = append(n.Attrs, [2]string{name, value})
+		}
+	}
+	return n, min(i, len(s)), selfClose
+}
+
+// IsSpace reports whether c is HTML whitespace. A non-breaking space is not.
+func IsSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Collapse collapses the runs of HTML whitespace of s to one space, keeping
+// one at either end.
+func Collapse(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if IsSpace(s[i]) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// indexFold is strings.Index ignoring ASCII case.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+package htmltree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dump writes the tree under n as nested `name(children)` terms.
+func dump(n *Node) string {
+	var parts []string
+	for _, c := range n.Children {
+		switch c.Kind {
+		case Text:
+			parts = append(parts, fmt.Sprintf("%q", c.Data))
+		case Comment:
+			parts = append(parts, "!"+fmt.Sprintf("%q", c.Data))
+		case Decl:
+			parts = append(parts, c.Data)
+		default:
+			s := c.Name
+			for _, a := range c.Attrs {
+				s += "[" + a[0] + "=" + a[1] + "]"
+			}
+			parts = append(parts, s+"("+dump(c)+")")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"attributes", `<a href='/?a=1&amp;b=2' HREF=x hidden>go</a>`, `a[href=/?a=1&b=2][hidden=]("go")`},
+		{"implied close", "<ul><li>a<li>b</ul><p>c<div>d</div>", `ul(li("a") li("b")) p("c") div("d")`},
+		{"unclosed", "<div><p>a</div></b>x", `div(p("a")) "x"`},
+		{"raw text", "<script>if (a<b) {}</script><title>a &amp; <b></title>", `script("if (a<b) {}") title("a & <b>")`},
+		{"void and self-closed", "<br><span/>a", `br() span() "a"`},
+		{"declarations and comments", "<!DOCTYPE  html><!-- x --><!-- y", `<!DOCTYPE html> !" x " !" y"`},
+		{"stray less-than", "a < b", `"a < b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, dump(Parse(tt.in)))
+		})
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	// A non-breaking space is not whitespace.
+	require.Equal(t, " a b\u00a0 c ", Collapse("\n a \t b\u00a0 c  "))
+}
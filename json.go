@@ -0,0 +1,249 @@
+package giom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gad-lang/gad"
+)
+
+// JSON form of the render tree. A tag is
+//
+//	{"type": "tag", "name": "a", "attrs": [{"name": "href", "value": "/"}],
+//	 "class": ["nav"], "style": ["color:red"], "children": [...]}
+//
+// and a text node is {"type": "text", "values": [...]}. Attribute and text
+// values map to their JSON counterparts (strings, numbers, booleans, null,
+// arrays and objects); the Gad-only shapes are tagged so they round-trip: a
+// RawStr is {"raw": "..."} and a Flag is {"flag": true}.
+
+const (
+	jsonTypeTag  = "tag"
+	jsonTypeText = "text"
+)
+
+type tagJSON struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name,omitempty"`
+	Attrs    []attrJSON        `json:"attrs,omitempty"`
+	Class    []string          `json:"class,omitempty"`
+	Style    []string          `json:"style,omitempty"`
+	Children []json.RawMessage `json:"children,omitempty"`
+}
+
+type attrJSON struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+type textJSON struct {
+	Type   string            `json:"type"`
+	Values []json.RawMessage `json:"values"`
+}
+
+// MarshalJSON implements json.Marshaler: the name, attributes in their
+// insertion order, class list, styles and children. The tree is written as it
+// is: call ResolveStacks first on a tree built by running a template directly.
+func (t *Tag) MarshalJSON() ([]byte, error) {
+	tj := tagJSON{Type: jsonTypeTag, Name: t.Name, Class: t.ClassList, Style: t.Styles}
+	for _, name := range t.attrOrder {
+		v, err := marshalValue(t.Attrs[name])
+		if err != nil {
+			return nil, err
+		}
+		tj.Attrs = append(tj.Attrs, attrJSON{Name: name, Value: v})
+	}
+	for _, c := range t.Children {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		tj.Children = append(tj.Children, b)
+	}
+	return json.Marshal(tj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the tag's content.
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	var tj tagJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+	if tj.Type != "" && tj.Type != jsonTypeTag {
+		return fmt.Errorf("giom: JSON element of type %q is not a tag", tj.Type)
+	}
+	*t = Tag{Name: tj.Name, ClassList: tj.Class, Styles: tj.Style}
+	for _, a := range tj.Attrs {
+		v, err := unmarshalValue(a.Value)
+		if err != nil {
+			return err
+		}
+		t.setAttr(a.Name, v)
+	}
+	for _, c := range tj.Children {
+		el, err := UnmarshalElement(c)
+		if err != nil {
+			return err
+		}
+		t.Children = append(t.Children, t.adopt(el))
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Text) MarshalJSON() ([]byte, error) {
+	tj := textJSON{Type: jsonTypeText, Values: make([]json.RawMessage, 0, len(t))}
+	for _, v := range t {
+		b, err := marshalValue(v)
+		if err != nil {
+			return nil, err
+		}
+		tj.Values = append(tj.Values, b)
+	}
+	return json.Marshal(tj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var tj textJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+	if tj.Type != "" && tj.Type != jsonTypeText {
+		return fmt.Errorf("giom: JSON element of type %q is not a text", tj.Type)
+	}
+	*t = make(Text, 0, len(tj.Values))
+	for _, raw := range tj.Values {
+		v, err := unmarshalValue(raw)
+		if err != nil {
+			return err
+		}
+		*t = append(*t, v)
+	}
+	return nil
+}
+
+// UnmarshalElement decodes an element written by MarshalJSON, dispatching on
+// its "type".
+func UnmarshalElement(data []byte) (Element, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	switch head.Type {
+	case jsonTypeTag:
+		t := &Tag{}
+		if err := t.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return t, nil
+	case jsonTypeText:
+		var t Text
+		if err := t.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("giom: unknown JSON element type %q", head.Type)
+	}
+}
+
+func marshalValue(o gad.Object) (json.RawMessage, error) {
+	return json.Marshal(jsonValue(o))
+}
+
+func unmarshalValue(data json.RawMessage) (gad.Object, error) {
+	if len(data) == 0 {
+		return gad.Nil, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return fromJSONValue(v), nil
+}
+
+// jsonValue converts a Gad value to its JSON form (see the package comment
+// above for the tagged RawStr and Flag shapes).
+func jsonValue(o gad.Object) any {
+	switch t := o.(type) {
+	case nil, *gad.NilType:
+		return nil
+	case gad.Str:
+		return string(t)
+	case gad.RawStr:
+		return map[string]any{"raw": string(t)}
+	case gad.Flag:
+		return map[string]any{"flag": bool(t)}
+	case gad.Bool:
+		return bool(t)
+	case gad.Int:
+		return int64(t)
+	case gad.Uint:
+		return uint64(t)
+	case gad.Float:
+		return float64(t)
+	case gad.Array:
+		arr := make([]any, len(t))
+		for i, v := range t {
+			arr[i] = jsonValue(v)
+		}
+		return arr
+	case gad.Dict:
+		m := make(map[string]any, len(t))
+		for k, v := range t {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case Element:
+		return t
+	default:
+		return o.ToString()
+	}
+}
+
+// fromJSONValue converts a decoded JSON value (numbers as json.Number) back to
+// a Gad value.
+func fromJSONValue(v any) gad.Object {
+	switch t := v.(type) {
+	case nil:
+		return gad.Nil
+	case string:
+		return gad.Str(t)
+	case bool:
+		return gad.Bool(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return gad.Int(i)
+		}
+		f, _ := t.Float64()
+		return gad.Float(f)
+	case []any:
+		arr := make(gad.Array, len(t))
+		for i, e := range t {
+			arr[i] = fromJSONValue(e)
+		}
+		return arr
+	case map[string]any:
+		if len(t) == 1 {
+			if s, ok := t["raw"].(string); ok {
+				return gad.RawStr(s)
+			}
+			if b, ok := t["flag"].(bool); ok {
+				return gad.Flag(b)
+			}
+		}
+		d := make(gad.Dict, len(t))
+		for k, e := range t {
+			d[k] = fromJSONValue(e)
+		}
+		return d
+	default:
+		return gad.Nil
+	}
+}
@@ -0,0 +1,79 @@
+package giom
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gad-lang/gad"
+)
+
+func jsonTree() *Tag {
+	root := NewTag(nil, "ul", nil, gad.KeyValueArray{
+		{K: gad.Str("id"), V: gad.Str("nav")},
+		{K: gad.Str("class"), V: gad.Str("menu")},
+		{K: gad.Str("data-n"), V: gad.Int(2)},
+		{K: gad.Str("hidden"), V: gad.Flag(true)},
+		{K: gad.Str("style"), V: gad.Str("color:red")},
+	})
+	li := NewTag(root, "li", nil, nil)
+	li.Children = append(li.Children, Text{gad.Str("a"), gad.RawStr("<b>b</b>")})
+	NewTag(root, "li", nil, gad.KeyValueArray{{K: gad.Str("class"), V: gad.Str("last")}})
+	return root
+}
+
+func TestTagJSON(t *testing.T) {
+	b, err := json.Marshal(jsonTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"tag","name":"ul","attrs":[{"name":"id","value":"nav"},{"name":"data-n","value":2},` +
+		`{"name":"hidden","value":{"flag":true}}],"class":["menu"],"style":["color:red"],"children":[` +
+		`{"type":"tag","name":"li","children":[{"type":"text","values":["a",{"raw":"<b>b</b>"}]}]},` +
+		`{"type":"tag","name":"li","class":["last"]}]}`
+	if got := string(b); got != want {
+		t.Fatalf("marshal\n got: %s\nwant: %s", got, want)
+	}
+
+	var back Tag
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := writeElement(t, &back), writeElement(t, jsonTree()); got != want {
+		t.Fatalf("round trip\n got: %s\nwant: %s", got, want)
+	}
+	if v, ok := back.Attrs["data-n"].(gad.Int); !ok || v != 2 {
+		t.Fatalf("data-n decoded as %#v", back.Attrs["data-n"])
+	}
+}
+
+func TestUnmarshalElement(t *testing.T) {
+	el, err := UnmarshalElement([]byte(`{"type":"text","values":["x",1.5,null]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, ok := el.(Text)
+	if !ok || len(text) != 3 || text[0] != gad.Str("x") || text[1] != gad.Float(1.5) || text[2] != gad.Nil {
+		t.Fatalf("got %#v", el)
+	}
+	if _, err := UnmarshalElement([]byte(`{"type":"comment"}`)); err == nil {
+		t.Fatal("expected an error for an unknown element type")
+	}
+}
+
+func TestBuiltinToJSON(t *testing.T) {
+	builtins := AppendBuiltins(gad.NewBuiltins())
+	st := gad.NewSymbolTable(builtins.NameSet)
+	_, bc, err := gad.Compile(st, []byte(`return giom.toJSON(giom.Tag("a"; href="/", class="x"))`), gad.CompileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := gad.NewEval(builtins.Build(), st, gad.CompileOptions{}).Run(context.Background(), bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"tag","name":"a","attrs":[{"name":"href","value":"/"}],"class":["x"]}`
+	if got, ok := ret.(gad.Str); !ok || string(got) != want {
+		t.Fatalf("\n got: %v\nwant: %s", ret, want)
+	}
+}
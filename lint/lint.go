@@ -0,0 +1,273 @@
+// Package lint reports likely mistakes in Giom templates, run by `giom lint`.
+// A Linter parses a template and checks its node.File against these rules:
+//
+//	undeclared-global   an expression reads a name nothing declares
+//	unknown-component   a `+name` call names no component in scope
+//	component-args      a `+name(…)` call does not match the component's parameters
+//	unknown-slot        `@slot #name` passes a slot the component does not declare
+//	duplicate-id        two tags of a component, or of the page, share an id
+//	img-alt             an `img` tag has no alt attribute
+//	raw-untrusted       `{=raw expr}` writes a value not listed as trusted
+//	unused-import       a name bound by `@import` is never used
+//	unused-component    a component that is not exported is never called
+//
+// A `//- giom:ignore` comment suppresses the findings of the line below it;
+// `//- giom:ignore img-alt duplicate-id` only suppresses the rules it lists.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+// Rule IDs.
+const (
+	RuleUndeclaredGlobal = "undeclared-global"
+	RuleUnknownComponent = "unknown-component"
+	RuleComponentArgs    = "component-args"
+	RuleUnknownSlot      = "unknown-slot"
+	RuleDuplicateID      = "duplicate-id"
+	RuleImgAlt           = "img-alt"
+	RuleRawUntrusted     = "raw-untrusted"
+	RuleUnusedImport     = "unused-import"
+	RuleUnusedComponent  = "unused-component"
+)
+
+// Finding is a problem reported at a position of a template.
+type Finding struct {
+	Rule    string
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.File, f.Line, f.Column, f.Message, f.Rule)
+}
+
+// Linter checks templates. The zero value is ready to use.
+type Linter struct {
+	// Globals are declared, as the keys of the globals passed to giom.Render
+	// are.
+	Globals []string
+	// Trusted are the expressions `{=raw …}` may write, e.g. "Model.Page.Body".
+	// String literals are always trusted.
+	Trusted []string
+	// WorkDir is the base directory of `@import` paths; the template's
+	// directory when empty.
+	WorkDir string
+}
+
+// LintFile checks the template at path.
+func (l *Linter) LintFile(path string) ([]Finding, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(path, src)
+}
+
+// Lint checks the template src, named path, and returns its findings sorted
+// by position. A template that does not parse returns the parse error.
+func (l *Linter) Lint(path string, src []byte) ([]Finding, error) {
+	fileSet := source.NewFileSet()
+	file := fileSet.AddFileData(path, -1, src)
+	parsed, err := parseRecover(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &pass{
+		linter:  l,
+		path:    path,
+		src:     src,
+		fileSet: fileSet,
+		file:    file,
+		ast:     parsed,
+		modules: map[string]*giomnode.File{},
+		ignores: map[int][]string{},
+	}
+	p.collect()
+	p.checkCalls()
+	p.checkTags()
+	p.checkRaw()
+	p.checkUnused()
+	// Last: converting the template to Gad code adds parameters to its slots.
+	p.checkReferences()
+	return p.results(), nil
+}
+
+// pass holds the state of one Lint call.
+type pass struct {
+	linter  *Linter
+	path    string
+	src     []byte
+	fileSet *source.FileSet
+	file    *source.File
+	ast     *giomnode.File
+	// modules caches the imported templates by path; nil when one does not
+	// parse.
+	modules map[string]*giomnode.File
+
+	// Collected from the template, comments excluded.
+	comps   []*giomnode.CompDecl
+	funcs   []*giomnode.FuncDecl
+	calls   []*giomnode.CompCallStmt
+	texts   []*giomnode.TextStmt
+	imports []*giomnode.CodeStmt
+	// ignores maps a line to the rules suppressed on it; an empty list
+	// suppresses all of them.
+	ignores map[int][]string
+
+	findings []Finding
+}
+
+var rgxIgnore = regexp.MustCompile(`^giom:ignore\b(.*)$`)
+
+// collect gathers the declarations, calls, texts and ignore comments of the
+// template.
+func (p *pass) collect() {
+	for _, stmt := range p.ast.Stmts {
+		if c, ok := stmt.(*giomnode.CodeStmt); ok && c.Import != nil {
+			p.imports = append(p.imports, c)
+		}
+	}
+	giomnode.Walk(p.ast.Stmts, func(stmt gnode.Stmt) bool {
+		switch s := stmt.(type) {
+		case *giomnode.CommentStmt:
+			if m := rgxIgnore.FindStringSubmatch(strings.TrimSpace(s.Text)); m != nil && s.Silent {
+				line, _ := p.position(s.NodePos)
+				p.ignores[line+1] = strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+			}
+			// The body of a comment is commented out.
+			return false
+		case *giomnode.CompDecl:
+			p.comps = append(p.comps, s)
+		case *giomnode.FuncDecl:
+			p.funcs = append(p.funcs, s)
+		case *giomnode.CompCallStmt:
+			p.calls = append(p.calls, s)
+		case *giomnode.TextStmt:
+			p.texts = append(p.texts, s)
+		}
+		return true
+	})
+}
+
+// report adds a finding at pos.
+func (p *pass) report(rule string, pos source.Pos, format string, args ...any) {
+	line, col := p.position(pos)
+	p.reportAt(rule, line, col, format, args...)
+}
+
+// reportAt adds a finding at the 1-based line and column.
+func (p *pass) reportAt(rule string, line, col int, format string, args ...any) {
+	p.findings = append(p.findings, Finding{
+		Rule:    rule,
+		File:    p.path,
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// results returns the findings that are not suppressed, sorted by position.
+func (p *pass) results() []Finding {
+	var out []Finding
+	for _, f := range p.findings {
+		if !p.ignored(f) {
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Line != out[j].Line {
+			return out[i].Line < out[j].Line
+		}
+		return out[i].Column < out[j].Column
+	})
+	return out
+}
+
+func (p *pass) ignored(f Finding) bool {
+	rules, ok := p.ignores[f.Line]
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r == f.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the 1-based line and column of pos.
+func (p *pass) position(pos source.Pos) (line, col int) {
+	fp := p.fileSet.Position(pos)
+	return fp.Line, fp.Column
+}
+
+// offset returns the byte offset of pos in the template, or -1.
+func (p *pass) offset(pos source.Pos) int {
+	if !pos.IsValid() {
+		return -1
+	}
+	off := int(pos) - p.file.Base
+	if off < 0 || off > len(p.src) {
+		return -1
+	}
+	return off
+}
+
+// workDir returns the base directory of the template's imports.
+func (p *pass) workDir() string {
+	if p.linter.WorkDir != "" {
+		return p.linter.WorkDir
+	}
+	return filepath.Dir(p.path)
+}
+
+// module returns the parsed template an `@import` reads, or nil when it is
+// not a template or does not parse.
+func (p *pass) module(spec *giomnode.ImportSpec) *giomnode.File {
+	path := spec.Path
+	if filepath.Ext(path) != ".giom" {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.workDir(), path)
+	}
+	if f, ok := p.modules[path]; ok {
+		return f
+	}
+	var parsed *giomnode.File
+	if src, err := os.ReadFile(path); err == nil {
+		file := source.NewFileSet().AddFileData(path, -1, src)
+		parsed, _ = parseRecover(file)
+	}
+	p.modules[path] = parsed
+	return parsed
+}
+
+// parseRecover parses file; a parser panic is returned as an error.
+func parseRecover(file *source.File) (f *giomnode.File, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			f, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return giomparser.NewParser(file).ParseFile()
+}
@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const libSrc = "@export comp card(title; slots={})\n" +
+	"    div.card\n" +
+	"        h2 {= title}\n" +
+	"        @slot main\n" +
+	"        @slot footer\n"
+
+const pageSrc = "@import { card } from \"lib.giom\"\n" + // 1
+	"@import \"lib.giom\" as lib\n" + // 2
+	"@import { card: unusedCard } from \"lib.giom\"\n" + // 3
+	"@comp unused()\n" + // 4
+	"    p x\n" + // 5
+	"@comp helper(a)\n" + // 6
+	"    p {= a}\n" + // 7
+	"@main\n" + // 8
+	"    +card(\"A\", \"B\")\n" + // 9
+	"        @slot #header\n" + // 10
+	"            p h\n" + // 11
+	"    +lib.cardd(\"B\")\n" + // 12
+	"    +missing\n" + // 13
+	"    +helper(1, extra=2)\n" + // 14
+	"    p {= Undeclared}\n" + // 15
+	"    div#a\n" + // 16
+	"    div#a\n" + // 17
+	"    img[src=\"x.png\"]\n" + // 18
+	"    p {=raw Body}\n" + // 19
+	"    p {=raw Trusted.Body}\n" + // 20
+	"    //- giom:ignore img-alt\n" + // 21
+	"    img[src=\"y.png\"]\n" // 22
+
+// lintFiles writes name → content files into a temporary directory and lints
+// the file name.
+func lintFiles(t *testing.T, l *Linter, files map[string]string, name string) []Finding {
+	t.Helper()
+	dir := t.TempDir()
+	for n, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, n), []byte(content), 0644))
+	}
+	findings, err := l.LintFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return findings
+}
+
+// lines returns the "line rule" of each finding.
+func lines(findings []Finding) []string {
+	out := make([]string, len(findings))
+	for i, f := range findings {
+		out[i] = fmt.Sprintf("%d %s", f.Line, f.Rule)
+	}
+	return out
+}
+
+func TestLint(t *testing.T) {
+	l := &Linter{Globals: []string{"Body", "Trusted"}, Trusted: []string{"Trusted.Body"}}
+	findings := lintFiles(t, l, map[string]string{"lib.giom": libSrc, "page.giom": pageSrc}, "page.giom")
+	require.Equal(t, []string{
+		"3 unused-import",
+		"4 unused-component",
+		"9 component-args",
+		"10 unknown-slot",
+		"12 unknown-component",
+		"13 unknown-component",
+		"14 component-args",
+		"15 undeclared-global",
+		"17 duplicate-id",
+		"18 img-alt",
+		"19 raw-untrusted",
+	}, lines(findings))
+
+	messages := map[int]string{}
+	for _, f := range findings {
+		messages[f.Line] = f.Message
+	}
+	require.Equal(t, `"unusedCard" is imported but never used`, messages[3])
+	require.Equal(t, `component "unused" is never used`, messages[4])
+	require.Equal(t, `+card takes 1 argument, got 2`, messages[9])
+	require.Equal(t, `+card declares no slot "header"`, messages[10])
+	require.Equal(t, `unknown component "lib.cardd"`, messages[12])
+	require.Equal(t, `unknown component "missing"`, messages[13])
+	require.Equal(t, `+helper has no parameter "extra"`, messages[14])
+	require.Equal(t, `"Undeclared" is not declared; declare it with @global`, messages[15])
+	require.Equal(t, `duplicate id "a"`, messages[17])
+	require.Equal(t, `{=raw Body} writes an untrusted value unescaped`, messages[19])
+	require.Equal(t, 5, findings[5].Column)
+}
+
+func TestLintIgnore(t *testing.T) {
+	src := "@main\n" +
+		"    //- giom:ignore\n" +
+		"    img#x[src=\"a.png\"]\n" +
+		"    //- giom:ignore duplicate-id, unknown-slot\n" +
+		"    img#x[src=\"b.png\"]\n" +
+		"    // giom:ignore\n" +
+		"    img[src=\"c.png\"]\n"
+	findings := lintFiles(t, &Linter{}, map[string]string{"page.giom": src}, "page.giom")
+	require.Equal(t, []string{"5 img-alt", "7 img-alt"}, lines(findings))
+	require.Equal(t, "page.giom", filepath.Base(findings[0].File))
+	require.Regexp(t, `page\.giom:5:5: img has no alt attribute \(img-alt\)$`, findings[0].String())
+}
+
+func TestLintComponentScopes(t *testing.T) {
+	src := "@comp a()\n" +
+		"    div#x\n" +
+		"@comp b(*items)\n" +
+		"    div#x\n" +
+		"@main\n" +
+		"    div#x\n" +
+		"    +a\n" +
+		"    +b\n" +
+		"    +b(1, 2)\n"
+	findings := lintFiles(t, &Linter{}, map[string]string{"page.giom": src}, "page.giom")
+	require.Empty(t, findings)
+}
+
+func TestLintReferences(t *testing.T) {
+	src := "@comp row(item)\n" + // 1
+		"    td {= item.Name}\n" + // 2
+		"    td {= Missing}\n" + // 3
+		"@main\n" + // 4
+		"    // {= Commented}\n" + // 5
+		"    @for i, x in xs\n" + // 6
+		"        +row(x)\n" + // 7
+		"        p[title=\"Missing\"] {= i}\n" + // 8
+		"    ~ y := len(xs)\n" + // 9
+		"    p {= y} {= x}\n" // 10
+	findings := lintFiles(t, &Linter{Globals: []string{"xs"}}, map[string]string{"page.giom": src}, "page.giom")
+	require.Equal(t, []string{"3 undeclared-global", "10 undeclared-global"}, lines(findings))
+	require.Equal(t, `"Missing" is not declared; declare it with @global`, findings[0].Message)
+	require.Equal(t, `"x" is not declared; declare it with @global`, findings[1].Message)
+}
+
+func TestLintParseError(t *testing.T) {
+	_, err := (&Linter{}).Lint("page.giom", []byte("@main\n    @else\n"))
+	require.ErrorContains(t, err, "unexpected ELSE without matching @if")
+}
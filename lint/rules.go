@@ -0,0 +1,561 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gad-lang/gad"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+	"github.com/gad-lang/gad/token"
+
+	"github.com/gad-lang/gad/giom"
+	giomnode "github.com/gad-lang/gad/giom/node"
+)
+
+// =============================================================================
+// undeclared-global, unknown-component
+// =============================================================================
+
+// checkReferences reports the names the template reads that nothing declares.
+// The template is converted to the Gad code it compiles to, whose identifiers
+// are resolved through the scopes of its functions and loops (see resolver);
+// a name no scope declares is reported unless it is a builtin. An unresolved
+// name called as `+name` is an unknown component.
+func (p *pass) checkReferences() {
+	root := newScope(nil)
+	for _, name := range p.linter.Globals {
+		root.names[name] = true
+	}
+	giomnode.Walk(p.ast.Stmts, func(stmt gnode.Stmt) bool {
+		// The Gad enum statement names its values as identifiers.
+		if e, ok := stmt.(*giomnode.EnumStmt); ok {
+			root.names[e.Name] = true
+		}
+		return true
+	})
+	r := &resolver{seen: map[nodeRef]bool{}}
+	r.visit(reflect.ValueOf(giomnode.ConvertFile(p.ast.Stmts)), root)
+
+	sort.SliceStable(r.reads, func(i, j int) bool { return r.reads[i].ident.Pos() < r.reads[j].ident.Pos() })
+	reported := map[string]bool{}
+	for _, rd := range r.reads {
+		name := rd.ident.Name
+		if reported[name] || !rd.ident.Pos().IsValid() || rd.scope.declares(name) || isBuiltin(name) {
+			continue
+		}
+		reported[name] = true
+		line, col := p.position(rd.ident.Pos())
+		if call := p.unresolvedCall(name, line); call != nil {
+			p.report(RuleUnknownComponent, call.NodePos, "unknown component %q", call.Name)
+			continue
+		}
+		p.reportAt(RuleUndeclaredGlobal, line, col, "%q is not declared; declare it with @global", name)
+	}
+}
+
+// scope holds the names declared in a function or loop body of the compiled
+// template. Declarations count for the whole body, wherever they are.
+type scope struct {
+	outer *scope
+	names map[string]bool
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: map[string]bool{}}
+}
+
+// declares reports whether s or a scope enclosing it declares name.
+func (s *scope) declares(name string) bool {
+	for ; s != nil; s = s.outer {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// identRead is an identifier read in a scope.
+type identRead struct {
+	ident *gnode.IdentExpr
+	scope *scope
+}
+
+type nodeRef struct {
+	t reflect.Type
+	p uintptr
+}
+
+// resolver walks Gad code, collecting the names each scope declares and the
+// identifiers read. Nodes that neither declare names nor open a scope are
+// walked through their fields, where every identifier is a read, except for
+// the fields named Ident (of parameters, catch clauses, …), which declare it.
+type resolver struct {
+	reads []identRead
+	seen  map[nodeRef]bool
+}
+
+func (r *resolver) declare(s *scope, ident *gnode.IdentExpr) {
+	if ident != nil {
+		s.names[ident.Name] = true
+	}
+}
+
+func (r *resolver) visit(v reflect.Value, s *scope) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			r.visit(v.Elem(), s)
+		}
+	case reflect.Ptr:
+		ref := nodeRef{v.Type(), v.Pointer()}
+		if v.IsNil() || r.seen[ref] {
+			return
+		}
+		r.seen[ref] = true
+		if v.CanInterface() && r.node(v.Interface(), s) {
+			return
+		}
+		r.visit(v.Elem(), s)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if t.Field(i).Name == "Ident" && f.Type() == identExprType {
+				// The name of a call's named argument is not a variable.
+				if t.Name() != "NamedArgExpr" && f.CanInterface() {
+					r.declare(s, f.Interface().(*gnode.IdentExpr))
+				}
+				continue
+			}
+			r.visit(f, s)
+		}
+	case reflect.Slice, reflect.Array:
+		if k := v.Type().Elem().Kind(); k != reflect.Interface && k != reflect.Ptr && k != reflect.Struct && k != reflect.Slice {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			r.visit(v.Index(i), s)
+		}
+	}
+}
+
+var identExprType = reflect.TypeOf((*gnode.IdentExpr)(nil))
+
+// node handles the nodes that read or declare names and those opening a scope,
+// reporting whether it did.
+func (r *resolver) node(n any, s *scope) bool {
+	switch n := n.(type) {
+	case *gnode.IdentExpr:
+		if n.Name != "_" {
+			r.reads = append(r.reads, identRead{n, s})
+		}
+	case *gnode.AssignStmt:
+		for _, lhs := range n.LHS {
+			if ident, ok := lhs.(*gnode.IdentExpr); ok && n.Token == token.Define {
+				r.declare(s, ident)
+			} else {
+				r.visit(reflect.ValueOf(lhs), s)
+			}
+		}
+		r.visit(reflect.ValueOf(n.RHS), s)
+	case *gnode.ValueSpec:
+		for _, ident := range n.Idents {
+			r.declare(s, ident)
+		}
+		r.visit(reflect.ValueOf(n.Values), s)
+	case *gnode.TypedIdentExpr:
+		// A parameter; its type names are builtins.
+		r.declare(s, n.Ident)
+	case *gnode.FuncExpr:
+		inner := newScope(s)
+		r.visit(reflect.ValueOf(n.Type), inner)
+		r.visit(reflect.ValueOf(n.Body), inner)
+	case *gnode.ForInStmt:
+		inner := newScope(s)
+		r.declare(inner, n.Key)
+		r.declare(inner, n.Value)
+		r.visit(reflect.ValueOf(n.Iterable), s)
+		r.visit(reflect.ValueOf(n.Body), inner)
+	case *gnode.KeyValuePairLit:
+		// A key-value pair names its key (`(;name=value)`).
+		if _, ok := n.Key.(*gnode.IdentExpr); !ok {
+			r.visit(reflect.ValueOf(n.Key), s)
+		}
+		r.visit(reflect.ValueOf(n.Value), s)
+	case *gnode.EnumStmt, *giomnode.CommentStmt:
+		// Enum values are names; commented-out content is not code.
+	default:
+		return false
+	}
+	return true
+}
+
+// builtins caches isBuiltin.
+var (
+	builtinsMu sync.Mutex
+	builtins   = map[string]bool{}
+)
+
+// isBuiltin reports whether name is a builtin of the templates, compiling a
+// program reading it with the builtins giom.Render uses.
+func isBuiltin(name string) bool {
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+	ok, cached := builtins[name]
+	if !cached {
+		ok = compiles("return " + name)
+		builtins[name] = ok
+	}
+	return ok
+}
+
+// compiles reports whether the Gad src compiles; a compiler panic is a
+// failure.
+func compiles(src string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	st := gad.NewSymbolTable(giom.AppendBuiltins(gad.NewBuiltins()).NameSet)
+	_, _, err := gad.Compile(st, []byte(src), gad.CompileOptions{})
+	return err == nil
+}
+
+// unresolvedCall returns the call on line whose name, or the qualifier of its
+// name, compiles to the identifier name.
+func (p *pass) unresolvedCall(name string, line int) *giomnode.CompCallStmt {
+	for _, c := range p.calls {
+		root, _, _ := strings.Cut(c.Name, ".")
+		if strings.ReplaceAll(root, "-", "__") != name {
+			continue
+		}
+		if l, _ := p.position(c.NodePos); l == line {
+			return c
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// unknown-component, component-args, unknown-slot
+// =============================================================================
+
+// checkCalls checks the calls of components imported `as alias`, which the
+// compiler does not resolve, and the arguments and slots of every call whose
+// component is known.
+func (p *pass) checkCalls() {
+	for _, c := range p.calls {
+		decl, known := p.resolve(c.Name)
+		if decl == nil {
+			if known {
+				p.report(RuleUnknownComponent, c.NodePos, "unknown component %q", c.Name)
+			}
+			continue
+		}
+		if comp, ok := decl.(*giomnode.CompDecl); ok {
+			p.checkArgs(c, comp)
+			p.checkSlots(c, comp)
+		}
+	}
+}
+
+// resolve returns the component or function a call names: declared in the
+// template, imported by a destructured `@import` or, for `alias.name`,
+// exported by the template imported `as alias`. known reports that a nil
+// declaration is certainly missing, as opposed to bound to something the
+// linter does not follow (a variable, a Gad module).
+func (p *pass) resolve(name string) (decl gnode.Stmt, known bool) {
+	if qualifier, member, ok := strings.Cut(name, "."); ok {
+		for _, imp := range p.imports {
+			if imp.Import.Alias != qualifier {
+				continue
+			}
+			if m := p.module(imp.Import); m != nil {
+				return exported(m, member)
+			}
+			return nil, false
+		}
+		return nil, false
+	}
+	for _, c := range p.comps {
+		if !c.Main && c.Name == name {
+			return c, true
+		}
+	}
+	for _, f := range p.funcs {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	for _, imp := range p.imports {
+		if exportedName, ok := imp.Import.Names[name]; ok {
+			if m := p.module(imp.Import); m != nil {
+				return exported(m, exportedName)
+			}
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// exported returns the component or function m exports as name; known is
+// false when m exports name as another value.
+func exported(m *giomnode.File, name string) (decl gnode.Stmt, known bool) {
+	known = true
+	giomnode.Walk(m.Stmts, func(stmt gnode.Stmt) bool {
+		switch s := stmt.(type) {
+		case *giomnode.CompDecl:
+			if s.Exported && s.Name == name {
+				decl = s
+			}
+		case *giomnode.FuncDecl:
+			if s.Exported && s.Name == name {
+				decl = s
+			}
+		case *giomnode.ExportStmt:
+			if s.Name == name {
+				known = false
+			}
+		}
+		return decl == nil
+	})
+	return decl, decl != nil || known
+}
+
+// checkArgs reports the arguments of c that the parameters of comp do not
+// take.
+func (p *pass) checkArgs(c *giomnode.CompCallStmt, comp *giomnode.CompDecl) {
+	params := comp.Params
+	if params == nil {
+		params = &gnode.FuncParams{}
+	}
+	for _, v := range c.Args.Args.Values {
+		if v == nil {
+			// An argument the parser could not read, e.g. a `*spread`.
+			return
+		}
+	}
+	n, want := len(c.Args.Args.Values), len(params.Args.Values)
+	switch {
+	case params.Args.Var == nil && n != want:
+		p.report(RuleComponentArgs, c.NodePos, "+%s takes %s, got %d", c.Name, arguments(want), n)
+	case params.Args.Var != nil && n < want:
+		p.report(RuleComponentArgs, c.NodePos, "+%s takes at least %s, got %d", c.Name, arguments(want), n)
+	}
+
+	if params.NamedArgs.Var != nil {
+		return
+	}
+	names := map[string]bool{"slots": true}
+	for _, name := range params.NamedArgs.Names {
+		if name != nil && name.Ident != nil {
+			names[name.Ident.Name] = true
+		}
+	}
+	for _, arg := range c.Args.NamedArgs.Names {
+		if arg.Var || arg.Ident == nil {
+			continue
+		}
+		if !names[arg.Ident.Name] {
+			p.report(RuleComponentArgs, c.NodePos, "+%s has no parameter %q", c.Name, arg.Ident.Name)
+		}
+	}
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// checkSlots reports the `@slot #name` blocks of c passing a slot comp does
+// not declare. Components declaring an interpolated slot name are skipped.
+func (p *pass) checkSlots(c *giomnode.CompCallStmt, comp *giomnode.CompDecl) {
+	declared := map[string]bool{}
+	for _, slot := range comp.Slots {
+		if slot.NameExpr != nil {
+			return
+		}
+		declared[slot.Name] = true
+	}
+	for _, sp := range c.SlotPass {
+		if sp.NameExpr != nil || sp.Implicit() {
+			continue
+		}
+		ident, ok := sp.Name.(*gnode.IdentExpr)
+		if ok && !declared[ident.Name] {
+			p.report(RuleUnknownSlot, sp.NodePos, "+%s declares no slot %q", c.Name, ident.Name)
+		}
+	}
+}
+
+// =============================================================================
+// duplicate-id, img-alt
+// =============================================================================
+
+func (p *pass) checkTags() {
+	p.checkTagsIn(p.ast.Stmts)
+}
+
+// checkTagsIn checks the tags of stmts, whose ids are unique, and those of the
+// components declared in stmts, each with its own ids.
+func (p *pass) checkTagsIn(stmts gnode.Stmts) {
+	ids := map[string]bool{}
+	giomnode.Walk(stmts, func(stmt gnode.Stmt) bool {
+		switch s := stmt.(type) {
+		case *giomnode.CommentStmt:
+			return false
+		case *giomnode.CompDecl:
+			p.checkTagsIn(s.Body)
+			return false
+		case *giomnode.TagStmt:
+			p.checkTag(s, ids)
+		}
+		return true
+	})
+}
+
+func (p *pass) checkTag(t *giomnode.TagStmt, ids map[string]bool) {
+	hasAlt := false
+	for _, a := range t.Attributes {
+		switch {
+		case a.Spread:
+			hasAlt = true
+		case a.Name == "alt":
+			hasAlt = true
+		case a.Name == "id" && a.Condition == nil:
+			lit, ok := a.Value.(*gnode.StrLit)
+			if !ok || lit.Value() == "" {
+				continue
+			}
+			if ids[lit.Value()] {
+				p.report(RuleDuplicateID, t.NodePos, "duplicate id %q", lit.Value())
+			}
+			ids[lit.Value()] = true
+		}
+	}
+	if strings.EqualFold(t.Name, "img") && !hasAlt {
+		p.report(RuleImgAlt, t.NodePos, "img has no alt attribute")
+	}
+}
+
+// =============================================================================
+// raw-untrusted
+// =============================================================================
+
+var (
+	// rgxRaw matches a `{=raw value}` interpolation up to the end of its value.
+	rgxRaw    = regexp.MustCompile(`^\{=?\s*raw\s+([\s\S]+?)\s*\}?$`)
+	rgxStrLit = regexp.MustCompile("^(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`|'(?:[^'\\\\]|\\\\.)*')$")
+)
+
+func (p *pass) checkRaw() {
+	for _, t := range p.texts {
+		for _, stmt := range t.Stmts {
+			v, ok := stmt.(*gnode.MixedValueStmt)
+			if !ok || v.Expr == nil {
+				continue
+			}
+			if value, ok := p.rawValue(v); ok && !p.trusted(value) {
+				p.report(RuleRawUntrusted, v.Expr.Pos(), "{=raw %s} writes an untrusted value unescaped", value)
+			}
+		}
+	}
+}
+
+// rawValue returns the source of the value of a `{=raw value}` interpolation;
+// ok is false for other interpolations.
+func (p *pass) rawValue(v *gnode.MixedValueStmt) (value string, ok bool) {
+	start, end := p.offset(v.Expr.Pos()), p.offset(v.Expr.End())
+	if start < 0 || end < start {
+		return "", false
+	}
+	open := bytes.LastIndexByte(p.src[:start], '{')
+	if open < 0 {
+		return "", false
+	}
+	m := rgxRaw.FindSubmatch(p.src[open:end])
+	if m == nil {
+		return "", false
+	}
+	return string(m[1]), true
+}
+
+// trusted reports whether a raw value is a string literal or one of the
+// trusted expressions.
+func (p *pass) trusted(value string) bool {
+	if rgxStrLit.MatchString(value) {
+		return true
+	}
+	value = strings.Join(strings.Fields(value), "")
+	for _, t := range p.linter.Trusted {
+		if strings.Join(strings.Fields(t), "") == value {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// unused-import, unused-component
+// =============================================================================
+
+func (p *pass) checkUnused() {
+	for _, imp := range p.imports {
+		names := make([]string, 0, len(imp.Import.Names)+1)
+		for local := range imp.Import.Names {
+			names = append(names, local)
+		}
+		sort.Strings(names)
+		if imp.Import.Alias != "" {
+			names = append(names, imp.Import.Alias)
+		}
+		for _, name := range names {
+			if !p.used(name, imp.NodePos, imp.NodeEnd) {
+				p.report(RuleUnusedImport, imp.NodePos, "%q is imported but never used", name)
+			}
+		}
+	}
+	for _, c := range p.comps {
+		if c.Main || c.Exported {
+			continue
+		}
+		if !p.used(c.Name, c.NodePos, c.NodePos) {
+			p.report(RuleUnusedComponent, c.NodePos, "component %q is never used", c.Name)
+		}
+	}
+}
+
+// used reports whether name is called as `+name` or `+name.member`, or read
+// on a line out of the lines of pos..end (its declaration) that is not a
+// comment.
+func (p *pass) used(name string, pos, end source.Pos) bool {
+	for _, c := range p.calls {
+		if c.Name == name || strings.HasPrefix(c.Name, name+".") {
+			return true
+		}
+	}
+	from, _ := p.position(pos)
+	to, _ := p.position(end)
+	rgx := regexp.MustCompile(`(^|[^\w$.])` + regexp.QuoteMeta(name) + `($|[^\w$])`)
+	for i, line := range bytes.Split(p.src, []byte("\n")) {
+		if n := i + 1; n >= from && n <= to {
+			continue
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			continue
+		}
+		if rgx.Match(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package giom
+
+import (
+	"strconv"
+
+	"github.com/gad-lang/gad"
+)
+
+// LoopType is the Gad object type of *Loop.
+var LoopType = gad.NewBuiltinObjType("Loop")
+
+func init() {
+	LoopType.SetModule(ModuleSpec)
+}
+
+// Loop is the implicit `loop` variable of a @for block. A @for whose body
+// mentions `loop` (or that has an @else branch) compiles to
+//
+//	loop := giom.loop(iterable, parentLoop)
+//	for k, v in iterable { loop.next(); <body> }
+//	if loop.empty { <else> }
+//
+// so inside the body it describes the current iteration:
+//
+//	loop.index    // 0-based position
+//	loop.index1   // 1-based position
+//	loop.first    // true on the first item
+//	loop.last     // true on the last item (false when the length is unknown)
+//	loop.length   // item count, or -1 when the iterable has no length
+//	loop.odd      // true on the 1st, 3rd, … item (like CSS :nth-child(odd))
+//	loop.even     // true on the 2nd, 4th, … item
+//	loop.parent   // loop of the enclosing @for, or nil
+//	loop.empty    // true until the first item (the @else condition)
+type Loop struct {
+	// Index is the 0-based position of the current item, -1 before the first.
+	Index int
+	// Length is the item count, or -1 when unknown.
+	Length int
+	// Parent is the loop of the enclosing @for, if any.
+	Parent *Loop
+}
+
+// NewLoop returns a loop over iterable, counting its items with the `len`
+// builtin when it has a length.
+func NewLoop(vm *gad.VM, iterable gad.Object, parent *Loop) *Loop {
+	l := &Loop{Index: -1, Length: -1, Parent: parent}
+	if iterable == nil || iterable == gad.Nil || vm == nil {
+		return l
+	}
+	if n, err := vm.Builtins.ArgsInvoker(gad.BuiltinLen, gad.Call{VM: vm})(iterable); err == nil {
+		if i, ok := n.(gad.Int); ok {
+			l.Length = int(i)
+		}
+	}
+	return l
+}
+
+// Next advances the loop to the next item.
+func (l *Loop) Next() { l.Index++ }
+
+// First reports whether the current item is the first.
+func (l *Loop) First() bool { return l.Index == 0 }
+
+// Last reports whether the current item is the last one of a known length.
+func (l *Loop) Last() bool { return l.Length >= 0 && l.Index == l.Length-1 }
+
+// Empty reports whether the loop has not reached an item (yet).
+func (l *Loop) Empty() bool { return l.Index < 0 }
+
+func (l *Loop) Type() gad.ObjectType { return LoopType }
+func (l *Loop) IsFalsy() bool        { return false }
+
+func (l *Loop) ToString() string {
+	return "giom.Loop(" + strconv.Itoa(l.Index) + "/" + strconv.Itoa(l.Length) + ")"
+}
+
+func (l *Loop) Equal(right gad.Object) bool {
+	o, ok := right.(*Loop)
+	return ok && o == l
+}
+
+// IndexGet implements the loop fields (see Loop) and `loop.next()`.
+func (l *Loop) IndexGet(_ *gad.VM, index gad.Object) (gad.Object, error) {
+	switch index.ToString() {
+	case "index":
+		return gad.Int(l.Index), nil
+	case "index1":
+		return gad.Int(l.Index + 1), nil
+	case "first":
+		return gad.Bool(l.First()), nil
+	case "last":
+		return gad.Bool(l.Last()), nil
+	case "length":
+		return gad.Int(l.Length), nil
+	case "odd":
+		return gad.Bool(l.Index >= 0 && l.Index%2 == 0), nil
+	case "even":
+		return gad.Bool(l.Index%2 == 1), nil
+	case "empty":
+		return gad.Bool(l.Empty()), nil
+	case "parent":
+		if l.Parent == nil {
+			return gad.Nil, nil
+		}
+		return l.Parent, nil
+	case "next":
+		return &gad.Function{
+			FuncName: "giom.Loop.next",
+			Module:   ModuleSpec,
+			Value: func(gad.Call) (gad.Object, error) {
+				l.Next()
+				return gad.Nil, nil
+			},
+		}, nil
+	default:
+		return gad.Nil, nil
+	}
+}
+
+// BuiltinLoop implements giom.loop(iterable[, parent]), the constructor of the
+// implicit `loop` variable of a @for block.
+var BuiltinLoop = &gad.Function{
+	FuncName: "giom.loop",
+	Module:   ModuleSpec,
+	Value: func(call gad.Call) (_ gad.Object, err error) {
+		var parent *Loop
+		if call.Args.Length() == 2 {
+			parent, _ = call.Args.GetOnly(1).(*Loop)
+		} else if err = call.Args.CheckLen(1); err != nil {
+			return
+		}
+		return NewLoop(call.VM, call.Args.GetOnly(0), parent), nil
+	},
+}
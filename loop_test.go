@@ -0,0 +1,86 @@
+package giom
+
+import (
+	"testing"
+
+	"github.com/gad-lang/gad"
+)
+
+func TestForElse(t *testing.T) {
+	tpl := "@main\n" +
+		"    ul\n" +
+		"        @for x in xs\n" +
+		"            li {= x}\n" +
+		"        @else\n" +
+		"            li.empty none\n"
+	portExpect(t, tpl, `<ul><li>a</li><li>b</li></ul>`, gad.Dict{"xs": gad.Array{gad.Str("a"), gad.Str("b")}})
+	portExpect(t, tpl, `<ul><li class="empty">none</li></ul>`, gad.Dict{"xs": gad.Array{}})
+}
+
+func TestForLoopVariable(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    @for k, x in xs\n"+
+			"        | {= x}:{= loop.index}:{= loop.index1}/{= loop.length}\n"+
+			"        @if loop.first\n"+
+			"            | F\n"+
+			"        @if loop.last\n"+
+			"            | L\n"+
+			"        @if loop.odd\n"+
+			"            | o\n"+
+			"        @if loop.even\n"+
+			"            | e\n"+
+			"        | ;\n",
+		"a:0:1/3Fo;b:1:2/3e;c:2:3/3Lo;",
+		gad.Dict{"xs": gad.Array{gad.Str("a"), gad.Str("b"), gad.Str("c")}})
+}
+
+func TestForLoopParent(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    @for row in rows\n"+
+			"        @for c in row\n"+
+			"            | [{= loop.parent.index}.{= loop.index}={= c}]\n",
+		"[0.0=a][0.1=b][1.0=c]",
+		gad.Dict{"rows": gad.Array{
+			gad.Array{gad.Str("a"), gad.Str("b")},
+			gad.Array{gad.Str("c")},
+		}})
+}
+
+func TestWhileBreakContinue(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    @var i = 0\n"+
+			"    @while i < 10\n"+
+			"        ~ i += 1\n"+
+			"        @if i == 2\n"+
+			"            @continue\n"+
+			"        @if i == 4\n"+
+			"            @break\n"+
+			"        | {= i}\n",
+		"13", nil)
+}
+
+func TestForBreakContinue(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    ul\n"+
+			"        @for x in xs\n"+
+			"            @if x == 2\n"+
+			"                @continue\n"+
+			"            @if x == 4\n"+
+			"                @break\n"+
+			"            li {= loop.index}:{= x}\n",
+		"<ul><li>0:1</li><li>2:3</li></ul>",
+		gad.Dict{"xs": gad.Array{gad.Int(1), gad.Int(2), gad.Int(3), gad.Int(4), gad.Int(5)}})
+}
+
+func TestForNamedLoop(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    @for loop in loops\n"+
+			"        | {= loop}; loop\n",
+		"a; loopb; loop",
+		gad.Dict{"loops": gad.Array{gad.Str("a"), gad.Str("b")}})
+}
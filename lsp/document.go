@@ -0,0 +1,277 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	gadparser "github.com/gad-lang/gad/parser"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+// document is a template open in the client.
+type document struct {
+	uri  string
+	path string
+	text *text
+	// view is the last parse of the document that succeeded, used while the
+	// text does not parse; nil when it never did.
+	view *view
+	// parseErr is the error of the last parse.
+	parseErr error
+}
+
+// view is a parsed template.
+type view struct {
+	path string
+	uri  string
+	text *text
+	file *source.File
+	ast  *giomnode.File
+}
+
+// text is the content of a template with its line offsets.
+type text struct {
+	data  string
+	lines []int // offsets of the line starts
+}
+
+func newText(data string) *text {
+	t := &text{data: data, lines: []int{0}}
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\n' {
+			t.lines = append(t.lines, i+1)
+		}
+	}
+	return t
+}
+
+// line returns the line n without its line break, or "".
+func (t *text) line(n int) string {
+	if n < 0 || n >= len(t.lines) {
+		return ""
+	}
+	end := len(t.data)
+	if n+1 < len(t.lines) {
+		end = t.lines[n+1] - 1
+	}
+	return strings.TrimSuffix(t.data[t.lines[n]:end], "\r")
+}
+
+// position returns the LSP position of the byte offset off.
+func (t *text) position(off int) Position {
+	off = max(0, min(off, len(t.data)))
+	n := sort.Search(len(t.lines), func(i int) bool { return t.lines[i] > off }) - 1
+	if n < 0 {
+		n = 0
+	}
+	col := 0
+	for _, r := range t.data[t.lines[n]:off] {
+		col += len(utf16.Encode([]rune{r}))
+	}
+	return Position{Line: n, Character: col}
+}
+
+// offset returns the byte offset of the LSP position p.
+func (t *text) offset(p Position) int {
+	if p.Line >= len(t.lines) {
+		return len(t.data)
+	}
+	off := t.lines[p.Line]
+	line := t.line(p.Line)
+	for col := 0; col < p.Character && line != ""; {
+		r, size := utf8.DecodeRuneInString(line)
+		col += len(utf16.Encode([]rune{r}))
+		off += size
+		line = line[size:]
+	}
+	return off
+}
+
+// parse parses the template data at path. Parser panics are returned as
+// errors.
+func parse(path, uri, data string) (v *view, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	file := source.NewFileSet().AddFileData(path, -1, []byte(data))
+	parsed, err := giomparser.NewParser(file).ParseFile()
+	if err != nil {
+		return nil, err
+	}
+	return &view{path: path, uri: uri, text: newText(data), file: file, ast: parsed}, nil
+}
+
+// offset returns the byte offset of pos in the view.
+func (v *view) offset(pos source.Pos) int {
+	return int(pos) - v.file.Base
+}
+
+// rangeOf returns the range of pos..end.
+func (v *view) rangeOf(pos, end source.Pos) Range {
+	if end < pos {
+		end = pos
+	}
+	return Range{Start: v.text.position(v.offset(pos)), End: v.text.position(v.offset(end))}
+}
+
+// lineRange returns the range of the whole line holding pos.
+func (v *view) lineRange(pos source.Pos) Range {
+	start := v.text.position(v.offset(pos))
+	line := v.text.line(start.Line)
+	return Range{
+		Start: Position{Line: start.Line},
+		End:   Position{Line: start.Line, Character: len(utf16.Encode([]rune(line)))},
+	}
+}
+
+// headLine returns the source line of the node at pos, trimmed.
+func (v *view) headLine(pos source.Pos) string {
+	return strings.TrimSpace(v.text.line(v.text.position(v.offset(pos)).Line))
+}
+
+// decls returns the components and functions declared in the view, in
+// source order.
+func (v *view) decls() (decls []gnode.Stmt) {
+	giomnode.Walk(v.ast.Stmts, func(stmt gnode.Stmt) bool {
+		switch stmt.(type) {
+		case *giomnode.CompDecl, *giomnode.FuncDecl:
+			decls = append(decls, stmt)
+		}
+		return true
+	})
+	return
+}
+
+// decl returns the declaration of the component or function name.
+func (v *view) decl(name string) gnode.Stmt {
+	for _, d := range v.decls() {
+		switch d := d.(type) {
+		case *giomnode.CompDecl:
+			if !d.Main && (d.Name == name || d.ID == name) {
+				return d
+			}
+		case *giomnode.FuncDecl:
+			if d.Name == name {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// imports returns the `@import` directives of the view.
+func (v *view) imports() (imports []*giomnode.CodeStmt) {
+	for _, stmt := range v.ast.Stmts {
+		if c, ok := stmt.(*giomnode.CodeStmt); ok && c.Import != nil {
+			imports = append(imports, c)
+		}
+	}
+	return
+}
+
+// importPath returns the path of the module spec imports from the view.
+func (v *view) importPath(spec *giomnode.ImportSpec) string {
+	if filepath.IsAbs(spec.Path) {
+		return spec.Path
+	}
+	return filepath.Join(filepath.Dir(v.path), spec.Path)
+}
+
+// callAt returns the component call whose `+name` holds the byte offset off.
+func (v *view) callAt(off int) *giomnode.CompCallStmt {
+	var call *giomnode.CompCallStmt
+	giomnode.Walk(v.ast.Stmts, func(stmt gnode.Stmt) bool {
+		if c, ok := stmt.(*giomnode.CompCallStmt); ok {
+			if start := v.offset(c.NodePos); off >= start && off <= start+1+len(c.Name) {
+				call = c
+			}
+		}
+		return true
+	})
+	return call
+}
+
+// declAt returns the declaration whose head line holds the byte offset off.
+func (v *view) declAt(off int) gnode.Stmt {
+	line := v.text.position(off).Line
+	for _, d := range v.decls() {
+		if v.text.position(v.offset(d.Pos())).Line == line {
+			return d
+		}
+	}
+	return nil
+}
+
+// pathURI returns the file URI of path.
+func pathURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// uriPath returns the file path of a file URI, or the URI itself.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// diagnostics returns the diagnostics of a parse or compile error of the
+// template at path.
+func diagnostics(t *text, path string, err error) []Diagnostic {
+	var list gadparser.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		diags := make([]Diagnostic, len(list))
+		for i, e := range list {
+			diags[i] = diagnostic(t, e.Pos.Line, e.Pos.Column, e.Msg)
+		}
+		return diags
+	}
+	line, col := 0, 0
+	rgx := regexp.MustCompile(regexp.QuoteMeta(filepath.Base(path)) + `:(\d+)(?::(\d+))?`)
+	if m := rgx.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		col, _ = strconv.Atoi(m[2])
+	}
+	return []Diagnostic{diagnostic(t, line, col, err.Error())}
+}
+
+// diagnostic returns an error on the 1-based line and byte column, which
+// spans to the end of the line; the first line when line is 0.
+func diagnostic(t *text, line, col int, msg string) Diagnostic {
+	if line < 1 {
+		line = 1
+	}
+	if line > len(t.lines) {
+		line = len(t.lines)
+	}
+	src := t.line(line - 1)
+	if col < 1 || col > len(src) {
+		col = len(src) - len(strings.TrimLeft(src, " \t")) + 1
+	}
+	start := t.position(t.lines[line-1] + col - 1)
+	end := Position{Line: start.Line, Character: len(utf16.Encode([]rune(src)))}
+	return Diagnostic{
+		Range:    Range{Start: start, End: end},
+		Severity: SeverityError,
+		Source:   "giom",
+		Message:  msg,
+	}
+}
@@ -0,0 +1,536 @@
+// Package lsp implements a Language Server Protocol server for Giom
+// templates, run by `giom lsp`. It serves one client over a stream with:
+//
+//   - diagnostics: the parse errors of an open template as it changes, and
+//     its compile errors, imports included, when it is opened or saved;
+//   - go to definition of `+component` calls and of the names and files of
+//     `@import` lines, across files;
+//   - hover on a component call or declaration: its parameters and slots;
+//   - completion of directives after `@`, component names after `+` and slot
+//     names after `@slot #`;
+//   - document symbols: the `@comp` and `@func` declarations with their slots.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+
+	"github.com/gad-lang/gad/giom"
+	giomnode "github.com/gad-lang/gad/giom/node"
+)
+
+// Server is a language server for the templates open in one client.
+type Server struct {
+	// Globals are declared when templates are compiled for diagnostics, as
+	// the keys of the globals passed to giom.Render are.
+	Globals []string
+
+	render   *giom.Render
+	docs     map[string]*document
+	out      io.Writer
+	writeErr error
+}
+
+// NewServer returns a server with no open documents.
+func NewServer() *Server {
+	return &Server{render: giom.NewRender(""), docs: map[string]*document{}}
+}
+
+// Serve reads the client messages from in and writes the responses and
+// notifications to out, until the client sends exit or in ends.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	r := bufio.NewReader(in)
+	for s.writeErr == nil {
+		data, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err = json.Unmarshal(data, &req); err != nil {
+			s.write(response(nil, nil, &responseError{codeParseError, err.Error()}))
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, rerr := s.handle(req)
+		if req.ID != nil {
+			s.write(response(req.ID, result, rerr))
+		}
+	}
+	return s.writeErr
+}
+
+func (s *Server) write(msg any) {
+	if s.writeErr == nil {
+		s.writeErr = writeMessage(s.out, msg)
+	}
+}
+
+// handle runs the method of req; notifications return no result.
+func (s *Server) handle(req request) (any, *responseError) {
+	switch req.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       1,
+				DefinitionProvider:     true,
+				HoverProvider:          true,
+				CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"@", "+", "#", "."}},
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "giom"},
+		}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		return call(req.Params, s.didOpen)
+	case "textDocument/didChange":
+		return call(req.Params, s.didChange)
+	case "textDocument/didSave":
+		return call(req.Params, s.didSave)
+	case "textDocument/didClose":
+		return call(req.Params, s.didClose)
+	case "textDocument/definition":
+		return call(req.Params, s.definition)
+	case "textDocument/hover":
+		return call(req.Params, s.hover)
+	case "textDocument/completion":
+		return call(req.Params, s.completion)
+	case "textDocument/documentSymbol":
+		return call(req.Params, s.documentSymbol)
+	}
+	if req.ID == nil {
+		// Unknown notifications, such as $/cancelRequest, are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+}
+
+// call decodes params and passes them to f.
+func call[P any](params json.RawMessage, f func(P) any) (any, *responseError) {
+	var p P
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	return f(p), nil
+}
+
+// =============================================================================
+// Documents and diagnostics
+// =============================================================================
+
+func (s *Server) didOpen(p DidOpenTextDocumentParams) any {
+	doc := &document{uri: p.TextDocument.URI, path: uriPath(p.TextDocument.URI)}
+	s.docs[doc.uri] = doc
+	s.update(doc, p.TextDocument.Text)
+	s.publish(doc, true)
+	return nil
+}
+
+func (s *Server) didChange(p DidChangeTextDocumentParams) any {
+	doc := s.docs[p.TextDocument.URI]
+	if doc == nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	s.update(doc, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	s.publish(doc, false)
+	return nil
+}
+
+func (s *Server) didSave(p DidSaveTextDocumentParams) any {
+	if doc := s.docs[p.TextDocument.URI]; doc != nil {
+		s.publish(doc, true)
+	}
+	return nil
+}
+
+func (s *Server) didClose(p DidCloseTextDocumentParams) any {
+	delete(s.docs, p.TextDocument.URI)
+	s.write(notification("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}}))
+	return nil
+}
+
+// update sets the text of doc and parses it.
+func (s *Server) update(doc *document, data string) {
+	doc.text = newText(data)
+	v, err := parse(doc.path, doc.uri, data)
+	doc.parseErr = err
+	if err == nil {
+		doc.view = v
+	}
+}
+
+// publish sends the diagnostics of doc: its parse errors, else, with compile,
+// its compile errors.
+func (s *Server) publish(doc *document, compile bool) {
+	diags := []Diagnostic{}
+	if doc.parseErr != nil {
+		diags = diagnostics(doc.text, doc.path, doc.parseErr)
+	} else if compile {
+		if err := s.check(doc); err != nil {
+			diags = diagnostics(doc.text, doc.path, err)
+		}
+	}
+	s.write(notification("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: doc.uri, Diagnostics: diags}))
+}
+
+// check compiles doc; compiler panics are returned as errors.
+func (s *Server) check(doc *document) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return s.render.CheckSource(doc.path, []byte(doc.text.data), s.Globals...)
+}
+
+// load returns the parsed template at path: the open document, else the file.
+func (s *Server) load(path string) *view {
+	uri := pathURI(path)
+	if doc := s.docs[uri]; doc != nil {
+		return doc.view
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	v, _ := parse(path, uri, string(data))
+	return v
+}
+
+// viewAt returns the parsed document of p and the byte offset of its
+// position.
+func (s *Server) viewAt(p TextDocumentPositionParams) (*view, int) {
+	doc := s.docs[p.TextDocument.URI]
+	if doc == nil || doc.view == nil {
+		return nil, 0
+	}
+	return doc.view, doc.view.text.offset(p.Position)
+}
+
+// =============================================================================
+// Definitions and hover
+// =============================================================================
+
+// target is what a position refers to: a declaration, or a whole template
+// when decl is nil.
+type target struct {
+	view *view
+	decl gnode.Stmt
+}
+
+func (t *target) location() Location {
+	if t.decl == nil {
+		return Location{URI: t.view.uri}
+	}
+	return Location{URI: t.view.uri, Range: t.view.lineRange(t.decl.Pos())}
+}
+
+// target returns what the byte offset off of v refers to: the component of a
+// `+name` call, the file of an `@import` line or the declaration of one of its
+// names, or the declaration whose head holds off.
+func (s *Server) target(v *view, off int) *target {
+	if c := v.callAt(off); c != nil {
+		return s.resolve(v, c.Name)
+	}
+	line := v.text.position(off).Line
+	for _, imp := range v.imports() {
+		if v.text.position(v.offset(imp.NodePos)).Line != line {
+			continue
+		}
+		iv := s.load(v.importPath(imp.Import))
+		if iv == nil {
+			return nil
+		}
+		if name, ok := imp.Import.Names[wordAt(v.text.data, off)]; ok {
+			if d := iv.decl(name); d != nil {
+				return &target{view: iv, decl: d}
+			}
+		}
+		return &target{view: iv}
+	}
+	if d := v.declAt(off); d != nil {
+		return &target{view: v, decl: d}
+	}
+	return nil
+}
+
+// resolve returns the declaration of the component or function a call in v
+// names: declared in v, imported by a destructured `@import` or, for
+// `alias.name`, exported by the module imported `as alias`.
+func (s *Server) resolve(v *view, name string) *target {
+	if d := v.decl(name); d != nil {
+		return &target{view: v, decl: d}
+	}
+	qualifier, member, qualified := "", name, false
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		qualifier, member, qualified = name[:i], name[i+1:], true
+	}
+	for _, imp := range v.imports() {
+		exported, ok := imp.Import.Names[name]
+		if qualified {
+			exported, ok = member, imp.Import.Alias == qualifier
+		}
+		if !ok {
+			continue
+		}
+		if iv := s.load(v.importPath(imp.Import)); iv != nil {
+			if d := iv.decl(exported); d != nil {
+				return &target{view: iv, decl: d}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) definition(p TextDocumentPositionParams) any {
+	v, off := s.viewAt(p)
+	if v == nil {
+		return nil
+	}
+	if t := s.target(v, off); t != nil {
+		return []Location{t.location()}
+	}
+	return nil
+}
+
+func (s *Server) hover(p TextDocumentPositionParams) any {
+	v, off := s.viewAt(p)
+	if v == nil {
+		return nil
+	}
+	t := s.target(v, off)
+	if t == nil || t.decl == nil {
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: t.view.describe(t.decl)}}
+}
+
+// describe returns the markdown hover text of a declaration: its head line
+// and, for a component, its slots.
+func (v *view) describe(decl gnode.Stmt) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "```giom\n%s\n```", v.headLine(decl.Pos()))
+	if c, ok := decl.(*giomnode.CompDecl); ok && len(c.Slots) > 0 {
+		names := make([]string, len(c.Slots))
+		for i, slot := range c.Slots {
+			names[i] = "`" + slot.Name + "`"
+		}
+		b.WriteString("\n\nSlots: " + strings.Join(names, ", "))
+	}
+	if v.path != "" {
+		fmt.Fprintf(&b, "\n\nDeclared in `%s`", v.path)
+	}
+	return b.String()
+}
+
+// wordAt returns the identifier holding the byte offset off of data.
+func wordAt(data string, off int) string {
+	isWord := func(c byte) bool {
+		return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	start, end := off, off
+	for start > 0 && isWord(data[start-1]) {
+		start--
+	}
+	for end < len(data) && isWord(data[end]) {
+		end++
+	}
+	return data[start:end]
+}
+
+// =============================================================================
+// Completion
+// =============================================================================
+
+// directives are the completions after `@` at the start of a line.
+var directives = []struct{ name, detail string }{
+	{"if", "@if cond"},
+	{"else", "@else, @else if cond"},
+	{"for", "@for item in items"},
+	{"while", "@while cond"},
+	{"break", "@break"},
+	{"continue", "@continue"},
+	{"match", "@match value"},
+	{"case", "@case value"},
+	{"main", "@main"},
+	{"comp", "@comp name(params)"},
+	{"func", "@func name(params)"},
+	{"export", "@export comp name(params)"},
+	{"slot", "@slot name, @slot #name"},
+	{"wrap", "@wrap"},
+	{"import", `@import "file.giom" as name`},
+	{"var", "@var (name = value)"},
+	{"const", "@const (name = value)"},
+	{"global", "@global name"},
+	{"enum", "@enum Name(A, B)"},
+	{"whitespace", "@whitespace preserve"},
+	{"verbatim", "@verbatim"},
+	{"stack", "@stack name"},
+	{"push", "@push name"},
+	{"once", "@once"},
+}
+
+var (
+	rgxDirectivePrefix = regexp.MustCompile(`^@\w*$`)
+	rgxCallPrefix      = regexp.MustCompile(`^\+[\w.$@-]*$`)
+	rgxSlotPrefix      = regexp.MustCompile(`^@slot\s+#[\w-]*$`)
+	rgxCallLine        = regexp.MustCompile(`^\+([@$A-Za-z_-]+[.\w]*)`)
+)
+
+func (s *Server) completion(p TextDocumentPositionParams) any {
+	doc := s.docs[p.TextDocument.URI]
+	if doc == nil || p.Position.Line >= len(doc.text.lines) {
+		return nil
+	}
+	prefix := doc.text.data[doc.text.lines[p.Position.Line]:doc.text.offset(p.Position)]
+	code := strings.TrimLeft(prefix, " \t")
+
+	var items []CompletionItem
+	switch {
+	case rgxSlotPrefix.MatchString(code):
+		items = s.slotCompletions(doc, p.Position.Line, len(prefix)-len(code))
+	case rgxDirectivePrefix.MatchString(code):
+		for _, d := range directives {
+			items = append(items, CompletionItem{Label: d.name, Kind: CompletionKeyword, Detail: d.detail})
+		}
+	case rgxCallPrefix.MatchString(code):
+		items = s.callCompletions(doc)
+	}
+	return items
+}
+
+// callCompletions returns the components a `+` call in doc may name.
+func (s *Server) callCompletions(doc *document) (items []CompletionItem) {
+	v := doc.view
+	if v == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	add := func(label string, v *view, decl gnode.Stmt) {
+		if c, ok := decl.(*giomnode.CompDecl); !ok || c.Main || seen[label] {
+			return
+		}
+		seen[label] = true
+		items = append(items, CompletionItem{Label: label, Kind: CompletionClass, Detail: v.headLine(decl.Pos())})
+	}
+	for _, d := range v.decls() {
+		if c, ok := d.(*giomnode.CompDecl); ok {
+			add(c.Name, v, d)
+		}
+	}
+	for _, imp := range v.imports() {
+		iv := s.load(v.importPath(imp.Import))
+		if iv == nil {
+			continue
+		}
+		for local, name := range imp.Import.Names {
+			if d := iv.decl(name); d != nil {
+				add(local, iv, d)
+			}
+		}
+		if imp.Import.Alias != "" {
+			for _, d := range iv.decls() {
+				if c, ok := d.(*giomnode.CompDecl); ok && c.Exported {
+					add(imp.Import.Alias+"."+c.Name, iv, d)
+				}
+			}
+		}
+	}
+	return
+}
+
+// slotCompletions returns the slots of the component called by the `+name`
+// line holding the `@slot #` line n of doc, indented by indent.
+func (s *Server) slotCompletions(doc *document, n, indent int) (items []CompletionItem) {
+	if doc.view == nil {
+		return nil
+	}
+	for l := n - 1; l >= 0; l-- {
+		line := doc.text.line(l)
+		code := strings.TrimLeft(line, " \t")
+		if code == "" || len(line)-len(code) >= indent {
+			continue
+		}
+		m := rgxCallLine.FindStringSubmatch(code)
+		if m == nil {
+			return nil
+		}
+		t := s.resolve(doc.view, m[1])
+		if t == nil {
+			return nil
+		}
+		if c, ok := t.decl.(*giomnode.CompDecl); ok {
+			for _, slot := range c.Slots {
+				items = append(items, CompletionItem{Label: slot.Name, Kind: CompletionField, Detail: t.view.headLine(slot.NodePos)})
+			}
+		}
+		return
+	}
+	return nil
+}
+
+// =============================================================================
+// Document symbols
+// =============================================================================
+
+func (s *Server) documentSymbol(p DocumentSymbolParams) any {
+	doc := s.docs[p.TextDocument.URI]
+	if doc == nil || doc.view == nil {
+		return nil
+	}
+	return doc.view.symbols(doc.view.ast.Stmts)
+}
+
+// symbols returns the component and function declarations in stmts, with
+// the slots and the declarations nested in each component.
+func (v *view) symbols(stmts gnode.Stmts) []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	giomnode.Walk(stmts, func(stmt gnode.Stmt) bool {
+		switch d := stmt.(type) {
+		case *giomnode.CompDecl:
+			name := d.Name
+			if d.Main {
+				name = "@main"
+			}
+			sym := v.symbol(name, d.ParamsRaw, SymbolClass, d.NodePos, d.NodeEnd)
+			for _, slot := range d.Slots {
+				sym.Children = append(sym.Children, v.symbol(slot.Name, slot.ScopeRaw, SymbolField, slot.NodePos, slot.NodeEnd))
+			}
+			sym.Children = append(sym.Children, v.symbols(d.Body)...)
+			syms = append(syms, sym)
+			return false
+		case *giomnode.FuncDecl:
+			syms = append(syms, v.symbol(d.Name, d.ParamsRaw, SymbolFunction, d.NodePos, d.NodeEnd))
+			return false
+		}
+		return true
+	})
+	return syms
+}
+
+// symbol returns the symbol of a declaration from pos to end, selected on its
+// head line.
+func (v *view) symbol(name, detail string, kind int, pos, end source.Pos) DocumentSymbol {
+	head := v.lineRange(pos)
+	r := Range{Start: head.Start, End: v.text.position(v.offset(end))}
+	if r.End.Line <= head.End.Line {
+		r.End = head.End
+	}
+	return DocumentSymbol{Name: name, Detail: detail, Kind: kind, Range: r, SelectionRange: head}
+}
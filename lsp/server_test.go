@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// session holds the messages a client sends to a server.
+type session struct {
+	in bytes.Buffer
+	id int
+}
+
+// request adds a request and returns its id.
+func (c *session) request(method string, params any) int {
+	c.id++
+	c.write(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	return c.id
+}
+
+func (c *session) notify(method string, params any) {
+	c.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *session) write(msg any) {
+	if err := writeMessage(&c.in, msg); err != nil {
+		panic(err)
+	}
+}
+
+// serverMessage is a response or notification written by the server.
+type serverMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// run serves the session and returns the responses by id and the
+// diagnostics published by URI, the last ones of each document.
+func (c *session) run(t *testing.T) (map[int]serverMessage, map[string][]Diagnostic) {
+	t.Helper()
+	c.notify("exit", nil)
+	var out bytes.Buffer
+	require.NoError(t, NewServer().Serve(&c.in, &out))
+
+	responses := map[int]serverMessage{}
+	diags := map[string][]Diagnostic{}
+	r := bufio.NewReader(&out)
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		var msg serverMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		switch {
+		case msg.ID != nil:
+			responses[*msg.ID] = msg
+		case msg.Method == "textDocument/publishDiagnostics":
+			var p PublishDiagnosticsParams
+			require.NoError(t, json.Unmarshal(msg.Params, &p))
+			diags[p.URI] = p.Diagnostics
+		}
+	}
+	return responses, diags
+}
+
+func result[T any](t *testing.T, msg serverMessage) T {
+	t.Helper()
+	require.Nil(t, msg.Error)
+	var v T
+	require.NoError(t, json.Unmarshal(msg.Result, &v))
+	return v
+}
+
+func at(uri string, line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	}
+}
+
+const libSrc = "@export comp card(title; slots={})\n" +
+	"    div.card\n" +
+	"        h2 {= title}\n" +
+	"        @slot main\n" +
+	"        @slot footer\n"
+
+const pageSrc = "@import { card } from \"lib.giom\"\n" +
+	"@import \"lib.giom\" as lib\n" +
+	"@comp local(x)\n" +
+	"    p {= x}\n" +
+	"@main\n" +
+	"    +card(\"A\")\n" +
+	"        @slot #footer\n" +
+	"            p f\n" +
+	"    +lib.card(\"B\")\n" +
+	"    +local(1)\n"
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.giom"), []byte(libSrc), 0644))
+	page, lib := pathURI(filepath.Join(dir, "page.giom")), pathURI(filepath.Join(dir, "lib.giom"))
+
+	var c session
+	initID := c.request("initialize", map[string]any{})
+	c.notify("initialized", map[string]any{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: page, Text: pageSrc}})
+	defCall := c.request("textDocument/definition", at(page, 5, 6))
+	defAlias := c.request("textDocument/definition", at(page, 8, 10))
+	defLocal := c.request("textDocument/definition", at(page, 9, 5))
+	defImport := c.request("textDocument/definition", at(page, 0, 11))
+	hover := c.request("textDocument/hover", at(page, 5, 6))
+	directives := c.request("textDocument/completion", at(page, 4, 1))
+	calls := c.request("textDocument/completion", at(page, 9, 5))
+	slots := c.request("textDocument/completion", at(page, 6, 15))
+	symbols := c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: page}})
+	unknown := c.request("textDocument/rename", at(page, 0, 0))
+	shutdown := c.request("shutdown", nil)
+	responses, diags := c.run(t)
+
+	init := result[InitializeResult](t, responses[initID])
+	require.True(t, init.Capabilities.DefinitionProvider)
+	require.Empty(t, diags[page])
+
+	cardDecl := Location{URI: lib, Range: Range{End: Position{Character: 34}}}
+	require.Equal(t, []Location{cardDecl}, result[[]Location](t, responses[defCall]))
+	require.Equal(t, []Location{cardDecl}, result[[]Location](t, responses[defAlias]))
+	require.Equal(t, []Location{{URI: page, Range: Range{Start: Position{Line: 2}, End: Position{Line: 2, Character: 14}}}},
+		result[[]Location](t, responses[defLocal]))
+	require.Equal(t, []Location{cardDecl}, result[[]Location](t, responses[defImport]))
+
+	h := result[Hover](t, responses[hover])
+	require.Contains(t, h.Contents.Value, "```giom\n@export comp card(title; slots={})\n```")
+	require.Contains(t, h.Contents.Value, "Slots: `main`, `footer`")
+
+	labels := func(id int) []string {
+		var names []string
+		for _, item := range result[[]CompletionItem](t, responses[id]) {
+			names = append(names, item.Label)
+		}
+		return names
+	}
+	require.Subset(t, labels(directives), []string{"if", "for", "comp", "slot", "import"})
+	require.ElementsMatch(t, []string{"local", "card", "lib.card"}, labels(calls))
+	require.Equal(t, []string{"main", "footer"}, labels(slots))
+
+	syms := result[[]DocumentSymbol](t, responses[symbols])
+	require.Len(t, syms, 2)
+	require.Equal(t, "local", syms[0].Name)
+	require.Equal(t, "x", syms[0].Detail)
+	require.Equal(t, Range{Start: Position{Line: 2}, End: Position{Line: 2, Character: 14}}, syms[0].SelectionRange)
+	require.Equal(t, "@main", syms[1].Name)
+
+	require.Equal(t, codeMethodNotFound, responses[unknown].Error.Code)
+	require.Equal(t, "null", string(responses[shutdown].Result))
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	uri := pathURI(filepath.Join(dir, "page.giom"))
+
+	var c session
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: "@main\n    p ok\n"}})
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": "@main\n    @else\n"}},
+	})
+	_, parseDiags := c.run(t)
+	require.Len(t, parseDiags[uri], 1)
+	require.Contains(t, parseDiags[uri][0].Message, "unexpected ELSE without matching @if")
+	require.Equal(t, 1, parseDiags[uri][0].Range.Start.Line)
+
+	c = session{}
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: "@main\n    p {= undefinedVar}\n"}})
+	_, compileDiags := c.run(t)
+	require.Len(t, compileDiags[uri], 1)
+	require.Contains(t, compileDiags[uri][0].Message, `unresolved reference "undefinedVar"`)
+	require.Equal(t, 1, compileDiags[uri][0].Range.Start.Line)
+}
+
+func TestText(t *testing.T) {
+	txt := newText("ab\n\tπ𝄞x\n")
+	require.Equal(t, Position{Line: 1, Character: 4}, txt.position(strings.Index(txt.data, "x")))
+	require.Equal(t, strings.Index(txt.data, "x"), txt.offset(Position{Line: 1, Character: 4}))
+	require.Equal(t, "\tπ𝄞x", txt.line(1))
+	require.Equal(t, "", txt.line(5))
+}
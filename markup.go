@@ -0,0 +1,225 @@
+package giom
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gad-lang/gad"
+	giomnode "github.com/gad-lang/gad/giom/node"
+)
+
+// MarkupMode selects the serialisation dialect of a render tree.
+type MarkupMode uint8
+
+const (
+	// HTML is the default mode: the giom void elements (see
+	// node.IsSelfClosing) are written as `<br />`, every other element with an
+	// explicit close tag.
+	HTML MarkupMode = iota
+	// HTML5 writes the HTML5 void elements without a slash (`<br>`).
+	HTML5
+	// XHTML self-closes void and empty elements (`<br />`, `<div />`) and
+	// escapes text and attribute values for XML.
+	XHTML
+	// XML self-closes every empty element (`<item/>`), has no void elements
+	// unless configured, and escapes text and attribute values for XML.
+	XML
+)
+
+// html5Void is the HTML5 void element set used by the HTML5 and XHTML modes.
+var html5Void = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Markup configures how a render tree is written. The zero value is the HTML
+// mode used by Element.WriteTo.
+type Markup struct {
+	Mode MarkupMode
+	// Void adds to the mode's void element set. Void elements never have
+	// children and always self-close (HTML5 writes them as `<x>`), which lets
+	// custom elements opt in.
+	Void map[string]bool
+	// ReplaceVoid makes Void replace the mode's void element set instead.
+	ReplaceVoid bool
+}
+
+// VoidElements returns a void element set holding names, for Markup.Void.
+func VoidElements(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+// DetectMarkup returns the markup implied by a render tree: a tree whose first
+// text starts with an XML prolog (`!!! xml`) is written as XML, anything else
+// as HTML.
+func DetectMarkup(el Element) Markup {
+	if t, ok := firstText(el); ok && len(t) > 0 {
+		if rs, ok := t[0].(gad.RawStr); ok && strings.HasPrefix(strings.TrimSpace(string(rs)), "<?xml") {
+			return Markup{Mode: XML}
+		}
+	}
+	return Markup{}
+}
+
+// firstText returns the first text node of el in document order, stopping at
+// the first named tag.
+func firstText(el Element) (Text, bool) {
+	switch e := el.(type) {
+	case Text:
+		return e, true
+	case *Tag:
+		if e.Name != "" || len(e.Children) == 0 {
+			return nil, false
+		}
+		return firstText(e.Children[0])
+	}
+	return nil, false
+}
+
+// Write serialises el (and its subtree) to w in this markup. The tree is
+// written as it is: call ResolveStacks first on a tree built by running a
+// template directly.
+func (m Markup) Write(vm *gad.VM, w io.Writer, el Element) (int64, error) {
+	return writeWithMarkup(vm, w, el, m)
+}
+
+// isVoid reports whether name is a void element in this markup.
+func (m Markup) isVoid(name string) bool {
+	if m.Void[name] {
+		return true
+	}
+	if m.ReplaceVoid {
+		return false
+	}
+	switch m.Mode {
+	case HTML5, XHTML:
+		return html5Void[name]
+	case XML:
+		return false
+	default:
+		return giomnode.IsSelfClosing(name)
+	}
+}
+
+// isXML reports whether values are escaped for XML and empty elements
+// self-close.
+func (m Markup) isXML() bool {
+	return m.Mode == XHTML || m.Mode == XML
+}
+
+// selfClose returns the terminator of a self-closed element.
+func (m Markup) selfClose() string {
+	switch m.Mode {
+	case HTML5:
+		return ">"
+	case XML:
+		return "/>"
+	default:
+		return " />"
+	}
+}
+
+// markupWriter is implemented by elements whose output depends on the markup
+// (tags and the containers holding tags), so the markup reaches the whole
+// subtree through the fixed gad.ToWriter signature.
+type markupWriter interface {
+	writeMarkup(vm *gad.VM, w io.Writer, m Markup) (int64, error)
+}
+
+// writeWithMarkup writes el in markup m.
+func writeWithMarkup(vm *gad.VM, w io.Writer, el Element, m Markup) (int64, error) {
+	switch e := el.(type) {
+	case markupWriter:
+		return e.writeMarkup(vm, w, m)
+	case Text:
+		if m.isXML() {
+			return e.writeXML(vm, w)
+		}
+	}
+	return el.WriteTo(vm, w)
+}
+
+// xmlEscaper escapes text and attribute values for XML.
+var xmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+// xmlString returns the text of a value for XML output.
+func xmlString(vm *gad.VM, v gad.Object) string {
+	switch t := v.(type) {
+	case gad.Str:
+		return string(t)
+	case gad.RawStr:
+		return string(t)
+	}
+	if vm != nil {
+		if s, err := gad.ToStr(vm, v); err == nil {
+			return string(s)
+		}
+	}
+	return v.ToString()
+}
+
+// writeXML writes the text values escaped for XML; a RawStr is still written
+// verbatim.
+func (t Text) writeXML(vm *gad.VM, w io.Writer) (int64, error) {
+	var wc writeCounter
+	for _, v := range t {
+		if rs, ok := v.(gad.RawStr); ok {
+			wc.writeString(w, string(rs))
+		} else {
+			wc.writeString(w, xmlEscaper.Replace(xmlString(vm, v)))
+		}
+	}
+	return wc.n, wc.err
+}
+
+// xmlAttrValue formats an attribute value for XML the way AttrFunc does for
+// HTML: a falsy value drops the attribute, a true Flag repeats the name (XML
+// has no minimised attributes) and an Array joins its truthy items.
+func xmlAttrValue(vm *gad.VM, name string, value gad.Object) (string, bool) {
+	if value.IsFalsy() {
+		return "", false
+	}
+	switch t := value.(type) {
+	case gad.Flag:
+		return name, true
+	case gad.Array:
+		parts := make([]string, 0, len(t))
+		for _, o := range t {
+			if !o.IsFalsy() {
+				parts = append(parts, xmlString(vm, o))
+			}
+		}
+		return strings.Join(parts, " "), true
+	default:
+		return xmlString(vm, value), true
+	}
+}
+
+// writeXMLAttrs renders the tag's attributes with XML escaping.
+func (t *Tag) writeXMLAttrs(vm *gad.VM, w io.Writer, wc *writeCounter) error {
+	for _, name := range t.attrOrder {
+		if v, ok := xmlAttrValue(vm, name, t.Attrs[name]); ok {
+			wc.writeString(w, " "+name+`="`+xmlEscaper.Replace(v)+`"`)
+		}
+	}
+	if len(t.ClassList) > 0 {
+		wc.writeString(w, ` class="`+xmlEscaper.Replace(strings.Join(t.ClassList, " "))+`"`)
+	}
+	if len(t.Styles) > 0 {
+		wc.writeString(w, ` style="`+xmlEscaper.Replace(strings.Join(t.Styles, "; "))+`"`)
+	}
+	return wc.err
+}
+
+var _ markupWriter = (*Tag)(nil)
@@ -0,0 +1,91 @@
+package giom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gad-lang/gad"
+)
+
+func writeMarkup(t *testing.T, m Markup, el Element) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := m.Write(newElementVM(), &buf, el); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return buf.String()
+}
+
+func TestMarkupModes(t *testing.T) {
+	tree := func() *Tag {
+		root := NewTag(nil, "div", nil, gad.KeyValueArray{
+			{K: gad.Str("title"), V: gad.Str("t")},
+			{K: gad.Str("hidden"), V: gad.Flag(true)},
+		})
+		NewTag(root, "br", nil, nil)
+		NewTag(root, "span", nil, nil)
+		NewTag(root, "wbr", nil, nil)
+		root.Children = append(root.Children, Text{gad.Str("x"), gad.RawStr("<b>ok</b>")})
+		return root
+	}
+
+	tests := []struct {
+		name string
+		m    Markup
+		want string
+	}{
+		{"html", Markup{},
+			`<div title="t" hidden><br /><span></span><wbr></wbr>x<b>ok</b></div>`},
+		{"html5", Markup{Mode: HTML5},
+			`<div title="t" hidden><br><span></span><wbr>x<b>ok</b></div>`},
+		{"xhtml", Markup{Mode: XHTML},
+			`<div title="t" hidden="hidden"><br /><span /><wbr />x<b>ok</b></div>`},
+		{"xml", Markup{Mode: XML},
+			`<div title="t" hidden="hidden"><br/><span/><wbr/>x<b>ok</b></div>`},
+		{"custom void", Markup{Mode: HTML5, Void: VoidElements("span")},
+			`<div title="t" hidden><br><span><wbr>x<b>ok</b></div>`},
+		{"replaced void", Markup{Mode: HTML5, Void: VoidElements("br"), ReplaceVoid: true},
+			`<div title="t" hidden><br><span></span><wbr></wbr>x<b>ok</b></div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeMarkup(t, tt.m, tree()); got != tt.want {
+				t.Fatalf("\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMarkupXMLEscaping verifies XML modes escape text and attribute values
+// while raw strings stay verbatim.
+func TestMarkupXMLEscaping(t *testing.T) {
+	el := NewTag(nil, "item", []Element{Text{gad.Str(`x < y & "z"`), gad.RawStr("<![CDATA[<a>]]>")}},
+		gad.KeyValueArray{{K: gad.Str("title"), V: gad.Str(`a "b" & c`)}})
+	want := `<item title="a &quot;b&quot; &amp; c">x &lt; y &amp; &quot;z&quot;<![CDATA[<a>]]></item>`
+	if got := writeMarkup(t, Markup{Mode: XML}, el); got != want {
+		t.Fatalf("\n got: %s\nwant: %s", got, want)
+	}
+}
+
+// TestMarkupWriteToDefault verifies Tag.WriteTo keeps the default HTML output.
+func TestMarkupWriteToDefault(t *testing.T) {
+	img := NewTag(nil, "img", nil, gad.KeyValueArray{{K: gad.Str("src"), V: gad.Str("/x")}})
+	if got, want := writeElement(t, img), writeMarkup(t, Markup{}, img); got != want {
+		t.Fatalf("\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestDetectMarkup(t *testing.T) {
+	got, err := portRun(t, "!!! xml\n@main\n    feed\n        entry\n            title News\n        entry\n", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="utf-8" ?><feed><entry><title>News</title></entry><entry/></feed>`
+	if got != want {
+		t.Fatalf("\n got: %s\nwant: %s", got, want)
+	}
+
+	if m := DetectMarkup(NewTag(nil, "html", nil, nil)); m.Mode != HTML {
+		t.Fatalf("html tree detected as %v", m.Mode)
+	}
+}
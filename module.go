@@ -14,11 +14,23 @@ func newModule() gad.Dict {
 		// # giom module
 		// ## Types
 		// Tag is a tag element type; Text wraps a value as a text node.
-		"Tag":    TagType,
-		"Text":   TextType,
-		"escape": BuiltinEscape,
-		"attr":   BuiltinAttr,
-		"attrs":  BuiltinAttrs,
-		"write":  BuiltinTextWrite,
+		"Tag":       TagType,
+		"Text":      TextType,
+		"escape":    BuiltinEscape,
+		"attr":      BuiltinAttr,
+		"attrs":     BuiltinAttrs,
+		"write":     BuiltinTextWrite,
+		"toJSON":    BuiltinToJSON,
+		"loop":      BuiltinLoop,
+		"toggle":    BuiltinToggle,
+		"merge":     BuiltinMerge,
+		"js":        BuiltinJS,
+		"css":       BuiltinCSS,
+		"stack":     BuiltinStack,
+		"push":      BuiltinPush,
+		"once":      BuiltinOnce,
+		"cover":     BuiltinCover,
+		"profEnter": BuiltinProfEnter,
+		"profExit":  BuiltinProfExit,
 	}
 }
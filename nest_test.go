@@ -0,0 +1,44 @@
+package giom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gad-lang/gad/parser/source"
+	"github.com/stretchr/testify/require"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+func TestInlineNest(t *testing.T) {
+	portExpect(t,
+		"@main\n"+
+			"    ul.menu\n"+
+			"        li: a[href=\"/\"] Home\n"+
+			"        li.on: a[href=\"/docs\"]: b Docs\n"+
+			"        li: svg:g\n",
+		`<ul class="menu"><li><a href="/">Home</a></li><li class="on"><a href="/docs"><b>Docs</b></a></li><li><svg:g></svg:g></li></ul>`,
+		nil)
+}
+
+func TestInlineNestGiomRoundTrip(t *testing.T) {
+	src := "nav#top: ul.menu: li: a[href=\"/\"]\n" +
+		"    b Home\n"
+	f := source.NewFileSet().AddFileData("nest.giom", -1, []byte(src))
+	file, err := giomparser.NewParser(f).ParseFile()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	ctx := giomnode.NewGiomCodeContext(&buf)
+	ctx.Prefix = "    "
+	file.WriteGiom(ctx)
+	require.Contains(t, buf.String(), "nav#top: ul.menu: li: a[href=\"/\"]\n    b Home\n")
+
+	want, err := portRun(t, src, nil, nil)
+	require.NoError(t, err)
+	got, err := portRun(t, buf.String(), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.Equal(t, `<nav id="top"><ul class="menu"><li><a href="/"><b>Home</b></a></li></ul></nav>`, got)
+}
@@ -167,6 +167,20 @@ func convertStmt(s gnode.Stmt) gnode.Stmts {
 		return convertAssign(st)
 	case *ForStmt:
 		return convertFor(st)
+	case *WhileStmt:
+		return convertWhile(st)
+	case *BranchStmt:
+		return convertBranch(st)
+	case *VerbatimStmt:
+		return convertVerbatim(st)
+	case *EmbedStmt:
+		return convertEmbed(st)
+	case *StackStmt:
+		return convertStack(st)
+	case *PushStmt:
+		return convertPush(st)
+	case *OnceStmt:
+		return convertOnce(st)
 	case *IfStmt:
 		return convertIf(st)
 	case *DoctypeStmt:
@@ -678,75 +692,188 @@ func convertSlotPass(s *SlotPassStmt) gnode.Stmts {
 	}
 }
 
+// Identifiers of the implicit @for loop object (see convertFor).
+const (
+	loopVar       = "loop"
+	loopParentVar = "$loop"
+	forIterVar    = "$for"
+)
+
+// convertFor lowers a @for block to a Gad for-in (`v in xs`, `k, v in xs`) or
+// plain for statement. When the block defines the implicit `loop` variable (see
+// ForStmt.Loop) it becomes
+//
+//	{ $for := xs; [$loop := loop]; loop := giom.loop($for, $loop|nil)
+//	  for k, v in $for { loop.next(); <body> }
+//	  if loop.empty { <else> } }
 func convertFor(f *ForStmt) gnode.Stmts {
-	if arr, ok := f.Cond.(*gnode.ArrayExpr); ok && len(arr.Elements) == 2 {
-		key, keyOK := arr.Elements[0].(*gnode.IdentExpr)
-		bin, binOK := arr.Elements[1].(*gnode.BinaryExpr)
-		if keyOK && binOK && bin.Token == token.In {
-			if val, valOK := bin.LHS.(*gnode.IdentExpr); valOK {
-				return gnode.Stmts{
-					&gnode.ForInStmt{
-						ForPos:   f.Pos(),
-						Key:      key,
-						Value:    val,
-						Iterable: bin.RHS,
-						Body:     gnode.SBlock(f.Pos(), f.End(), convertBody(f.Body)...),
-					},
-				}
-			}
-		}
+	key, val, iterable, in := forInParts(f.Cond)
+	body := convertBody(f.Body)
+	if !f.Loop {
+		return gnode.Stmts{forLoopStmt(f, key, val, iterable, in, body)}
 	}
-	if mp, ok := f.Cond.(*gnode.MultiParenExpr); ok && len(mp.PositionalElements) == 2 {
-		key, keyOK := mp.PositionalElements[0].(*gnode.IdentExpr)
-		bin, binOK := mp.PositionalElements[1].(*gnode.BinaryExpr)
-		if keyOK && binOK && bin.Token == token.In {
-			val, valOK := bin.LHS.(*gnode.IdentExpr)
-			if !valOK {
-				return gnode.Stmts{
-					&gnode.ForStmt{
-						ForPos: f.Pos(),
-						Init:   f.Init,
-						Cond:   f.Cond,
-						Post:   f.Post,
-						Body:   gnode.SBlock(f.Pos(), f.End(), convertBody(f.Body)...),
-					},
-				}
-			}
-			return gnode.Stmts{
-				&gnode.ForInStmt{
-					ForPos:   f.Pos(),
-					Key:      key,
-					Value:    val,
-					Iterable: bin.RHS,
-					Body:     gnode.SBlock(f.Pos(), f.End(), convertBody(f.Body)...),
-				},
-			}
-		}
+
+	pos := f.Pos()
+	var stmts gnode.Stmts
+	var iter gnode.Expr = gnode.LNil(pos)
+	if in {
+		// Evaluate the iterable once, for both the loop object and the loop.
+		stmts = append(stmts, defineVar(forIterVar, iterable, pos))
+		iterable, iter = gnode.EIdent(forIterVar, pos), gnode.EIdent(forIterVar, pos)
+	}
+	var parent gnode.Expr = gnode.LNil(pos)
+	if f.OuterLoop {
+		stmts = append(stmts, defineVar(loopParentVar, gnode.EIdent(loopVar, pos), pos))
+		parent = gnode.EIdent(loopParentVar, pos)
+	}
+	stmts = append(stmts, defineVar(loopVar, giomNew("loop", pos, f.End(), iter, parent), pos))
+
+	next := gnode.ECall(gnode.ESelector(gnode.EIdent(loopVar, pos), gnode.Str("next", pos)), pos, pos)
+	body = append(gnode.Stmts{gnode.SExpr(next)}, body...)
+	stmts = append(stmts, forLoopStmt(f, key, val, iterable, in, body))
+
+	if len(f.Else) > 0 {
+		stmts = append(stmts, &gnode.IfStmt{
+			Cond: gnode.ESelector(gnode.EIdent(loopVar, pos), gnode.Str("empty", pos)),
+			Body: gnode.SBlock(pos, f.End(), convertBody(f.Else)...),
+		})
 	}
-	if bin, ok := f.Cond.(*gnode.BinaryExpr); ok && bin.Token == token.In {
-		if val, ok := bin.LHS.(*gnode.IdentExpr); ok {
-			return gnode.Stmts{
-				&gnode.ForInStmt{
-					ForPos:   f.Pos(),
-					Key:      &gnode.IdentExpr{Name: "_", Empty: true},
-					Value:    val,
-					Iterable: bin.RHS,
-					Body:     gnode.SBlock(f.Pos(), f.End(), convertBody(f.Body)...),
-				},
-			}
+	return gnode.Stmts{gnode.SBlock(pos, f.End(), stmts...)}
+}
+
+// Binds reports whether the @for names its key or value variable name.
+func (f *ForStmt) Binds(name string) bool {
+	key, val, _, in := forInParts(f.Cond)
+	return in && (key.Name == name || val.Name == name)
+}
+
+// forInParts splits a @for condition of the forms `v in xs`, `k, v in xs` and
+// `(k, v in xs)` into its parts; in is false for any other condition.
+func forInParts(cond gnode.Expr) (key, val *gnode.IdentExpr, iterable gnode.Expr, in bool) {
+	var elems []gnode.Expr
+	switch c := cond.(type) {
+	case *gnode.ArrayExpr:
+		elems = c.Elements
+	case *gnode.MultiParenExpr:
+		elems = c.PositionalElements
+	case *gnode.BinaryExpr:
+		if v, ok := c.LHS.(*gnode.IdentExpr); ok && c.Token == token.In {
+			return &gnode.IdentExpr{Name: "_", Empty: true}, v, c.RHS, true
 		}
+		return nil, nil, nil, false
+	}
+	if len(elems) != 2 {
+		return nil, nil, nil, false
+	}
+	key, keyOK := elems[0].(*gnode.IdentExpr)
+	bin, binOK := elems[1].(*gnode.BinaryExpr)
+	if !keyOK || !binOK || bin.Token != token.In {
+		return nil, nil, nil, false
 	}
+	if val, ok := bin.LHS.(*gnode.IdentExpr); ok {
+		return key, val, bin.RHS, true
+	}
+	return nil, nil, nil, false
+}
+
+// forLoopStmt builds the Gad loop statement of a @for block with the given
+// (converted) body.
+func forLoopStmt(f *ForStmt, key, val *gnode.IdentExpr, iterable gnode.Expr, in bool, body gnode.Stmts) gnode.Stmt {
+	block := gnode.SBlock(f.Pos(), f.End(), body...)
+	if in {
+		return &gnode.ForInStmt{ForPos: f.Pos(), Key: key, Value: val, Iterable: iterable, Body: block}
+	}
+	return &gnode.ForStmt{ForPos: f.Pos(), Init: f.Init, Cond: f.Cond, Post: f.Post, Body: block}
+}
+
+// convertWhile lowers `@while cond` to a Gad `for cond { … }` loop.
+func convertWhile(w *WhileStmt) gnode.Stmts {
 	return gnode.Stmts{
 		&gnode.ForStmt{
-			ForPos: f.Pos(),
-			Init:   f.Init,
-			Cond:   f.Cond,
-			Post:   f.Post,
-			Body:   gnode.SBlock(f.Pos(), f.End(), convertBody(f.Body)...),
+			ForPos: w.Pos(),
+			Cond:   w.Cond,
+			Body:   gnode.SBlock(w.Pos(), w.End(), convertBody(w.Body)...),
 		},
 	}
 }
 
+// convertBranch lowers @break / @continue to the Gad statement.
+func convertBranch(b *BranchStmt) gnode.Stmts {
+	return gnode.Stmts{&gnode.BranchStmt{Token: b.Token, TokenPos: b.Pos()}}
+}
+
+// convertVerbatim lowers a @verbatim block to `giom.Text(tag, text)`, with the
+// text as a raw string for `@verbatim raw`.
+func convertVerbatim(v *VerbatimStmt) gnode.Stmts {
+	if v.Text == "" {
+		return nil
+	}
+	var value gnode.Expr = gnode.Str(v.Text, v.Pos())
+	if v.Raw {
+		value = gnode.EToRaw(v.Pos(), value)
+	}
+	return gnode.Stmts{gnode.SExpr(textCall(v.Pos(), v.End(), value))}
+}
+
+// embedEscapers maps an embed tag to the giom builtin escaping its
+// interpolations.
+var embedEscapers = map[string]string{"style": "css", "script": "js"}
+
+// convertEmbed lowers a :style / :script block to its tag holding one text
+// node: the literal parts are raw strings and each interpolation goes through
+// giom.css / giom.js, which escape it for that context.
+//
+//	{ tag := giom.Tag(tag, "script"); giom.Text(tag, "…", giom.js(expr), "…") }
+func convertEmbed(e *EmbedStmt) gnode.Stmts {
+	pos := e.Pos()
+	inner := gnode.Stmts{defineTag(giomNew("Tag", pos, e.End(), tagIdent(pos), gnode.Str(e.Name, pos)), pos)}
+	if len(e.Parts) > 0 {
+		values := make([]gnode.Expr, len(e.Parts))
+		for i, part := range e.Parts {
+			if lit, ok := part.(*gnode.StrLit); ok {
+				values[i] = gnode.EToRaw(lit.Pos(), lit)
+			} else {
+				values[i] = giomNew(embedEscapers[e.Name], part.Pos(), part.End(), part)
+			}
+		}
+		inner = append(inner, gnode.SExpr(textCall(pos, e.End(), values...)))
+	}
+	return gnode.Stmts{gnode.SBlock(pos, e.End(), inner...)}
+}
+
+// convertStack lowers `@stack name` to `giom.stack(tag, name)`, the placeholder
+// giom.ResolveStacks fills with the pushed content.
+func convertStack(s *StackStmt) gnode.Stmts {
+	return gnode.Stmts{gnode.SExpr(giomNew("stack", s.Pos(), s.End(), tagIdent(s.Pos()), s.Name))}
+}
+
+// convertPush lowers `@push name` to a fragment built in place and moved to
+// the stack by giom.ResolveStacks:
+//
+//	{ tag := giom.push(tag, name); <body> }
+func convertPush(s *PushStmt) gnode.Stmts {
+	return markerBlock("push", s.Name, s.Body, s.Pos(), s.End())
+}
+
+// convertOnce lowers `@once key` to a fragment giom.ResolveStacks keeps only
+// the first time key is seen:
+//
+//	{ tag := giom.once(tag, key); <body> }
+func convertOnce(s *OnceStmt) gnode.Stmts {
+	return markerBlock("once", s.Key, s.Body, s.Pos(), s.End())
+}
+
+func markerBlock(ctor string, arg gnode.Expr, body gnode.Stmts, pos, end source.Pos) gnode.Stmts {
+	inner := gnode.Stmts{defineTag(giomNew(ctor, pos, end, tagIdent(pos), arg), pos)}
+	inner = append(inner, convertBody(body)...)
+	return gnode.Stmts{gnode.SBlock(pos, end, inner...)}
+}
+
+// defineVar builds `name := value`.
+func defineVar(name string, value gnode.Expr, pos source.Pos) gnode.Stmt {
+	return &gnode.AssignStmt{LHS: []gnode.Expr{gnode.EIdent(name, pos)}, RHS: []gnode.Expr{value}, Token: token.Define, TokenPos: pos}
+}
+
 func convertIf(s *IfStmt) gnode.Stmts {
 	body := gnode.SBlock(s.Pos(), s.End(), convertBody(s.Body)...)
 	var elseStmt gnode.Stmt
@@ -772,23 +899,60 @@ func convertIf(s *IfStmt) gnode.Stmts {
 func convertTag(t *TagStmt) gnode.Stmts {
 	ctor := giomNew("Tag", t.NodePos, t.NodeEnd,
 		tagIdent(t.NodePos), gnode.Str(t.Name, t.NodePos))
-	applyTagAttrs(ctor, t.Attributes)
+	merges := applyTagAttrs(ctor, t.Attributes, t.NodePos)
 
-	inner := gnode.Stmts{defineTag(ctor, t.NodePos)}
+	inner := append(gnode.Stmts{defineTag(ctor, t.NodePos)}, merges...)
 	if !t.SelfClosing {
 		inner = append(inner, convertBody(t.Body)...)
 	}
-	return gnode.Stmts{gnode.SBlock(t.Pos(), t.End(), inner...)}
+	out := gnode.Stmts{gnode.SBlock(t.Pos(), t.End(), inner...)}
+	if t.SpaceBefore {
+		out = append(gnode.Stmts{spaceText(t.Pos())}, out...)
+	}
+	if t.SpaceAfter {
+		out = append(out, spaceText(t.End()))
+	}
+	return out
+}
+
+// spaceText builds `giom.Text(tag, " ")`, the space written around a tag with
+// a whitespace modifier.
+func spaceText(pos source.Pos) gnode.Stmt {
+	return gnode.SExpr(textCall(pos, pos, gnode.Str(" ", pos)))
 }
 
 // applyTagAttrs adds a tag's attributes as named arguments of the giom.Tag call,
 // expanding `**attrs`-style groups into individual name=value pairs. giom.Tag
 // classifies them into regular attributes, class list and styles.
-func applyTagAttrs(call *gnode.CallExpr, attrs []*TagAttribute) {
+//
+// From the first attribute spread on, attributes are merged in order into the
+// new tag instead, so later ones still win:
+//
+//	giom.merge(tag, spread)
+//	giom.merge(tag; name=value, …)
+//
+// applyTagAttrs returns these merge statements.
+func applyTagAttrs(call *gnode.CallExpr, attrs []*TagAttribute, pos source.Pos) (merges gnode.Stmts) {
 	for _, attr := range attrs {
 		if attr == nil {
 			continue
 		}
+		if attr.Spread {
+			merge := tagMergeCall(pos)
+			value := attr.Value
+			if attr.Condition != nil {
+				value = giomNew("toggle", attr.Condition.Pos(), attr.Condition.End(), attr.Condition, value)
+			}
+			merge.Args.Values = append(merge.Args.Values, value)
+			merges = append(merges, gnode.SExpr(merge))
+			call = nil
+			continue
+		}
+		if call == nil {
+			merge := tagMergeCall(pos)
+			merges = append(merges, gnode.SExpr(merge))
+			call = merge
+		}
 		if attr.Elements != nil {
 			for _, el := range attr.Elements.Elements {
 				if kv, ok := el.(*gnode.KeyValuePairLit); ok {
@@ -797,16 +961,33 @@ func applyTagAttrs(call *gnode.CallExpr, attrs []*TagAttribute) {
 			}
 			continue
 		}
-		value := attr.Value
-		if value == nil {
-			if attr.IsFlag {
-				value = gnode.Str(attr.Name, 0)
-			} else {
-				value = gnode.Str("", 0)
-			}
+		addNamedArg(call, attr.Name, attrValue(attr))
+	}
+	return
+}
+
+// tagMergeCall builds `giom.merge(tag)`, which merges attributes into the
+// current tag.
+func tagMergeCall(pos source.Pos) *gnode.CallExpr {
+	return giomNew("merge", pos, pos, tagIdent(pos))
+}
+
+// attrValue returns the value expression of a single attribute. A conditional
+// attribute, a toggled class or one of a group with a trailing `? cond`, lowers
+// to `giom.toggle(cond, value)`, a keyed value the tag drops when cond is falsy.
+func attrValue(attr *TagAttribute) gnode.Expr {
+	value := attr.Value
+	if value == nil {
+		if attr.IsFlag {
+			value = gnode.Str(attr.Name, 0)
+		} else {
+			value = gnode.Str("", 0)
 		}
-		addNamedArg(call, attr.Name, value)
 	}
+	if attr.Condition != nil {
+		value = giomNew("toggle", attr.Condition.Pos(), attr.Condition.End(), attr.Condition, value)
+	}
+	return value
 }
 
 // convertHtml lowers a raw HTML region to a giom.Text append of its literal and
@@ -878,6 +1059,9 @@ func convertText(t *TextStmt) gnode.Stmts {
 		out.Append(gnode.SExpr(textCall(t.NodePos, t.NodeEnd, values...)))
 		values = nil
 	}
+	if t.SpaceBefore {
+		values = append(values, gnode.Str(" ", t.Pos()))
+	}
 	for _, stmt := range t.Stmts {
 		switch s := stmt.(type) {
 		case *gnode.MixedTextStmt:
@@ -885,8 +1069,9 @@ func convertText(t *TextStmt) gnode.Stmts {
 		case *gnode.MixedValueStmt:
 			values = append(values, s.Expr)
 		case gnode.Stmt:
+			// Inline `#[tag …]` spans are nested TagStmts.
 			flush()
-			out.Append(s)
+			out.Append(convertStmt(s)...)
 		}
 	}
 	flush()
@@ -1,11 +1,18 @@
 package node
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
 )
 
 // =============================================================================
@@ -17,6 +24,13 @@ type GiomCodeWriteContext struct {
 	Writer io.Writer
 	Depth  int
 	Prefix string // indentation string (default "\t")
+	// Source, when set, is the file the statements were parsed from: directive
+	// lines, text and one-line code are then written as in the source (only
+	// re-indented) and blank lines between statements are kept. Tag heads and
+	// `~~` Gad blocks are always regenerated.
+	Source *source.File
+
+	lineStarts []int // offsets of the Source lines, computed on first use
 }
 
 // NewGiomCodeContext creates a new context writing to w.
@@ -37,15 +51,160 @@ func (c *GiomCodeWriteContext) WriteLine(s string) {
 	c.write(c.indent() + s + "\n")
 }
 
-// WriteStmts writes a list of giom statements at the current depth.
+// WriteStmts writes a list of giom statements at the current depth. With a
+// Source, a blank line between two statements is written as one blank line.
 func (c *GiomCodeWriteContext) WriteStmts(stmts gnode.Stmts) {
+	var prev gnode.Stmt
 	for _, stmt := range stmts {
-		if gc, ok := stmt.(GiomCoder); ok {
-			gc.WriteGiom(c)
+		gc, ok := stmt.(GiomCoder)
+		if !ok {
+			continue
+		}
+		if prev != nil && c.blankBefore(stmt.Pos(), prev.Pos()) {
+			c.write("\n")
 		}
+		gc.WriteGiom(c)
+		prev = stmt
 	}
 }
 
+// writeBody writes stmts one level deeper.
+func (c *GiomCodeWriteContext) writeBody(stmts gnode.Stmts) {
+	c.Depth++
+	c.WriteStmts(stmts)
+	c.Depth--
+}
+
+// writeLines writes lines at the current depth, keeping their relative
+// indentation; empty lines are written without indentation.
+func (c *GiomCodeWriteContext) writeLines(lines []string) {
+	for _, line := range lines {
+		if line == "" {
+			c.write("\n")
+		} else {
+			c.WriteLine(line)
+		}
+	}
+}
+
+// =============================================================================
+// Source lines
+// =============================================================================
+
+// srcLine returns the index of the Source line holding pos, or -1 without a
+// Source or a valid position.
+func (c *GiomCodeWriteContext) srcLine(pos source.Pos) int {
+	if c.Source == nil || !pos.IsValid() {
+		return -1
+	}
+	data := c.Source.Data.Bytes()
+	if c.lineStarts == nil {
+		c.lineStarts = []int{0}
+		for i, b := range data {
+			if b == '\n' {
+				c.lineStarts = append(c.lineStarts, i+1)
+			}
+		}
+	}
+	off := int(pos) - c.Source.Base
+	if off < 0 || off > len(data) {
+		return -1
+	}
+	return sort.Search(len(c.lineStarts), func(i int) bool { return c.lineStarts[i] > off }) - 1
+}
+
+// srcLineText returns the Source line i without its line break.
+func (c *GiomCodeWriteContext) srcLineText(i int) string {
+	data := c.Source.Data.Bytes()
+	end := len(data)
+	if i+1 < len(c.lineStarts) {
+		end = c.lineStarts[i+1] - 1
+	}
+	return strings.TrimRight(string(data[c.lineStarts[i]:end]), " \t\r")
+}
+
+// srcSlice returns the Source text from pos up to end.
+func (c *GiomCodeWriteContext) srcSlice(pos, end source.Pos) (string, bool) {
+	if c.srcLine(pos) < 0 || end < pos {
+		return "", false
+	}
+	data := c.Source.Data.Bytes()
+	from, to := int(pos)-c.Source.Base, int(end)-c.Source.Base
+	if to > len(data) {
+		return "", false
+	}
+	return string(data[from:to]), true
+}
+
+// srcLines returns the Source lines from the one holding pos through the one
+// holding the last byte before end, extended over `\` line continuations and
+// without the indentation of the first line.
+func (c *GiomCodeWriteContext) srcLines(pos, end source.Pos) ([]string, bool) {
+	from := c.srcLine(pos)
+	if from < 0 {
+		return nil, false
+	}
+	to := from
+	if end > pos {
+		if l := c.srcLine(end - 1); l > to {
+			to = l
+		}
+	}
+	for to+1 < len(c.lineStarts) && strings.HasSuffix(c.srcLineText(to), "\\") {
+		to++
+	}
+	first := c.srcLineText(from)
+	indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	lines := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		line := c.srcLineText(i)
+		if strings.HasPrefix(line, indent) {
+			line = line[len(indent):]
+		} else {
+			line = strings.TrimLeft(line, " \t")
+		}
+		lines = append(lines, line)
+	}
+	return lines, true
+}
+
+// blankBefore reports whether the Source line before pos is blank and comes
+// after the line of prev.
+func (c *GiomCodeWriteContext) blankBefore(pos, prev source.Pos) bool {
+	l := c.srcLine(pos)
+	return l > 0 && l-1 > c.srcLine(prev) && strings.TrimSpace(c.srcLineText(l-1)) == ""
+}
+
+// writeSource writes the Source lines of pos..end (see srcLines), or fallback
+// without a Source.
+func (c *GiomCodeWriteContext) writeSource(pos, end source.Pos, fallback string) {
+	if lines, ok := c.srcLines(pos, end); ok {
+		c.writeLines(lines)
+	} else {
+		c.WriteLine(fallback)
+	}
+}
+
+// writeHead writes the directive line at pos as in the Source, or fallback.
+func (c *GiomCodeWriteContext) writeHead(pos source.Pos, fallback string) {
+	c.writeSource(pos, pos, fallback)
+}
+
+// writeClause writes the head of an `@else` or `@case` clause, which has no
+// position of its own: the nearest Source line above the clause body starting
+// with prefix, or fallback.
+func (c *GiomCodeWriteContext) writeClause(body gnode.Stmts, prefix, fallback string) {
+	if len(body) > 0 {
+		for l := c.srcLine(body[0].Pos()) - 1; l >= 0; l-- {
+			if line := strings.TrimSpace(c.srcLineText(l)); strings.HasPrefix(line, prefix) {
+				c.WriteLine(line)
+				return
+			}
+		}
+	}
+	c.WriteLine(fallback)
+}
+
 // GiomCoder is implemented by nodes that can write formatted giom source.
 type GiomCoder interface {
 	WriteGiom(ctx *GiomCodeWriteContext)
@@ -56,145 +215,408 @@ type GiomCoder interface {
 // =============================================================================
 
 func (f *File) WriteGiom(ctx *GiomCodeWriteContext) {
+	if f.Whitespace != "" {
+		ctx.WriteLine("@whitespace " + f.Whitespace)
+	}
 	ctx.WriteStmts(f.Stmts)
 }
 
 func (t *TextStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	// Reconstruct text from mixed GAD statements
+	marker := "|"
+	if t.Marker {
+		marker = "|+"
+	}
+	if text := t.giomText(ctx); text != "" {
+		marker += " " + text
+	}
+	ctx.WriteLine(marker)
+}
+
+// rgxTextLead matches what precedes the content of a text line: the `|` or
+// `|+` marker and one space.
+var rgxTextLead = regexp.MustCompile(`^(\|\+?)? ?`)
+
+// giomText returns the text content on one line: as written in the source
+// when ctx has it, so escapes and interpolation forms are kept, else rebuilt
+// from the parsed pieces.
+func (t *TextStmt) giomText(ctx *GiomCodeWriteContext) string {
+	if len(t.Stmts) == 0 {
+		return ""
+	}
+	if s, ok := ctx.srcSlice(t.NodePos, t.NodeEnd); ok && !strings.Contains(s, "\n") {
+		if s = strings.TrimRight(rgxTextLead.ReplaceAllString(s, ""), " \t"); s != "" {
+			return s
+		}
+	}
+	var b strings.Builder
 	for _, stmt := range t.Stmts {
-		switch s := stmt.(type) {
-		case *gnode.MixedTextStmt:
-			ctx.WriteLine("| " + s.String())
-		case *gnode.MixedValueStmt:
-			ctx.WriteLine("| {" + s.String() + "}")
-		default:
-			ctx.WriteLine("| " + s.String())
+		b.WriteString(textPieceGiom(stmt))
+	}
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// textPieceGiom returns the giom source of one piece of text content.
+func textPieceGiom(stmt gnode.Stmt) string {
+	switch s := stmt.(type) {
+	case *gnode.MixedValueStmt:
+		return "{" + s.String() + "}"
+	case *TagStmt:
+		return "#[" + s.inlineGiom() + "]"
+	default:
+		return strings.ReplaceAll(s.String(), "#[", `\#[`)
+	}
+}
+
+// inlineGiom returns the giom source of a `#[tag …]` text span: the tag head
+// followed by its text.
+func (t *TagStmt) inlineGiom() string {
+	s := t.giomHead()
+	for _, stmt := range t.Body {
+		if text, ok := stmt.(*TextStmt); ok {
+			var b strings.Builder
+			for _, piece := range text.Stmts {
+				b.WriteString(textPieceGiom(piece))
+			}
+			s += " " + b.String()
 		}
 	}
+	return s
 }
 
 func (t *TagStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine(t.Name)
-	ctx.Depth++
+	line, last := t.giomHead(), t
+	// Inline children continue the line as `parent: child`, unless a
+	// conditional attribute (`? cond`, which runs to the end of the line) ends
+	// the head.
+	for len(last.Body) == 1 && !last.hasCondition() {
+		child, ok := last.Body[0].(*TagStmt)
+		if !ok || !child.Inline {
+			break
+		}
+		line += ": " + child.giomHead()
+		last = child
+	}
+	if text, ok := last.inlineText(ctx); ok {
+		ctx.WriteLine(line + " " + text)
+		return
+	}
+	ctx.WriteLine(line)
+	ctx.writeBody(last.Body)
+}
+
+// inlineText returns the text of a tag whose body is one line of text, which
+// is written after the head (`a.btn[href=x] text`). A `|+` line, text after a
+// `? cond` attribute and text that could read as part of the head stay in
+// the block.
+func (t *TagStmt) inlineText(ctx *GiomCodeWriteContext) (string, bool) {
+	if len(t.Body) != 1 || t.hasCondition() {
+		return "", false
+	}
+	text, ok := t.Body[0].(*TextStmt)
+	if !ok || text.Marker {
+		return "", false
+	}
+	s := text.giomText(ctx)
+	if r, _ := utf8.DecodeRuneInString(s); !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '{' {
+		return "", false
+	}
+	return s, true
+}
+
+// giomHead returns the tag name followed by its attributes in the inline
+// `#id.class[name=value]` form.
+func (t *TagStmt) giomHead() string {
+	var b strings.Builder
+	b.WriteString(t.Name)
 	for _, attr := range t.Attributes {
-		attr.writeGiom(ctx)
+		b.WriteString(attr.giomString())
 	}
-	ctx.WriteStmts(t.Body)
-	ctx.Depth--
+	b.WriteString(t.Modifier)
+	return b.String()
+}
+
+func (t *TagStmt) hasCondition() bool {
+	for _, attr := range t.Attributes {
+		if attr.Condition != nil && !attr.Toggle {
+			return true
+		}
+	}
+	return false
 }
 
-func (a *TagAttribute) writeGiom(ctx *GiomCodeWriteContext) {
+func (a *TagAttribute) giomString() string {
+	if a.Spread {
+		if a.Condition != nil {
+			return "[..." + exprStr(a.Value) + "] ? " + a.Condition.String()
+		}
+		return "&attributes(" + exprStr(a.Value) + ")"
+	}
 	cond := ""
 	if a.Condition != nil {
-		cond = " ? " + a.Condition.String()
+		if a.Toggle {
+			cond = "?(" + a.Condition.String() + ")"
+		} else {
+			cond = " ? " + a.Condition.String()
+		}
 	}
-	switch a.Name {
-	case "id":
-		ctx.WriteLine("#" + exprStr(a.Value) + cond)
-	case "class":
-		ctx.WriteLine("." + exprStr(a.Value) + cond)
-	default:
-		s := "[" + a.Name
-		if a.IsFlag {
-			s += cond
-		} else if a.Value != nil {
-			if a.IsRaw {
-				s += "=\"" + exprStr(a.Value) + "\""
-			} else {
-				s += "=" + exprStr(a.Value)
-			}
-			s += cond
+	if lit, ok := a.Value.(*gnode.StrLit); ok && rgxGiomName.MatchString(lit.Value()) {
+		switch a.Name {
+		case "id":
+			return "#" + lit.Value() + cond
+		case "class":
+			return "." + lit.Value() + cond
+		}
+	} else if a.Name == "class" && a.Value != nil && !a.IsRaw {
+		if _, ok := a.Value.(*gnode.StrLit); !ok {
+			return ".{" + exprStr(a.Value) + "}" + cond
+		}
+	}
+	s := "[" + a.Name
+	if !a.IsFlag && a.Value != nil {
+		if lit, ok := a.Value.(*gnode.StrLit); ok && a.IsRaw {
+			s += "=\"" + lit.Value() + "\""
+		} else if ok && strings.ContainsAny(lit.Value(), "\"\n\r") {
+			// A raw `"…"` value cannot hold quotes or line breaks.
+			s += "=(" + strconv.Quote(lit.Value()) + ")"
+		} else {
+			s += "=" + exprStr(a.Value)
 		}
-		s += "]"
-		ctx.WriteLine(s)
 	}
+	return s + "]" + cond
 }
 
+// rgxGiomName matches an id or class name written in the `#id` / `.class`
+// shorthand.
+var rgxGiomName = regexp.MustCompile(`^[\w-]+$`)
+
 func (d *DoctypeStmt) WriteGiom(ctx *GiomCodeWriteContext) {
 	ctx.WriteLine("!!! " + d.Value)
 }
 
 func (c *CommentStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	prefix := "//"
+	line := "//"
 	if c.Silent {
-		prefix = "//-"
+		line = "//-"
 	}
-	ctx.WriteLine(prefix + " " + c.Text)
-	if len(c.Body) > 0 {
-		ctx.Depth++
-		ctx.WriteStmts(c.Body)
-		ctx.Depth--
+	if c.Text != "" {
+		line += " " + c.Text
 	}
+	ctx.WriteLine(line)
+	ctx.writeBody(c.Body)
 }
 
 func (s *IfStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine("@if " + exprStr(s.Cond))
-	ctx.Depth++
-	ctx.WriteStmts(s.Body)
-	ctx.Depth--
+	ctx.writeHead(s.NodePos, "@if "+exprStr(s.Cond))
+	ctx.writeBody(s.Body)
 	for _, eif := range s.ElseIfs {
-		ctx.WriteLine("@else if " + exprStr(eif.Cond))
-		ctx.Depth++
-		ctx.WriteStmts(eif.Body)
-		ctx.Depth--
+		ctx.writeClause(eif.Body, "@else if", "@else if "+exprStr(eif.Cond))
+		ctx.writeBody(eif.Body)
 	}
 	if len(s.Else) > 0 {
-		ctx.WriteLine("@else")
-		ctx.Depth++
-		ctx.WriteStmts(s.Else)
-		ctx.Depth--
+		ctx.writeClause(s.Else, "@else", "@else")
+		ctx.writeBody(s.Else)
 	}
 }
 
 func (s *ForStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine("@for " + exprStr(s.Cond))
+	ctx.writeHead(s.NodePos, "@for "+exprStr(s.Cond))
+	ctx.writeBody(s.Body)
+	if len(s.Else) > 0 {
+		ctx.writeClause(s.Else, "@else", "@else")
+		ctx.writeBody(s.Else)
+	}
+}
+
+func (s *WhileStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	ctx.writeHead(s.NodePos, "@while "+exprStr(s.Cond))
+	ctx.writeBody(s.Body)
+}
+
+func (s *BranchStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	ctx.WriteLine("@" + s.Token.String())
+}
+
+func (s *VerbatimStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	if s.Raw {
+		ctx.WriteLine("@verbatim raw")
+	} else {
+		ctx.WriteLine("@verbatim")
+	}
+	if s.Text == "" {
+		return
+	}
 	ctx.Depth++
-	ctx.WriteStmts(s.Body)
+	ctx.writeLines(strings.Split(s.Text, "\n"))
 	ctx.Depth--
-	if len(s.Else) > 0 {
-		ctx.WriteLine("@else")
-		ctx.Depth++
-		ctx.WriteStmts(s.Else)
-		ctx.Depth--
+}
+
+func (s *StackStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	ctx.writeHead(s.NodePos, "@stack "+exprStr(s.Name))
+}
+
+func (s *PushStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	ctx.writeHead(s.NodePos, "@push "+exprStr(s.Name))
+	ctx.writeBody(s.Body)
+}
+
+func (s *OnceStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	// A generated key (the source location) is not written back.
+	if lit, ok := s.Key.(*gnode.StrLit); ok && lit.Pos() == s.NodePos {
+		ctx.WriteLine("@once")
+	} else {
+		ctx.writeHead(s.NodePos, "@once "+exprStr(s.Key))
 	}
+	ctx.writeBody(s.Body)
+}
+
+func (s *EmbedStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	ctx.WriteLine(":" + s.Name)
+	var b strings.Builder
+	for _, part := range s.Parts {
+		if lit, ok := part.(*gnode.StrLit); ok {
+			b.WriteString(lit.Value())
+		} else {
+			b.WriteString("{= " + exprStr(part) + "}")
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+	ctx.Depth++
+	ctx.writeLines(strings.Split(b.String(), "\n"))
+	ctx.Depth--
 }
 
 func (s *AssignStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine(exprStr(s.LHS) + " " + s.Op + " " + exprStr(s.RHS))
+	ctx.writeSource(s.NodePos, s.NodeEnd, exprStr(s.LHS)+" "+s.Op+" "+exprStr(s.RHS))
+}
+
+func (h *HtmlStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	if lines, ok := ctx.srcLines(h.NodePos, h.NodeEnd); ok {
+		ctx.writeLines(lines)
+		return
+	}
+	// Without the source, the lowered write calls are written as code.
+	(&CodeStmt{Stmts: h.Stmts, Block: true}).WriteGiom(ctx)
 }
 
 func (c *CodeStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	if len(c.Stmts) == 1 {
-		ctx.WriteLine("~ " + c.Stmts[0].String())
-	} else if len(c.Stmts) > 1 {
-		ctx.WriteLine("~~")
+	if !c.Block {
+		// A `~` line, or an `@import` directive.
+		if lines, ok := ctx.srcLines(c.NodePos, c.NodeEnd); ok {
+			ctx.writeLines(lines)
+			return
+		}
 		for _, stmt := range c.Stmts {
-			ctx.WriteLine(stmt.String())
+			ctx.WriteLine("~ " + stmt.String())
+		}
+		return
+	}
+	ctx.WriteLine("~~")
+	ctx.Depth++
+	ctx.writeLines(c.blockLines(ctx))
+	ctx.Depth--
+	ctx.WriteLine("~~")
+}
+
+// blockLines returns the lines of a `~~` block, without indentation: the
+// Gad code formatted, or the source lines when they hold comments, which the
+// parsed statements do not keep.
+func (c *CodeStmt) blockLines(ctx *GiomCodeWriteContext) []string {
+	if from := ctx.srcLine(c.NodePos); from >= 0 {
+		var raw []string
+		for l := from + 1; l < len(ctx.lineStarts); l++ {
+			line := ctx.srcLineText(l)
+			if strings.TrimSpace(line) == "~~" {
+				break
+			}
+			raw = append(raw, line)
+		}
+		if hasComment(strings.Join(raw, "\n")) {
+			return Dedent(raw)
+		}
+	}
+	var buf bytes.Buffer
+	gnode.CodeW(&buf, c.Stmts, gnode.CodeWithPrefix(ctx.Prefix), gnode.CodeFormat())
+	return Dedent(strings.Split(strings.Trim(buf.String(), "\n"), "\n"))
+}
+
+// hasComment reports whether the Gad source src holds a `//` or `/*` comment;
+// the text of string, raw string and char literals is skipped.
+func hasComment(src string) bool {
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"', '\'':
+			for i++; i < len(src) && src[i] != c && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '`':
+			j := strings.IndexByte(src[i+1:], '`')
+			if j < 0 {
+				return false
+			}
+			i += j + 1
+		case '/':
+			if i+1 < len(src) && (src[i+1] == '/' || src[i+1] == '*') {
+				return true
+			}
 		}
-		ctx.WriteLine("~~")
 	}
+	return false
+}
+
+// Dedent removes the indentation common to the non-blank lines, and the
+// blank lines at both ends, and trims the lines' trailing whitespace.
+func Dedent(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	indent := -1
+	for _, line := range lines {
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); n < len(line) && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if indent > 0 && len(line) > indent {
+			line = line[indent:]
+		}
+		out[i] = strings.TrimRight(line, " \t")
+	}
+	return out
 }
 
 func (f *FuncDecl) WriteGiom(ctx *GiomCodeWriteContext) {
 	line := "@func " + f.Name
+	if f.Exported {
+		line = "@export func " + f.Name
+	}
 	if f.Params != nil {
 		line += f.Params.String()
 	}
-	ctx.WriteLine(line)
-	ctx.Depth++
-	ctx.WriteStmts(f.Body)
-	ctx.Depth--
+	ctx.writeHead(f.NodePos, line)
+	ctx.writeBody(f.Body)
 }
 
 func (c *CompDecl) WriteGiom(ctx *GiomCodeWriteContext) {
 	line := "@comp " + c.Name
+	switch {
+	case c.Main:
+		line = "@main"
+	case c.Exported:
+		line = "@export comp " + c.Name
+	}
 	if c.Params != nil {
 		line += c.Params.String()
 	}
-	ctx.WriteLine(line)
-	ctx.Depth++
-	ctx.WriteStmts(c.Body)
-	ctx.Depth--
+	ctx.writeHead(c.NodePos, line)
+	ctx.writeBody(c.Body)
 }
 
 func (c *CompCallStmt) WriteGiom(ctx *GiomCodeWriteContext) {
@@ -202,12 +624,19 @@ func (c *CompCallStmt) WriteGiom(ctx *GiomCodeWriteContext) {
 	if c.Args.Args.Valid() || c.Args.NamedArgs.Valid() {
 		line += c.Args.String()
 	}
-	ctx.WriteLine(line)
-	ctx.Depth++
+	ctx.writeHead(c.NodePos, line)
+	// The call block in source order: the call-scope code, the `@slot #name`
+	// passes and the content of the implicit main slot.
+	body := append(gnode.Stmts{}, c.InitStmts...)
 	for _, sp := range c.SlotPass {
-		sp.WriteGiom(ctx)
+		if sp.Implicit() {
+			body = append(body, sp.Body...)
+		} else {
+			body = append(body, sp)
+		}
 	}
-	ctx.Depth--
+	sort.SliceStable(body, func(i, j int) bool { return body[i].Pos() < body[j].Pos() })
+	ctx.writeBody(body)
 }
 
 func (s *SlotDecl) WriteGiom(ctx *GiomCodeWriteContext) {
@@ -215,10 +644,13 @@ func (s *SlotDecl) WriteGiom(ctx *GiomCodeWriteContext) {
 	if s.Scope != nil {
 		line += s.Scope.String()
 	}
-	ctx.WriteLine(line)
-	ctx.Depth++
-	ctx.WriteStmts(s.Body)
-	ctx.Depth--
+	ctx.writeHead(s.NodePos, line)
+	if s.Wrap != nil {
+		ctx.Depth++
+		s.Wrap.WriteGiom(ctx)
+		ctx.Depth--
+	}
+	ctx.writeBody(s.Body)
 }
 
 func (s *SlotPassStmt) WriteGiom(ctx *GiomCodeWriteContext) {
@@ -229,63 +661,64 @@ func (s *SlotPassStmt) WriteGiom(ctx *GiomCodeWriteContext) {
 	if s.FuncType != nil && s.FuncType.Params.LParen.IsValid() {
 		line += s.FuncType.Params.String()
 	}
-	ctx.WriteLine(line)
-	ctx.Depth++
-	ctx.WriteStmts(s.Body)
-	ctx.Depth--
+	ctx.writeHead(s.NodePos, line)
+	ctx.writeBody(s.Body)
 }
 
 func (w *WrapStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine("@wrap")
-	ctx.Depth++
-	ctx.WriteStmts(w.Body)
-	ctx.Depth--
+	ctx.writeHead(w.NodePos, "@wrap")
+	ctx.writeBody(w.Body)
 }
 
 func (s *MatchStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine("@match " + exprStr(s.Tag))
+	ctx.writeHead(s.NodePos, "@match "+exprStr(s.Tag))
 	ctx.Depth++
 	for _, c := range s.Cases {
-		ctx.WriteLine("@case " + exprStr(c.Expr))
-		ctx.Depth++
-		ctx.WriteStmts(c.Body)
-		ctx.Depth--
+		ctx.writeClause(c.Body, "@case", "@case "+exprStr(c.Expr))
+		ctx.writeBody(c.Body)
 	}
 	if len(s.Default) > 0 {
-		ctx.WriteLine("@else")
-		ctx.Depth++
-		ctx.WriteStmts(s.Default)
-		ctx.Depth--
+		ctx.writeClause(s.Default, "@else", "@else")
+		ctx.writeBody(s.Default)
 	}
 	ctx.Depth--
 }
 
 func (s *VarStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	var parts []string
-	for _, d := range s.Decls {
-		if d.Init != nil {
-			parts = append(parts, fmt.Sprintf("%s = %s", d.Name, exprStr(d.Init)))
-		} else {
-			parts = append(parts, d.Name)
-		}
-	}
-	ctx.WriteLine("@var (" + strings.Join(parts, ", ") + ")")
+	ctx.writeSource(s.NodePos, s.NodeEnd, "@var ("+declsGiom(s.Decls)+")")
 }
 
 func (s *ConstStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	var parts []string
-	for _, d := range s.Decls {
+	ctx.writeSource(s.NodePos, s.NodeEnd, "@const ("+declsGiom(s.Decls)+")")
+}
+
+// declsGiom returns the `name = init` list of a `@var` or `@const` line.
+func declsGiom(decls []VarDecl) string {
+	parts := make([]string, len(decls))
+	for i, d := range decls {
 		if d.Init != nil {
-			parts = append(parts, fmt.Sprintf("%s = %s", d.Name, exprStr(d.Init)))
+			parts[i] = fmt.Sprintf("%s = %s", d.Name, exprStr(d.Init))
 		} else {
-			parts = append(parts, d.Name)
+			parts[i] = d.Name
 		}
 	}
-	ctx.WriteLine("@const (" + strings.Join(parts, ", ") + ")")
+	return strings.Join(parts, ", ")
 }
 
 func (s *GlobalStmt) WriteGiom(ctx *GiomCodeWriteContext) {
-	ctx.WriteLine("@global " + strings.Join(s.Names, " "))
+	line := "@global " + strings.Join(s.Names, ", ")
+	if s.Decl != nil {
+		line = "@global " + strings.TrimPrefix(s.Decl.String(), "global ")
+	}
+	ctx.writeSource(s.NodePos, s.NodeEnd, line)
+}
+
+func (s *EnumStmt) WriteGiom(ctx *GiomCodeWriteContext) {
+	line := "@enum " + s.Name
+	if s.Decl != nil {
+		line = "~ " + s.Decl.String()
+	}
+	ctx.writeSource(s.NodePos, s.NodeEnd, line)
 }
 
 func (e *ExportStmt) WriteGiom(ctx *GiomCodeWriteContext) {
@@ -293,7 +726,7 @@ func (e *ExportStmt) WriteGiom(ctx *GiomCodeWriteContext) {
 	if e.Value != nil {
 		line += " = " + exprStr(e.Value)
 	}
-	ctx.WriteLine(line)
+	ctx.writeSource(e.NodePos, e.NodeEnd, line)
 }
 
 // =============================================================================
@@ -307,7 +740,15 @@ var (
 	_ GiomCoder = (*CommentStmt)(nil)
 	_ GiomCoder = (*IfStmt)(nil)
 	_ GiomCoder = (*ForStmt)(nil)
+	_ GiomCoder = (*WhileStmt)(nil)
+	_ GiomCoder = (*BranchStmt)(nil)
+	_ GiomCoder = (*VerbatimStmt)(nil)
+	_ GiomCoder = (*EmbedStmt)(nil)
+	_ GiomCoder = (*StackStmt)(nil)
+	_ GiomCoder = (*PushStmt)(nil)
+	_ GiomCoder = (*OnceStmt)(nil)
 	_ GiomCoder = (*AssignStmt)(nil)
+	_ GiomCoder = (*HtmlStmt)(nil)
 	_ GiomCoder = (*CodeStmt)(nil)
 	_ GiomCoder = (*FuncDecl)(nil)
 	_ GiomCoder = (*CompDecl)(nil)
@@ -319,6 +760,7 @@ var (
 	_ GiomCoder = (*VarStmt)(nil)
 	_ GiomCoder = (*ConstStmt)(nil)
 	_ GiomCoder = (*GlobalStmt)(nil)
+	_ GiomCoder = (*EnumStmt)(nil)
 	_ GiomCoder = (*ExportStmt)(nil)
 )
 
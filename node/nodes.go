@@ -7,6 +7,7 @@ import (
 	"github.com/gad-lang/gad/parser/ast"
 	gnode "github.com/gad-lang/gad/parser/node"
 	"github.com/gad-lang/gad/parser/source"
+	"github.com/gad-lang/gad/token"
 )
 
 // =============================================================================
@@ -17,6 +18,9 @@ type File struct {
 	Stmts     gnode.Stmts
 	Comps     []*CompDecl
 	InputFile *source.File
+	// Whitespace is the `@whitespace` pragma mode ("preserve" or "collapse"),
+	// empty when the file has none.
+	Whitespace string
 }
 
 func (f *File) Pos() source.Pos {
@@ -48,6 +52,11 @@ type TextStmt struct {
 	NodePos source.Pos
 	NodeEnd source.Pos
 	Stmts   gnode.Stmts
+	// SpaceBefore writes a space before the text (`|+ text`, or between
+	// siblings under `@whitespace preserve`).
+	SpaceBefore bool
+	// Marker reports that the space comes from the `|+` marker.
+	Marker bool
 }
 
 func (t *TextStmt) Pos() source.Pos { return t.NodePos }
@@ -69,7 +78,13 @@ type TagAttribute struct {
 	IsRaw     bool
 	IsFlag    bool
 	Condition gnode.Expr
-	Elements  *gnode.KeyValueArrayLit
+	// Toggle reports that Condition was written in place as `.class?(cond)`
+	// rather than as a trailing `? cond`.
+	Toggle bool
+	// Spread reports an attribute spread (`&attributes(expr)` or `[...expr]`):
+	// Value is a collection of attributes merged into the tag at this point.
+	Spread   bool
+	Elements *gnode.KeyValueArrayLit
 }
 
 type TagStmt struct {
@@ -80,6 +95,15 @@ type TagStmt struct {
 	Attributes  []*TagAttribute
 	Body        gnode.Stmts
 	SelfClosing bool
+	// Inline reports that the tag was nested on its parent's line with the
+	// `parent: child` syntax, as the only child of the parent.
+	Inline bool
+	// Modifier is the whitespace modifier of the tag head (`<`, `>` or `<>`).
+	Modifier string
+	// SpaceBefore and SpaceAfter write a space around the tag, as resolved
+	// from Modifier and the file's @whitespace mode.
+	SpaceBefore bool
+	SpaceAfter  bool
 }
 
 func (t *TagStmt) Pos() source.Pos { return t.NodePos }
@@ -142,15 +166,7 @@ func buildAttrsCall(attrs []*TagAttribute, pos ...source.Pos) gnode.Expr {
 			}
 			continue
 		}
-		value := attr.Value
-		if value == nil {
-			if attr.IsFlag {
-				value = gnode.Str(attr.Name, 0)
-			} else {
-				value = gnode.Str("", 0)
-			}
-		}
-		addNamedArg(call, attr.Name, value)
+		addNamedArg(call, attr.Name, attrValue(attr))
 	}
 	writeCall := &gnode.CallExpr{Func: gnode.EIdent("write", 0)}
 	writeCall.Args.Values = append(writeCall.Args.Values, call)
@@ -329,7 +345,14 @@ type ForStmt struct {
 	Cond    gnode.Expr
 	Post    gnode.Stmt
 	Body    gnode.Stmts
-	Else    gnode.Stmts
+	// Else renders when the loop produced no item.
+	Else gnode.Stmts
+	// Loop reports that the block defines the implicit `loop` variable: the
+	// body mentions it or there is an Else branch.
+	Loop bool
+	// OuterLoop reports that an enclosing @for defines `loop`, which becomes
+	// this loop's `loop.parent`.
+	OuterLoop bool
 }
 
 func (s *ForStmt) Pos() source.Pos { return s.NodePos }
@@ -365,6 +388,160 @@ func (s *ForStmt) WriteCode(ctx *gnode.CodeWriteContext) {
 	ctx.WriteString("}")
 }
 
+// =============================================================================
+// WhileStmt — conditional loop block
+// =============================================================================
+
+type WhileStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	Cond    gnode.Expr
+	Body    gnode.Stmts
+}
+
+func (s *WhileStmt) Pos() source.Pos { return s.NodePos }
+func (s *WhileStmt) End() source.Pos { return s.NodeEnd }
+func (s *WhileStmt) StmtNode()       {}
+func (s *WhileStmt) String() string  { return "giom.While" }
+
+func (s *WhileStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteString("for ")
+	s.Cond.WriteCode(ctx)
+	ctx.WriteString(" {")
+	ctx.WriteSemi()
+	ctx.Depth++
+	ctx.WriteStmts(s.Body...)
+	ctx.Depth--
+	ctx.WriteSemi()
+	ctx.WriteString("}")
+}
+
+// =============================================================================
+// BranchStmt — @break / @continue
+// =============================================================================
+
+type BranchStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	// Token is token.Break or token.Continue.
+	Token token.Token
+}
+
+func (s *BranchStmt) Pos() source.Pos { return s.NodePos }
+func (s *BranchStmt) End() source.Pos { return s.NodeEnd }
+func (s *BranchStmt) StmtNode()       {}
+func (s *BranchStmt) String() string  { return "giom." + s.Token.String() }
+
+func (s *BranchStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteString(s.Token.String())
+}
+
+// =============================================================================
+// VerbatimStmt — @verbatim literal block
+// =============================================================================
+
+type VerbatimStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	// Text is the literal block, with its common indentation removed.
+	Text string
+	// Raw reports `@verbatim raw`: Text is written as trusted HTML instead of
+	// being escaped.
+	Raw bool
+}
+
+func (s *VerbatimStmt) Pos() source.Pos { return s.NodePos }
+func (s *VerbatimStmt) End() source.Pos { return s.NodeEnd }
+func (s *VerbatimStmt) StmtNode()       {}
+func (s *VerbatimStmt) String() string  { return "giom.Verbatim" }
+
+func (s *VerbatimStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteStmts(convertVerbatim(s)...)
+}
+
+// =============================================================================
+// EmbedStmt — :style / :script embedded block
+// =============================================================================
+
+type EmbedStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	// Name is the tag the block builds: "style" or "script".
+	Name string
+	// Parts are the literal text (*gnode.StrLit) and the `{= expr}`
+	// interpolations of the body, in order.
+	Parts []gnode.Expr
+}
+
+func (s *EmbedStmt) Pos() source.Pos { return s.NodePos }
+func (s *EmbedStmt) End() source.Pos { return s.NodeEnd }
+func (s *EmbedStmt) StmtNode()       {}
+func (s *EmbedStmt) String() string  { return "giom.Embed(" + s.Name + ")" }
+
+func (s *EmbedStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteStmts(convertEmbed(s)...)
+}
+
+// =============================================================================
+// StackStmt / PushStmt / OnceStmt — @stack, @push and @once
+// =============================================================================
+
+type StackStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	Name    gnode.Expr
+}
+
+func (s *StackStmt) Pos() source.Pos { return s.NodePos }
+func (s *StackStmt) End() source.Pos { return s.NodeEnd }
+func (s *StackStmt) StmtNode()       {}
+func (s *StackStmt) String() string  { return "giom.Stack" }
+
+func (s *StackStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteStmts(convertStack(s)...)
+}
+
+type PushStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	Name    gnode.Expr
+	Body    gnode.Stmts
+}
+
+func (s *PushStmt) Pos() source.Pos { return s.NodePos }
+func (s *PushStmt) End() source.Pos { return s.NodeEnd }
+func (s *PushStmt) StmtNode()       {}
+func (s *PushStmt) String() string  { return "giom.Push" }
+
+func (s *PushStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteStmts(convertPush(s)...)
+}
+
+type OnceStmt struct {
+	ast.NodeData
+	NodePos source.Pos
+	NodeEnd source.Pos
+	// Key identifies the block: the `@once key` expression, or a string of its
+	// source location when omitted.
+	Key  gnode.Expr
+	Body gnode.Stmts
+}
+
+func (s *OnceStmt) Pos() source.Pos { return s.NodePos }
+func (s *OnceStmt) End() source.Pos { return s.NodeEnd }
+func (s *OnceStmt) StmtNode()       {}
+func (s *OnceStmt) String() string  { return "giom.Once" }
+
+func (s *OnceStmt) WriteCode(ctx *gnode.CodeWriteContext) {
+	ctx.WriteStmts(convertOnce(s)...)
+}
+
 // =============================================================================
 // AssignStmt — variable assignment
 // =============================================================================
@@ -400,6 +577,24 @@ type CodeStmt struct {
 	Stmts     gnode.Stmts
 	TrimLeft  bool
 	TrimRight bool
+	// Block reports a `~~ … ~~` block, as opposed to a `~` line.
+	Block bool
+	// Import, for an `@import` directive, is the imported module and the
+	// names it binds.
+	Import *ImportSpec
+}
+
+// ImportSpec describes the module of an `@import` directive and the names it
+// binds in the importing template.
+type ImportSpec struct {
+	// Path is the module path, unquoted.
+	Path string
+	// Alias is the name bound to the whole module: `as alias`, or the
+	// `**rest` of a destructured import.
+	Alias string
+	// Names maps each destructured local name to the exported name it reads
+	// (`{ name }`, `{ name: local }`).
+	Names map[string]string
 }
 
 func (c *CodeStmt) Pos() source.Pos { return c.NodePos }
@@ -582,6 +777,12 @@ func (s *SlotPassStmt) End() source.Pos { return s.NodeEnd }
 func (s *SlotPassStmt) StmtNode()       {}
 func (s *SlotPassStmt) String() string  { return "giom.SlotPass" }
 
+// Implicit reports whether s is the main slot made of the content a component
+// call holds outside `@slot #name` blocks.
+func (s *SlotPassStmt) Implicit() bool {
+	return len(s.Body) > 0 && s.NodePos == s.Body[0].Pos() && s.Name != nil && !s.Name.Pos().IsValid()
+}
+
 func (s *SlotPassStmt) WriteCode(ctx *gnode.CodeWriteContext) {
 	ctx.WriteString("const $slot = func")
 	if s.FuncType != nil {
@@ -834,6 +1035,13 @@ var (
 	_ gnode.Stmt = (*CommentStmt)(nil)
 	_ gnode.Stmt = (*IfStmt)(nil)
 	_ gnode.Stmt = (*ForStmt)(nil)
+	_ gnode.Stmt = (*WhileStmt)(nil)
+	_ gnode.Stmt = (*BranchStmt)(nil)
+	_ gnode.Stmt = (*VerbatimStmt)(nil)
+	_ gnode.Stmt = (*EmbedStmt)(nil)
+	_ gnode.Stmt = (*StackStmt)(nil)
+	_ gnode.Stmt = (*PushStmt)(nil)
+	_ gnode.Stmt = (*OnceStmt)(nil)
 	_ gnode.Stmt = (*AssignStmt)(nil)
 	_ gnode.Stmt = (*CodeStmt)(nil)
 	_ gnode.Stmt = (*FuncDecl)(nil)
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"reflect"
+
+	gnode "github.com/gad-lang/gad/parser/node"
+)
+
+// Walk calls fn for each statement of stmts in source order, then, when fn
+// returns true, walks the statements nested in it: the bodies of tags,
+// directives and declarations, the pieces of text (with their `#[tag]` spans)
+// and the code and slots of component calls.
+func Walk(stmts gnode.Stmts, fn func(gnode.Stmt) bool) {
+	for _, stmt := range stmts {
+		if stmt == nil || !fn(stmt) {
+			continue
+		}
+		nested(stmt, func(list *gnode.Stmts) {
+			Walk(*list, fn)
+		}, func(st gnode.Stmt) {
+			Walk(gnode.Stmts{st}, fn)
+		})
+	}
+}
+
+// Rewrite replaces stmts, then each statement list nested in them as Walk
+// finds them, with what fn returns for it, and returns the new stmts. The
+// pieces of a text and the slots passed to a component call are not lists of
+// their own: fn only gets the lists nested in them, e.g. the bodies of
+// `#[tag]` spans and of the slots.
+func Rewrite(stmts gnode.Stmts, fn func(gnode.Stmts) gnode.Stmts) gnode.Stmts {
+	stmts = fn(stmts)
+	for _, stmt := range stmts {
+		rewriteNested(stmt, fn)
+	}
+	return stmts
+}
+
+func rewriteNested(stmt gnode.Stmt, fn func(gnode.Stmts) gnode.Stmts) {
+	if stmt == nil {
+		return
+	}
+	nested(stmt, func(list *gnode.Stmts) {
+		*list = Rewrite(*list, fn)
+	}, func(st gnode.Stmt) {
+		rewriteNested(st, fn)
+	})
+}
+
+// nested calls list for each statement list nested in stmt and one for each
+// statement nested in it outside such a list, in source order.
+func nested(stmt gnode.Stmt, list func(*gnode.Stmts), one func(gnode.Stmt)) {
+	switch s := stmt.(type) {
+	case *TextStmt:
+		for _, st := range s.Stmts {
+			one(st)
+		}
+	case *TagStmt:
+		list(&s.Body)
+	case *CommentStmt:
+		list(&s.Body)
+	case *IfStmt:
+		list(&s.Body)
+		for _, eif := range s.ElseIfs {
+			list(&eif.Body)
+		}
+		list(&s.Else)
+	case *ForStmt:
+		list(&s.Body)
+		list(&s.Else)
+	case *WhileStmt:
+		list(&s.Body)
+	case *MatchStmt:
+		for _, c := range s.Cases {
+			list(&c.Body)
+		}
+		list(&s.Default)
+	case *PushStmt:
+		list(&s.Body)
+	case *OnceStmt:
+		list(&s.Body)
+	case *FuncDecl:
+		list(&s.Body)
+	case *CompDecl:
+		list(&s.Body)
+	case *CompCallStmt:
+		list(&s.InitStmts)
+		for _, sp := range s.SlotPass {
+			one(sp)
+		}
+	case *SlotDecl:
+		if s.Wrap != nil {
+			one(s.Wrap)
+		}
+		list(&s.Body)
+	case *SlotPassStmt:
+		list(&s.Body)
+	case *WrapStmt:
+		list(&s.Body)
+	}
+}
+
+var identExprType = reflect.TypeOf((*gnode.IdentExpr)(nil))
+
+// UsesIdent reports whether an identifier expression named name appears
+// anywhere in stmts, including the expressions of attributes, interpolations
+// and code. Text, string literals and comments that merely contain the word
+// do not count.
+func UsesIdent(stmts gnode.Stmts, name string) bool {
+	type ref struct {
+		t reflect.Type
+		p uintptr
+	}
+	seen := map[ref]bool{}
+	var visit func(v reflect.Value) bool
+	visit = func(v reflect.Value) bool {
+		switch v.Kind() {
+		case reflect.Interface:
+			return !v.IsNil() && visit(v.Elem())
+		case reflect.Ptr:
+			r := ref{v.Type(), v.Pointer()}
+			if v.IsNil() || seen[r] {
+				return false
+			}
+			seen[r] = true
+			if v.Type() == identExprType {
+				return v.Elem().FieldByName("Name").String() == name
+			}
+			return visit(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if visit(v.Field(i)) {
+					return true
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			if k := v.Type().Elem().Kind(); k != reflect.Interface && k != reflect.Ptr && k != reflect.Struct && k != reflect.Slice {
+				return false
+			}
+			for i := 0; i < v.Len(); i++ {
+				if visit(v.Index(i)) {
+					return true
+				}
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				if visit(iter.Value()) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(reflect.ValueOf(stmts))
+}
@@ -146,3 +146,50 @@ func TestAttributeValuePositionMapsToSourceLine(t *testing.T) {
 		t.Fatalf("title value resolved to %d:%d, want 5:15", p.Line, p.Column)
 	}
 }
+
+func TestClassToggleAndInterpolation(t *testing.T) {
+	// `.name?(cond)` keeps the line going; `.{expr}` is an expression value.
+	src := "@main\n" +
+		"    a.nav.active?(url == current).{variant} Home\n"
+	fs, tag := parseTagFile(t, src)
+	if got := attrNames(tag); len(got) != 3 {
+		t.Fatalf("got %v, want three class attributes", got)
+	}
+	active, variant := tag.Attributes[1], tag.Attributes[2]
+	if !active.Toggle || active.Condition == nil {
+		t.Fatalf("active: toggle=%v condition=%v", active.Toggle, active.Condition)
+	}
+	if p := fs.Position(active.Condition.Pos()); p.Line != 2 || p.Column != 19 {
+		t.Fatalf("condition resolved to %d:%d, want 2:19", p.Line, p.Column)
+	}
+	if _, ok := variant.Value.(*node.IdentExpr); !ok {
+		t.Fatalf("variant value is %T, want an identifier", variant.Value)
+	}
+	if p := fs.Position(variant.Value.Pos()); p.Line != 2 || p.Column != 36 {
+		t.Fatalf("variant resolved to %d:%d, want 2:36", p.Line, p.Column)
+	}
+	if len(tag.Body) != 1 {
+		t.Fatalf("expected the text to stay in the body, got %d stmts", len(tag.Body))
+	}
+}
+
+func TestAttributeSpread(t *testing.T) {
+	src := "@main\n" +
+		"    div.btn&attributes(attrs)[...more, title=\"t\"].x hi\n"
+	fs, tag := parseTagFile(t, src)
+	if got := attrNames(tag); len(got) != 5 {
+		t.Fatalf("got %v, want class, two spreads, title and class", got)
+	}
+	for i, col := range map[int]int{1: 24, 2: 34} {
+		a := tag.Attributes[i]
+		if !a.Spread {
+			t.Fatalf("attribute %d is not a spread", i)
+		}
+		if p := fs.Position(a.Value.Pos()); p.Line != 2 || p.Column != col {
+			t.Fatalf("spread %d resolved to %d:%d, want 2:%d", i, p.Line, p.Column, col)
+		}
+	}
+	if len(tag.Body) != 1 {
+		t.Fatalf("expected the text to stay in the body, got %d stmts", len(tag.Body))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	gadparser "github.com/gad-lang/gad/parser"
 	gnode "github.com/gad-lang/gad/parser/node"
@@ -26,6 +28,16 @@ type Parser struct {
 	filename  string
 	comps     []*giomnode.CompDecl
 	compStack []*giomnode.CompDecl
+	// forStack collects, for each @for being parsed, its directly nested @for
+	// blocks, which learn whether the outer loop defines `loop` once its whole
+	// body is known.
+	forStack [][]*giomnode.ForStmt
+	// loopDepth counts the @for/@while bodies enclosing the current statement,
+	// validating @break and @continue.
+	loopDepth int
+	// whitespace is the `@whitespace` pragma mode ("preserve", "collapse" or
+	// empty, which collapses).
+	whitespace string
 }
 
 // NewParser creates a new Parser for the given source file.
@@ -99,6 +111,12 @@ func (p *Parser) ParseFile() (_ *giomnode.File, err error) {
 	}
 
 	for p.Token.Token != giomtoken.EOF && p.Token.Token != token.Illegal {
+		if p.Token.Token == giomtoken.Whitespace && len(file.Stmts) == 0 && file.Whitespace == "" {
+			file.Whitespace = stringData(p.Token, "value", "")
+			p.whitespace = file.Whitespace
+			p.Next()
+			continue
+		}
 		stmt := p.parseStmt()
 
 		if p.Errors.Len() > 0 {
@@ -110,6 +128,7 @@ func (p *Parser) ParseFile() (_ *giomnode.File, err error) {
 		}
 	}
 
+	p.spaceSiblings(file.Stmts)
 	file.Comps = p.comps
 	return file, nil
 }
@@ -141,6 +160,16 @@ func (p *Parser) parseStmt() gnode.Stmt {
 		return nil
 	case giomtoken.For:
 		return p.parseFor()
+	case giomtoken.Verbatim:
+		return p.parseVerbatim()
+	case giomtoken.Embed:
+		return p.parseEmbed()
+	case giomtoken.While:
+		return p.parseWhile()
+	case giomtoken.Branch:
+		return p.parseBranch()
+	case giomtoken.Stack:
+		return p.parseStack()
 	case giomtoken.Assignment:
 		return p.parseAssignment()
 	case giomtoken.Code:
@@ -175,6 +204,10 @@ func (p *Parser) parseStmt() gnode.Stmt {
 		return nil
 	case giomtoken.Export:
 		return p.parseExport()
+	case giomtoken.Whitespace:
+		p.Error(p.Token.Pos, "@whitespace must come first in the file")
+		p.Next()
+		return nil
 	case giomtoken.Blank:
 		p.Next()
 		return nil
@@ -201,9 +234,41 @@ func (p *Parser) parseBlock(parent gnode.Stmt) gnode.Stmts {
 	}
 
 	p.expect(giomtoken.Outdent)
+	p.spaceSiblings(stmts)
 	return stmts
 }
 
+// spaceSiblings puts a space between each pair of adjacent tag and text
+// siblings under `@whitespace preserve`, unless the `>` modifier of the first
+// or the `<` modifier of the second trims it.
+func (p *Parser) spaceSiblings(stmts gnode.Stmts) {
+	if p.whitespace != "preserve" {
+		return
+	}
+	var prev gnode.Stmt
+	for _, s := range stmts {
+		switch t := s.(type) {
+		case *giomnode.TagStmt:
+			if prev != nil && !trimsAfter(prev) && !strings.Contains(t.Modifier, "<") {
+				t.SpaceBefore = true
+			}
+		case *giomnode.TextStmt:
+			if prev != nil && !trimsAfter(prev) {
+				t.SpaceBefore = true
+			}
+		default:
+			prev = nil
+			continue
+		}
+		prev = s
+	}
+}
+
+func trimsAfter(s gnode.Stmt) bool {
+	t, ok := s.(*giomnode.TagStmt)
+	return ok && strings.Contains(t.Modifier, ">")
+}
+
 // =============================================================================
 // Parse functions for specific constructs
 // =============================================================================
@@ -254,14 +319,16 @@ func (p *Parser) parseText() *giomnode.TextStmt {
 	t := &giomnode.TextStmt{
 		NodePos: tok.Pos,
 		NodeEnd: tok.Pos + source.Pos(len(tok.Literal)),
+		Marker:  stringData(tok, "space", "") == "true",
 	}
+	t.SpaceBefore = t.Marker
 
 	if content != "" {
 		base := noBase
 		if positions, ok := tokenValuePos(tok); ok && len(positions) > 0 {
 			base = positions[0]
 		}
-		stmts, err := parseTextGadAt(content, base)
+		stmts, err := p.parseTextSpans(content, base, tok.Pos)
 		if err == nil {
 			t.Stmts = stmts
 		}
@@ -270,6 +337,164 @@ func (p *Parser) parseText() *giomnode.TextStmt {
 	return t
 }
 
+// parseTextSpans parses text content holding `#[tag …]` inline tag spans: the
+// text around the spans is mixed text/expression content and each span becomes
+// a nested TagStmt. base is the absolute position of content[0] (or noBase)
+// and pos the position errors are reported at when base is unknown.
+func (p *Parser) parseTextSpans(content string, base, pos source.Pos) (gnode.Stmts, error) {
+	spans := findInlineTags(content)
+	if len(spans) == 0 {
+		return parseTextGadAt(content, base)
+	}
+	at := func(offset int) source.Pos {
+		if base == noBase {
+			return noBase
+		}
+		return base + source.Pos(offset)
+	}
+
+	var out gnode.Stmts
+	// text adds content[from:to]; only the outer ends of the line are trimmed,
+	// the spaces around a span are part of the text.
+	text := func(from, to int) error {
+		if from == 0 {
+			from = len(content) - len(strings.TrimLeft(content, " \t"))
+		}
+		if to == len(content) {
+			to = len(strings.TrimRight(content, " \t"))
+		}
+		if from >= to {
+			return nil
+		}
+		stmts, err := parseGadAt(content[from:to], at(from), true)
+		out = append(out, stmts...)
+		return err
+	}
+	prev := 0
+	for _, sp := range spans {
+		if err := text(prev, sp.start); err != nil {
+			return nil, err
+		}
+		if content[sp.start] == '\\' {
+			// An escaped `\#[`: drop the backslash, keep the rest as text.
+			prev = sp.end
+			continue
+		}
+		errPos := at(sp.start)
+		if errPos == noBase {
+			errPos = pos
+		}
+		if tag := p.parseInlineTag(content[sp.start+2:sp.end-1], at(sp.start+2), errPos); tag != nil {
+			out = append(out, tag)
+		}
+		prev = sp.end
+	}
+	if err := text(prev, len(content)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseInlineTag parses the source of a `#[…]` span, a single tag line, at its
+// absolute position in the file.
+func (p *Parser) parseInlineTag(src string, base, errPos source.Pos) *giomnode.TagStmt {
+	lead := len(src) - len(strings.TrimLeft(src, " \t"))
+	src = src[lead:]
+	fileSet := source.NewFileSet()
+	fbase := -1
+	if base != noBase && int(base) >= fileSet.Base {
+		fbase = int(base) + lead
+	}
+	sub := NewParser(fileSet.AddFileData(p.file.Name, fbase, []byte(src)))
+	sub.whitespace = p.whitespace
+	file, err := sub.ParseFile()
+	if err != nil {
+		p.Error(errPos, fmt.Sprintf("invalid inline tag #[%s]: %v", src, err))
+		return nil
+	}
+	if len(file.Stmts) == 1 {
+		if tag, ok := file.Stmts[0].(*giomnode.TagStmt); ok {
+			return tag
+		}
+	}
+	p.Error(errPos, fmt.Sprintf("inline tag #[%s] must be a single tag", src))
+	return nil
+}
+
+// findInlineTags returns the `#[…]` spans of text content ([start, end) with
+// end after the closing `]`), skipping `{…}` interpolations and `#[` not
+// followed by a tag name. The backslash of an escaped `\#[` is returned as a
+// one-byte span of its own.
+func findInlineTags(s string) (spans []attrSpan) {
+	brace := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{':
+			brace++
+		case c == '}' && brace > 0:
+			brace--
+		case (c == '"' || c == '\'' || c == '`') && brace > 0:
+			i = skipQuoted(s, i)
+		case c == '\\' && brace == 0 && strings.HasPrefix(s[i+1:], "#["):
+			spans = append(spans, attrSpan{i, i + 1})
+			i += 2
+		case c == '#' && brace == 0 && i+1 < len(s) && s[i+1] == '[' && inlineTagStart(s[i+2:]):
+			if end := closeInlineTag(s, i+1); end > 0 {
+				spans = append(spans, attrSpan{i, end})
+				i = end - 1
+			}
+		}
+	}
+	return
+}
+
+// inlineTagStart reports whether s, the text after a `#[`, starts with a tag
+// name: a letter or `_`, after optional blanks. Anything else, as in
+// `Issue #[123]`, is text.
+func inlineTagStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(strings.TrimLeft(s, " \t"))
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// closeInlineTag returns the index after the `]` closing the span bracket at
+// s[open], or -1. Nested brackets (attribute groups, inner spans) and quoted
+// strings within attribute groups and interpolations are skipped.
+func closeInlineTag(s string, open int) int {
+	depth, brace := 0, 0
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{':
+			brace++
+		case c == '}' && brace > 0:
+			brace--
+		case (c == '"' || c == '\'' || c == '`') && (depth > 1 || brace > 0):
+			i = skipQuoted(s, i)
+		case c == '[' && brace == 0:
+			depth++
+		case c == ']' && brace == 0:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the quote closing the string opened at s[i]
+// (the last index when unterminated).
+func skipQuoted(s string, i int) int {
+	q := s[i]
+	for i++; i < len(s); i++ {
+		if s[i] == '\\' && q != '`' {
+			i++
+		} else if s[i] == q {
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
 func (p *Parser) parseHtml() *giomnode.HtmlStmt {
 	tok := p.Token
 	p.expect(giomtoken.Html)
@@ -312,9 +537,29 @@ func (p *Parser) parseTag() *giomnode.TagStmt {
 		}
 	}
 
+	if p.Token.Token == giomtoken.TagSpace {
+		tag.Modifier = stringData(p.Token, "value", "")
+		p.Next()
+		if p.whitespace != "preserve" {
+			tag.SpaceBefore = strings.Contains(tag.Modifier, "<")
+			tag.SpaceAfter = strings.Contains(tag.Modifier, ">")
+		}
+	}
+
 	tag.SelfClosing = giomnode.IsSelfClosing(name)
 
-	if p.Token.Token == giomtoken.Indent {
+	if p.Token.Token == giomtoken.InlineNest {
+		// `tag: child …` nests a single child on the same line; the child (the
+		// innermost one of a chain) takes the rest of the line and the block.
+		p.expect(giomtoken.InlineNest)
+		if p.Token.Token != giomtoken.Tag {
+			p.Error(p.Token.Pos, fmt.Sprintf("expected a tag after ':', got %s (%s)", giomtoken.String(p.Token.Token), p.Token.Literal))
+			return tag
+		}
+		child := p.parseTag()
+		child.Inline = true
+		tag.Body = gnode.Stmts{child}
+	} else if p.Token.Token == giomtoken.Indent {
 		tag.Body = p.parseBlock(tag)
 	} else if p.Token.Token == giomtoken.Text {
 		tag.Body = gnode.Stmts{p.parseText()}
@@ -344,12 +589,22 @@ func (p *Parser) parseInlineAttribute() *giomnode.TagAttribute {
 		return attr
 	case giomtoken.ClassName:
 		p.expect(giomtoken.ClassName)
-		attr := &giomnode.TagAttribute{
-			Name:  "class",
-			Value: gnode.Str(stringData(tok, "value", ""), tok.Pos),
+		attr := &giomnode.TagAttribute{Name: "class"}
+		if expr := stringData(tok, "expr", ""); expr != "" {
+			// `.{expr}`: the expression starts after the leading ".{".
+			attr.Value = parseExprStr(expr, tok.Pos+2)
+		} else {
+			attr.Value = gnode.Str(stringData(tok, "value", ""), tok.Pos)
 		}
 		if cond := stringData(tok, "condition", ""); cond != "" {
-			attr.Condition = parseExprStr(cond, tok.Pos)
+			condPos := tok.Pos
+			if v, ok := tok.GetOk("condOffset"); ok {
+				if off, ok := v.(int); ok {
+					condPos += source.Pos(off)
+					attr.Toggle = true
+				}
+			}
+			attr.Condition = parseExprStr(cond, condPos)
 		}
 		return attr
 	default:
@@ -409,8 +664,11 @@ func (p *Parser) parseFor() *giomnode.ForStmt {
 		Cond:    parseExprStr(condStr, tok.Pos),
 	}
 
+	p.forStack = append(p.forStack, nil)
 	if p.Token.Token == giomtoken.Indent {
+		p.loopDepth++
 		s.Body = p.parseBlock(s)
+		p.loopDepth--
 	}
 
 	if p.Token.Token == giomtoken.Else {
@@ -420,10 +678,227 @@ func (p *Parser) parseFor() *giomnode.ForStmt {
 		}
 	}
 
+	nested := p.forStack[len(p.forStack)-1]
+	p.forStack = p.forStack[:len(p.forStack)-1]
+	// A variable named `loop` is the user's: the block then only defines the
+	// loop object for an @else, which cannot have both.
+	bindsLoop := s.Binds("loop")
+	if bindsLoop && len(s.Else) > 0 {
+		p.Error(tok.Pos, "a @for with @else cannot name its variable loop")
+	}
+	s.Loop = len(s.Else) > 0 || !bindsLoop && giomnode.UsesIdent(s.Body, "loop")
+	for _, n := range nested {
+		n.OuterLoop = s.Loop
+	}
+	if n := len(p.forStack); n > 0 {
+		p.forStack[n-1] = append(p.forStack[n-1], s)
+	}
+
 	s.NodeEnd = bodyEndFor(s)
 	return s
 }
 
+// parseVerbatim parses a `@verbatim [raw]` block, whose literal text the
+// scanner already collected.
+func (p *Parser) parseVerbatim() *giomnode.VerbatimStmt {
+	tok := p.Token
+	p.expect(giomtoken.Verbatim)
+
+	s := &giomnode.VerbatimStmt{
+		NodePos: tok.Pos,
+		NodeEnd: tok.Pos + source.Pos(len(tok.Literal)),
+		Text:    stringData(tok, "text", ""),
+		Raw:     stringData(tok, "mode", "") == "raw",
+	}
+	if end := posData(tok, "end"); end != noBase && end > s.NodeEnd {
+		s.NodeEnd = end
+	}
+	return s
+}
+
+// parseEmbed parses a `:style` / `:script` embed. Its body is literal text
+// where only `{= expr}` interpolates; the parts alternate between literal
+// string literals and the interpolated expressions.
+func (p *Parser) parseEmbed() *giomnode.EmbedStmt {
+	tok := p.Token
+	p.expect(giomtoken.Embed)
+
+	s := &giomnode.EmbedStmt{
+		NodePos: tok.Pos,
+		NodeEnd: tok.Pos + source.Pos(len(tok.Literal)),
+		Name:    stringData(tok, "value", ""),
+	}
+	if end := posData(tok, "end"); end != noBase && end > s.NodeEnd {
+		s.NodeEnd = end
+	}
+	v, _ := tok.GetOk("lines")
+	lines, _ := v.([]string)
+	v, _ = tok.GetOk("linePos")
+	linePos, _ := v.([]source.Pos)
+
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			s.Parts = append(s.Parts, gnode.Str(lit.String(), tok.Pos))
+			lit.Reset()
+		}
+	}
+	for i, line := range lines {
+		if i > 0 {
+			lit.WriteByte('\n')
+		}
+		for off := 0; ; {
+			j := strings.Index(line[off:], "{=")
+			if j < 0 {
+				lit.WriteString(line[off:])
+				break
+			}
+			j += off
+			end := closeInterpolation(line, j)
+			if end < 0 {
+				lit.WriteString(line[off:])
+				break
+			}
+			lit.WriteString(line[off:j])
+			flush()
+			pos := noBase
+			if i < len(linePos) {
+				pos = linePos[i] + source.Pos(j+2)
+			}
+			s.Parts = append(s.Parts, parseExprStr(line[j+2:end-1], pos))
+			off = end
+		}
+	}
+	flush()
+	return s
+}
+
+// closeInterpolation returns the index after the `}` closing the `{` at
+// s[open], or -1. Quoted strings and nested braces are skipped.
+func closeInterpolation(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'', '`':
+			i = skipQuoted(s, i)
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+func (p *Parser) parseWhile() *giomnode.WhileStmt {
+	tok := p.Token
+	p.expect(giomtoken.While)
+
+	s := &giomnode.WhileStmt{
+		NodePos: tok.Pos,
+		Cond:    parseExprStr(stringData(tok, "value", ""), tok.Pos),
+	}
+
+	if p.Token.Token == giomtoken.Indent {
+		p.loopDepth++
+		s.Body = p.parseBlock(s)
+		p.loopDepth--
+	}
+
+	if len(s.Body) > 0 {
+		s.NodeEnd = s.Body[len(s.Body)-1].End()
+	} else {
+		s.NodeEnd = tok.Pos + source.Pos(len(tok.Literal))
+	}
+	return s
+}
+
+// parseBranch parses @break and @continue, which are only valid inside the
+// body of a @for or @while of the same function.
+func (p *Parser) parseBranch() *giomnode.BranchStmt {
+	tok := p.Token
+	p.expect(giomtoken.Branch)
+
+	s := &giomnode.BranchStmt{
+		NodePos: tok.Pos,
+		NodeEnd: tok.Pos + source.Pos(len(tok.Literal)),
+		Token:   token.Break,
+	}
+	if stringData(tok, "value", "") == "continue" {
+		s.Token = token.Continue
+	}
+	if p.loopDepth == 0 {
+		p.Error(tok.Pos, fmt.Sprintf("@%s outside of a loop", s.Token))
+	}
+	return s
+}
+
+// parseStack parses `@stack name`, `@push name` and `@once [key]`; the last
+// two take a body. A @once without a key is keyed by its place in the source,
+// so it is kept once however many times its component renders.
+func (p *Parser) parseStack() gnode.Stmt {
+	tok := p.Token
+	p.expect(giomtoken.Stack)
+
+	pos, end := tok.Pos, tok.Pos+source.Pos(len(tok.Literal))
+	kind := stringData(tok, "value", "")
+	var arg gnode.Expr
+	if expr := stringData(tok, "expr", ""); expr != "" {
+		argPos := pos
+		if v, ok := tok.GetOk("exprOffset"); ok {
+			if off, ok := v.(int); ok {
+				argPos += source.Pos(off)
+			}
+		}
+		arg = parseExprStr(expr, argPos)
+	} else if kind == "once" {
+		arg = gnode.Str(fmt.Sprintf("%s:%d", p.file.Name, int(pos)-p.file.Base), pos)
+	} else {
+		p.Error(pos, fmt.Sprintf("@%s requires a stack name", kind))
+		arg = gnode.Str("", pos)
+	}
+
+	switch kind {
+	case "stack":
+		return &giomnode.StackStmt{NodePos: pos, NodeEnd: end, Name: arg}
+	case "once":
+		s := &giomnode.OnceStmt{NodePos: pos, NodeEnd: end, Key: arg}
+		s.Body = p.parseStackBody(s)
+		if len(s.Body) > 0 {
+			s.NodeEnd = s.Body[len(s.Body)-1].End()
+		}
+		return s
+	default:
+		s := &giomnode.PushStmt{NodePos: pos, NodeEnd: end, Name: arg}
+		s.Body = p.parseStackBody(s)
+		if len(s.Body) > 0 {
+			s.NodeEnd = s.Body[len(s.Body)-1].End()
+		}
+		return s
+	}
+}
+
+// parseStackBody parses the optional indented body of a @push or @once.
+func (p *Parser) parseStackBody(parent gnode.Stmt) gnode.Stmts {
+	if p.Token.Token != giomtoken.Indent {
+		return nil
+	}
+	return p.parseBlock(parent)
+}
+
+// parseFuncBlock parses the block of a body that compiles to its own Gad
+// function (component, function, slot): the loops around it neither enclose
+// its @break/@continue nor parent its `loop`.
+func (p *Parser) parseFuncBlock(parent gnode.Stmt) gnode.Stmts {
+	forStack, loopDepth := p.forStack, p.loopDepth
+	p.forStack, p.loopDepth = nil, 0
+	defer func() { p.forStack, p.loopDepth = forStack, loopDepth }()
+	return p.parseBlock(parent)
+}
+
 func (p *Parser) parseAssignment() *giomnode.AssignStmt {
 	tok := p.Token
 	p.expect(giomtoken.Assignment)
@@ -464,6 +939,7 @@ func (p *Parser) parseCode() *giomnode.CodeStmt {
 			// original newlines, so parsing at the first line's base position
 			// keeps every statement mapped to its real source line/column.
 			if tok.Literal == "" {
+				s.Block = true
 				joined := strings.Join(v, "\n")
 				if trimmed := strings.TrimSpace(joined); trimmed != "" {
 					base := noBase
@@ -537,6 +1013,7 @@ func (p *Parser) parseImportModule() *giomnode.CodeStmt {
 	s := &giomnode.CodeStmt{
 		NodePos: tok.Pos,
 		NodeEnd: tok.Pos + source.Pos(len(tok.Literal)),
+		Import:  importSpec(path, ident, destructure),
 	}
 
 	if err == nil && stmts != nil {
@@ -549,40 +1026,71 @@ func (p *Parser) parseImportModule() *giomnode.CodeStmt {
 func expandImportDestructure(destructure, path string, pos source.Pos) string {
 	tmp := fmt.Sprintf("giom_import_%d", pos)
 	parts := []string{fmt.Sprintf("var %s = import(%s)", tmp, path)}
+	for _, f := range importFields(destructure) {
+		switch {
+		case f.rest:
+			parts = append(parts, fmt.Sprintf("var %s = %s", f.alias, tmp))
+		case f.fallback != "":
+			parts = append(parts, fmt.Sprintf("var %s = %s.%s ?? %s", f.alias, tmp, f.name, f.fallback))
+		default:
+			parts = append(parts, fmt.Sprintf("var %s = %s.%s", f.alias, tmp, f.name))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// importField is one field of a destructured `@import { … }`: `name`,
+// `name: alias`, `name = fallback` or `**alias` (rest).
+type importField struct {
+	name, alias, fallback string
+	rest                  bool
+}
+
+// importFields parses the fields of a destructured import; empty fields are
+// skipped.
+func importFields(destructure string) (fields []importField) {
 	for _, field := range strings.Split(destructure, ",") {
 		field = strings.TrimSpace(field)
 		if field == "" {
 			continue
 		}
 		if strings.HasPrefix(field, "**") {
-			alias := strings.TrimSpace(strings.TrimPrefix(field, "**"))
-			if alias != "" {
-				parts = append(parts, fmt.Sprintf("var %s = %s", alias, tmp))
+			if alias := strings.TrimSpace(strings.TrimPrefix(field, "**")); alias != "" {
+				fields = append(fields, importField{alias: alias, rest: true})
 			}
 			continue
 		}
-		name := field
-		alias := field
-		fallback := ""
+		f := importField{name: field, alias: field}
 		if before, after, ok := strings.Cut(field, "="); ok {
-			name = strings.TrimSpace(before)
-			alias = name
-			fallback = strings.TrimSpace(after)
+			f.name = strings.TrimSpace(before)
+			f.alias = f.name
+			f.fallback = strings.TrimSpace(after)
 		}
 		if before, after, ok := strings.Cut(field, ":"); ok {
-			name = strings.TrimSpace(before)
-			alias = strings.TrimSpace(after)
+			f.name = strings.TrimSpace(before)
+			f.alias = strings.TrimSpace(after)
+		}
+		if f.name != "" && f.alias != "" {
+			fields = append(fields, f)
 		}
-		if name == "" || alias == "" {
+	}
+	return
+}
+
+// importSpec returns the ImportSpec of an `@import` directive.
+func importSpec(path, ident, destructure string) *giomnode.ImportSpec {
+	spec := &giomnode.ImportSpec{Path: strings.Trim(path, `"`), Alias: ident}
+	for _, f := range importFields(destructure) {
+		if f.rest {
+			spec.Alias = f.alias
 			continue
 		}
-		value := fmt.Sprintf("%s.%s", tmp, name)
-		if fallback != "" {
-			value = fmt.Sprintf("%s ?? %s", value, fallback)
+		if spec.Names == nil {
+			spec.Names = map[string]string{}
 		}
-		parts = append(parts, fmt.Sprintf("var %s = %s", alias, value))
+		spec.Names[f.alias] = f.name
 	}
-	return strings.Join(parts, "\n")
+	return spec
 }
 
 // =============================================================================
@@ -616,6 +1124,13 @@ func (p *Parser) parseCompParams(argsStr string, pos source.Pos) *gnode.FuncPara
 // mirroring GAD KeyValueArray `(; … )`. A trailing `? condition` on the group
 // applies to every attribute in it.
 func (p *Parser) parseAttributeGroup(tok gadparser.PToken) []*giomnode.TagAttribute {
+	if spread := stringData(tok, "spread", ""); spread != "" {
+		// `&attributes(expr)`: the expression follows the prefix.
+		return []*giomnode.TagAttribute{{
+			Spread: true,
+			Value:  parseExprStr(spread, tok.Pos+source.Pos(len(attrSpreadPrefix))),
+		}}
+	}
 	inner := stringData(tok, "inner", "")
 
 	var cond gnode.Expr
@@ -651,8 +1166,8 @@ func isAttrNameChar(c byte) bool {
 		(c >= '0' && c <= '9') || c == '_' || c == '-' || c == ':' || c == '@' || c == '.'
 }
 
-// parseAttributeEntry parses a single `name`, `name=value` or `name="raw"`
-// attribute from an entry slice. base is the absolute position of entry[0], so
+// parseAttributeEntry parses a single `name`, `name=value`, `name="raw"` or
+// `...spread` attribute from an entry slice. base is the absolute position of entry[0], so
 // the value expression maps back to the original source.
 func parseAttributeEntry(entry string, base source.Pos) *giomnode.TagAttribute {
 	// Skip leading whitespace, advancing base to keep positions aligned.
@@ -660,6 +1175,10 @@ func parseAttributeEntry(entry string, base source.Pos) *giomnode.TagAttribute {
 	for i < len(entry) && (entry[i] == ' ' || entry[i] == '\t' || entry[i] == '\n' || entry[i] == '\r') {
 		i++
 	}
+	if strings.HasPrefix(entry[i:], "...") {
+		// `...expr` spreads a collection of attributes.
+		return &giomnode.TagAttribute{Spread: true, Value: parseExprStr(entry[i+3:], base+source.Pos(i+3))}
+	}
 	nameStart := i
 	for i < len(entry) && isAttrNameChar(entry[i]) {
 		i++
@@ -978,7 +1497,7 @@ func (p *Parser) parseFunc() *giomnode.FuncDecl {
 	}
 
 	if p.Token.Token == giomtoken.Indent {
-		f.Body = p.parseBlock(f)
+		f.Body = p.parseFuncBlock(f)
 	}
 
 	if len(f.Body) > 0 {
@@ -1011,7 +1530,7 @@ func (p *Parser) parseComp() *giomnode.CompDecl {
 
 	p.compStack = append(p.compStack, comp)
 	if p.Token.Token == giomtoken.Indent {
-		comp.Body = p.parseBlock(comp)
+		comp.Body = p.parseFuncBlock(comp)
 	}
 	p.compStack = p.compStack[:len(p.compStack)-1]
 
@@ -1080,7 +1599,7 @@ func (p *Parser) parseSlot() *giomnode.SlotDecl {
 	}
 
 	if p.Token.Token == giomtoken.Indent {
-		s.Body = p.parseBlock(s)
+		s.Body = p.parseFuncBlock(s)
 		if len(s.Body) > 0 {
 			if w, ok := s.Body[0].(*giomnode.WrapStmt); ok {
 				s.Wrap = w
@@ -1134,7 +1653,7 @@ func (p *Parser) parseSlotPass() *giomnode.SlotPassStmt {
 	}
 
 	if p.Token.Token == giomtoken.Indent {
-		s.Body = p.parseBlock(s)
+		s.Body = p.parseFuncBlock(s)
 	}
 
 	if len(s.Body) > 0 {
@@ -1180,7 +1699,7 @@ func (p *Parser) parseCompCall() *giomnode.CompCallStmt {
 	}
 
 	if p.Token.Token == giomtoken.Indent {
-		block := p.parseBlock(call)
+		block := p.parseFuncBlock(call)
 		var lastMainSlot *giomnode.SlotPassStmt
 		for _, child := range block {
 			switch t := child.(type) {
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
 	gnode "github.com/gad-lang/gad/parser/node"
 	"github.com/gad-lang/gad/parser/source"
+	"github.com/gad-lang/gad/token"
 	giomnode "github.com/gad-lang/gad/giom/node"
 )
 
@@ -280,6 +282,25 @@ func TestImportDestructureWithMain(t *testing.T) {
 	}
 }
 
+func TestImportSpec(t *testing.T) {
+	file := parseLine(t, "@import \"a.giom\" as comps\n@import { a, b: bb, c = 5, **rest } from \"lib/b.giom\"\n@import \"c.giom\"")
+	expectStmtCount(t, file, 3)
+	want := []giomnode.ImportSpec{
+		{Path: "a.giom", Alias: "comps"},
+		{Path: "lib/b.giom", Alias: "rest", Names: map[string]string{"a": "a", "bb": "b", "c": "c"}},
+		{Path: "c.giom"},
+	}
+	for i, w := range want {
+		cs, ok := file.Stmts[i].(*giomnode.CodeStmt)
+		if !ok || cs.Import == nil {
+			t.Fatalf("stmt %d: expected an import, got %#v", i, file.Stmts[i])
+		}
+		if !reflect.DeepEqual(*cs.Import, w) {
+			t.Fatalf("stmt %d: expected %+v, got %+v", i, w, *cs.Import)
+		}
+	}
+}
+
 func codeStmtStr(t *testing.T, file *giomnode.File, idx int) string {
 	t.Helper()
 	if idx >= len(file.Stmts) {
@@ -295,3 +316,189 @@ func codeStmtStr(t *testing.T, file *giomnode.File, idx int) string {
 	}
 	return strings.Join(parts, "; ")
 }
+
+func TestForLoopFlags(t *testing.T) {
+	file := parseLine(t, "@for row in rows\n"+
+		"    tr\n"+
+		"        @for c in row\n"+
+		"            td {= loop.parent.index}\n"+
+		"@for x in xs\n"+
+		"    | {= x}\n"+
+		"@for y in ys\n"+
+		"    | {= y}\n"+
+		"@else\n"+
+		"    | none\n")
+	expectStmtCount(t, file, 3)
+
+	outer := file.Stmts[0].(*giomnode.ForStmt)
+	inner := outer.Body[0].(*giomnode.TagStmt).Body[0].(*giomnode.ForStmt)
+	if !outer.Loop || outer.OuterLoop {
+		t.Fatalf("outer: Loop=%v OuterLoop=%v", outer.Loop, outer.OuterLoop)
+	}
+	if !inner.Loop || !inner.OuterLoop {
+		t.Fatalf("inner: Loop=%v OuterLoop=%v", inner.Loop, inner.OuterLoop)
+	}
+	if plain := file.Stmts[1].(*giomnode.ForStmt); plain.Loop {
+		t.Fatal("a @for not using loop must not define it")
+	}
+	if withElse := file.Stmts[2].(*giomnode.ForStmt); !withElse.Loop || len(withElse.Else) != 1 {
+		t.Fatalf("@else: Loop=%v len(Else)=%d", withElse.Loop, len(withElse.Else))
+	}
+}
+
+func TestForLoopMentions(t *testing.T) {
+	file := parseLine(t, "@for x in xs\n"+
+		"    // loop\n"+
+		"    p.loop[title=\"loop\"] loop {= x + \"loop\"}\n"+
+		"@for loop in loops\n"+
+		"    | {= loop}\n")
+	expectStmtCount(t, file, 2)
+	for i, stmt := range file.Stmts {
+		if f := stmt.(*giomnode.ForStmt); f.Loop {
+			t.Fatalf("@for %d must not define loop", i)
+		}
+	}
+}
+
+func TestForNamedLoopElse(t *testing.T) {
+	fs := source.NewFileSet()
+	src := "@for loop in loops\n    | {= loop}\n@else\n    | none\n"
+	_, err := NewParser(fs.AddFileData("test.giom", -1, []byte(src))).ParseFile()
+	want := "a @for with @else cannot name its variable loop"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestBranchOutsideLoop(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"top level", "@break\n", "@break outside of a loop"},
+		{"in if", "@if x\n    @continue\n", "@continue outside of a loop"},
+		{"component in loop", "@while x\n    @comp c()\n        @break\n", "@break outside of a loop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := source.NewFileSet()
+			_, err := NewParser(fs.AddFileData("test.giom", -1, []byte(tt.src))).ParseFile()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestWhileBranch(t *testing.T) {
+	file := parseLine(t, "@while i < 3\n    @if i == 1\n        @continue\n    @break\n")
+	expectStmtCount(t, file, 1)
+	w, ok := file.Stmts[0].(*giomnode.WhileStmt)
+	if !ok {
+		t.Fatalf("expected *giomnode.WhileStmt, got %T", file.Stmts[0])
+	}
+	if len(w.Body) != 2 {
+		t.Fatalf("expected 2 body statements, got %d", len(w.Body))
+	}
+	if b, ok := w.Body[1].(*giomnode.BranchStmt); !ok || b.Token != token.Break {
+		t.Fatalf("expected @break, got %#v", w.Body[1])
+	}
+	c := w.Body[0].(*giomnode.IfStmt).Body[0].(*giomnode.BranchStmt)
+	if c.Token != token.Continue {
+		t.Fatalf("expected @continue, got %s", c.Token)
+	}
+}
+
+func TestVerbatimBlock(t *testing.T) {
+	fs, _, file := parseFileWith(t, "div\n"+
+		"    @verbatim raw\n"+
+		"        p {x}\n"+
+		"          // not a comment\n"+
+		"\n"+
+		"    span\n")
+	expectStmtCount(t, file, 1)
+	div := file.Stmts[0].(*giomnode.TagStmt)
+	if len(div.Body) != 2 {
+		t.Fatalf("expected the block and the span, got %d stmts", len(div.Body))
+	}
+	v, ok := div.Body[0].(*giomnode.VerbatimStmt)
+	if !ok {
+		t.Fatalf("expected *giomnode.VerbatimStmt, got %T", div.Body[0])
+	}
+	if want := "p {x}\n  // not a comment"; v.Text != want || !v.Raw {
+		t.Fatalf("got text %q raw=%v, want %q raw", v.Text, v.Raw, want)
+	}
+	if line, col := posLineCol(fs, v.End()); line != 4 || col != 27 {
+		t.Fatalf("block ends at %d:%d, want 4:27", line, col)
+	}
+	if span, ok := div.Body[1].(*giomnode.TagStmt); !ok || span.Name != "span" {
+		t.Fatalf("expected the span after the block, got %#v", div.Body[1])
+	}
+}
+
+func TestEmbedBlock(t *testing.T) {
+	fs, _, file := parseFileWith(t, "div\n"+
+		"    :script\n"+
+		"        let a = {= x};\n"+
+		"        f(\"{y}\", {= {k: 1}})\n"+
+		"    span\n")
+	div := file.Stmts[0].(*giomnode.TagStmt)
+	if len(div.Body) != 2 {
+		t.Fatalf("expected the block and the span, got %d stmts", len(div.Body))
+	}
+	e, ok := div.Body[0].(*giomnode.EmbedStmt)
+	if !ok || e.Name != "script" {
+		t.Fatalf("expected a script *giomnode.EmbedStmt, got %#v", div.Body[0])
+	}
+	if len(e.Parts) != 5 {
+		t.Fatalf("expected literal, x, literal, dict, literal; got %d parts", len(e.Parts))
+	}
+	if lit, ok := e.Parts[2].(*gnode.StrLit); !ok || lit.Value() != ";\nf(\"{y}\", " {
+		t.Fatalf("unexpected literal %#v", e.Parts[2])
+	}
+	if line, col := posLineCol(fs, e.Parts[1].Pos()); line != 3 || col != 20 {
+		t.Fatalf("x at %d:%d, want 3:20", line, col)
+	}
+}
+
+func TestStackDirectives(t *testing.T) {
+	fs, _, file := parseFileWith(t, "head\n"+
+		"    @stack \"scripts\"\n"+
+		"@push \"scripts\"\n"+
+		"    @once\n"+
+		"        script\n"+
+		"    @once name\n")
+	expectStmtCount(t, file, 2)
+	head := file.Stmts[0].(*giomnode.TagStmt)
+	st, ok := head.Body[0].(*giomnode.StackStmt)
+	if !ok {
+		t.Fatalf("expected *giomnode.StackStmt, got %T", head.Body[0])
+	}
+	if lit, ok := st.Name.(*gnode.StrLit); !ok || lit.Value() != "scripts" {
+		t.Fatalf("unexpected stack name %#v", st.Name)
+	}
+	if line, col := posLineCol(fs, st.Name.Pos()); line != 2 || col != 12 {
+		t.Fatalf("name at %d:%d, want 2:12", line, col)
+	}
+	push, ok := file.Stmts[1].(*giomnode.PushStmt)
+	if !ok || len(push.Body) != 2 {
+		t.Fatalf("expected a *giomnode.PushStmt with two blocks, got %#v", file.Stmts[1])
+	}
+	once := push.Body[0].(*giomnode.OnceStmt)
+	if lit, ok := once.Key.(*gnode.StrLit); !ok || lit.Value() != "test.giom:46" {
+		t.Fatalf("unexpected generated key %#v", once.Key)
+	}
+	if len(once.Body) != 1 {
+		t.Fatalf("expected the script in the @once body, got %d stmts", len(once.Body))
+	}
+	if id, ok := push.Body[1].(*giomnode.OnceStmt).Key.(*gnode.IdentExpr); !ok || id.Name != "name" {
+		t.Fatalf("unexpected key %#v", push.Body[1].(*giomnode.OnceStmt).Key)
+	}
+
+	fset := source.NewFileSet()
+	_, err := NewParser(fset.AddFileData("test.giom", -1, []byte("@push\n    p\n"))).ParseFile()
+	if err == nil || !strings.Contains(err.Error(), "@push requires a stack name") {
+		t.Fatalf("expected a missing name error, got %v", err)
+	}
+}
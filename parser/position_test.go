@@ -117,3 +117,59 @@ func TestMultiLineCodePositionMapsToSourceLine(t *testing.T) {
 		t.Fatalf("second statement resolved to %d:%d, want 4:5", line, col)
 	}
 }
+
+// TestInlineNestPositions verifies each tag of a `parent: child` chain keeps
+// its own column and the innermost tag takes the text and the indented block.
+func TestInlineNestPositions(t *testing.T) {
+	src := "ul.menu: li: a[href=\"/\"] Home\n" +
+		"    span x\n"
+	fs, _, file := parseFileWith(t, src)
+
+	ul := file.Stmts[0].(*giomnode.TagStmt)
+	li := ul.Body[0].(*giomnode.TagStmt)
+	a := li.Body[0].(*giomnode.TagStmt)
+	for _, tc := range []struct {
+		tag    *giomnode.TagStmt
+		name   string
+		col    int
+		inline bool
+	}{
+		{ul, "ul", 1, false},
+		{li, "li", 10, true},
+		{a, "a", 14, true},
+	} {
+		if tc.tag.Name != tc.name || tc.tag.Inline != tc.inline || len(tc.tag.Body) == 0 {
+			t.Fatalf("%s: name=%q inline=%v body=%d", tc.name, tc.tag.Name, tc.tag.Inline, len(tc.tag.Body))
+		}
+		if line, col := posLineCol(fs, tc.tag.Pos()); line != 1 || col != tc.col {
+			t.Fatalf("%s at %d:%d, want 1:%d", tc.name, line, col, tc.col)
+		}
+	}
+	if len(a.Body) != 2 {
+		t.Fatalf("expected the text and the block in the innermost tag, got %d stmts", len(a.Body))
+	}
+	if ns := file.Stmts[0].(*giomnode.TagStmt); ns.End() != a.End() {
+		t.Fatalf("chain end %d, want %d", ns.End(), a.End())
+	}
+}
+
+// TestInlineTagSpanPositions verifies a `#[tag …]` span inside text parses to a
+// nested TagStmt positioned in the enclosing file.
+func TestInlineTagSpanPositions(t *testing.T) {
+	fs, _, file := parseFileWith(t, "p Read #[a[href=url] docs] now\n")
+
+	text := file.Stmts[0].(*giomnode.TagStmt).Body[0].(*giomnode.TextStmt)
+	if len(text.Stmts) != 3 {
+		t.Fatalf("expected text, tag, text; got %d stmts", len(text.Stmts))
+	}
+	a, ok := text.Stmts[1].(*giomnode.TagStmt)
+	if !ok || a.Name != "a" {
+		t.Fatalf("expected the a tag, got %#v", text.Stmts[1])
+	}
+	if line, col := posLineCol(fs, a.Pos()); line != 1 || col != 10 {
+		t.Fatalf("tag at %d:%d, want 1:10", line, col)
+	}
+	if line, col := posLineCol(fs, a.Attributes[0].Value.Pos()); line != 1 || col != 17 {
+		t.Fatalf("attribute value at %d:%d, want 1:17", line, col)
+	}
+}
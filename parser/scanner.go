@@ -38,6 +38,8 @@ type scanner struct {
 	lastTokenSize int
 
 	readRaw        bool
+	verbatim       bool // reading a verbatim block: a trailing `\` does not join lines
+	tagHead        bool // the last token was part of a tag head (name, id, class, attributes)
 	mode           gadparser.ScanMode
 	mixedDelimiter gadparser.MixedDelimiter
 	errorHandler   []source.ScannerErrorHandler
@@ -107,12 +109,28 @@ func (s *scanner) Scan() (t gadparser.PToken) {
 
 	case giomtoken.ScnNewLine:
 		s.state = giomtoken.ScnLine
+		s.tagHead = false
 		if tok := s.scanIndent(); tok.Valid() {
 			return tok
 		}
 		return s.Scan()
 
 	case giomtoken.ScnLine:
+		if s.tagHead {
+			if tok := s.scanAttrSpread(); tok.Valid() {
+				return tok
+			}
+			if tok := s.scanTagSpace(); tok.Valid() {
+				return tok
+			}
+			s.tagHead = false
+			if tok := s.scanInlineNest(); tok.Valid() {
+				return tok
+			}
+		}
+		if tok := s.scanWhitespace(); tok.Valid() {
+			return tok
+		}
 		if tok := s.scanExport(); tok.Valid() {
 			return tok
 		}
@@ -152,6 +170,21 @@ func (s *scanner) Scan() (t gadparser.PToken) {
 		if tok := s.scanFor(); tok.Valid() {
 			return tok
 		}
+		if tok := s.scanEmbed(); tok.Valid() {
+			return tok
+		}
+		if tok := s.scanVerbatim(); tok.Valid() {
+			return tok
+		}
+		if tok := s.scanWhile(); tok.Valid() {
+			return tok
+		}
+		if tok := s.scanBranch(); tok.Valid() {
+			return tok
+		}
+		if tok := s.scanStack(); tok.Valid() {
+			return tok
+		}
 		if tok := s.scanImportModule(); tok.Valid() {
 			return tok
 		}
@@ -346,6 +379,94 @@ func (s *scanner) scanFor() gadparser.PToken {
 	return gadparser.PToken{}
 }
 
+var rgxWhile = regexp.MustCompile(`^@while\s+(.+)$`)
+
+func (s *scanner) scanWhile() gadparser.PToken {
+	if sm := rgxWhile.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[0]))
+		return s.newToken(giomtoken.While, sm[0], strings.TrimSpace(sm[1]))
+	}
+	return gadparser.PToken{}
+}
+
+var rgxVerbatim = regexp.MustCompile(`^@verbatim(?:\s+(raw))?\s*$`)
+
+// scanVerbatim scans a `@verbatim` (or `@verbatim raw`) line together with the
+// literal block indented under it (see NextVerbatim).
+func (s *scanner) scanVerbatim() gadparser.PToken {
+	if sm := rgxVerbatim.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[0]))
+		pt := s.newToken(giomtoken.Verbatim, sm[0], "")
+		pt.Set("mode", sm[1])
+		lines, _, end := s.NextVerbatim()
+		pt.Set("text", strings.Join(lines, "\n"))
+		pt.Set("end", end)
+		return pt
+	}
+	return gadparser.PToken{}
+}
+
+var rgxEmbed = regexp.MustCompile(`^:(style|css|script|js)(?:\s+(.*))?$`)
+
+// embedTags maps an embed directive to the tag it builds.
+var embedTags = map[string]string{"style": "style", "css": "style", "script": "script", "js": "script"}
+
+// scanEmbed scans a `:style` / `:script` embed (`:css` and `:js` are aliases):
+// its body is the rest of the line or the literal block indented under it
+// (see NextVerbatim). The "lines" and "linePos" data hold the body lines and
+// their absolute positions.
+func (s *scanner) scanEmbed() gadparser.PToken {
+	if sm := rgxEmbed.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		rest := strings.TrimRight(sm[2], " \t")
+		restPos := len(sm[0]) - len(sm[2])
+		s.consume(len(sm[0]))
+		pt := s.newToken(giomtoken.Embed, sm[0], embedTags[sm[1]])
+		var (
+			lines     []string
+			positions []source.Pos
+			end       = pt.Pos + source.Pos(len(sm[0]))
+		)
+		if rest != "" {
+			lines, positions = []string{rest}, []source.Pos{pt.Pos + source.Pos(restPos)}
+		} else {
+			lines, positions, end = s.NextVerbatim()
+		}
+		pt.Set("lines", lines)
+		pt.Set("linePos", positions)
+		pt.Set("end", end)
+		return pt
+	}
+	return gadparser.PToken{}
+}
+
+var rgxBranch = regexp.MustCompile(`^@(break|continue)\s*$`)
+
+func (s *scanner) scanBranch() gadparser.PToken {
+	if sm := rgxBranch.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[0]))
+		return s.newToken(giomtoken.Branch, sm[0], sm[1])
+	}
+	return gadparser.PToken{}
+}
+
+var rgxStack = regexp.MustCompile(`^@(push|stack|once)(?:\s+(.+))?$`)
+
+// scanStack scans `@push name`, `@stack name` and `@once [key]`. The value is
+// the directive; "expr" holds its argument and "exprOffset" the argument's
+// offset in the token.
+func (s *scanner) scanStack() gadparser.PToken {
+	if sm := rgxStack.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[0]))
+		pt := s.newToken(giomtoken.Stack, sm[0], sm[1])
+		if expr := strings.TrimSpace(sm[2]); expr != "" {
+			pt.Set("expr", expr)
+			pt.Set("exprOffset", len(sm[0])-len(sm[2]))
+		}
+		return pt
+	}
+	return gadparser.PToken{}
+}
+
 var rgxAssignment = regexp.MustCompile(`^(\$[\w0-9\-_]*)?\s*([+-/*:]?)=\s*(.+)$`)
 
 func (s *scanner) scanAssignment() gadparser.PToken {
@@ -409,6 +530,7 @@ var rgxId = regexp.MustCompile(`^#([\w-]+)(?:\s*\?\s*(.*)$)?`)
 func (s *scanner) scanId() gadparser.PToken {
 	if sm := rgxId.FindStringSubmatch(s.buffer); len(sm) != 0 {
 		s.consume(len(sm[0]))
+		s.tagHead = true
 		pt := s.newToken(giomtoken.Id, sm[0], sm[1])
 		pt.Set("condition", sm[2])
 		return pt
@@ -416,11 +538,34 @@ func (s *scanner) scanId() gadparser.PToken {
 	return gadparser.PToken{}
 }
 
-var rgxClassName = regexp.MustCompile(`^\.([\w-]+)(?:\s*\?\s*(.*)$)?`)
+var (
+	rgxClassName       = regexp.MustCompile(`^\.([\w-]+)(?:\s*\?\s*(.*)$)?`)
+	rgxClassNameToggle = regexp.MustCompile(`^\.([\w-]+)\?\(`)
+)
 
+// scanClassName scans a `.name` class shorthand. Besides the line-ending
+// `.name ? cond` form, a class may be toggled in place with `.name?(cond)` and
+// its name may be interpolated with `.{expr}`:
+//
+//	a.nav-link.active?(item.URL == current) Home
+//	div.card.{variant}.{size}?(size != "")
+//
+// An interpolated class sets the "expr" data; a toggle sets "condition" and
+// "condOffset", the offset of the condition in the token.
 func (s *scanner) scanClassName() gadparser.PToken {
+	if strings.HasPrefix(s.buffer, ".{") {
+		group, end, ok := s.readBalanced(1, '{', '}')
+		if !ok {
+			return gadparser.PToken{}
+		}
+		return s.classNameToken("", group[1:len(group)-1], end)
+	}
+	if sm := rgxClassNameToggle.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		return s.classNameToken(sm[1], "", len(sm[1])+1)
+	}
 	if sm := rgxClassName.FindStringSubmatch(s.buffer); len(sm) != 0 {
 		s.consume(len(sm[0]))
+		s.tagHead = true
 		pt := s.newToken(giomtoken.ClassName, sm[0], sm[1])
 		pt.Set("condition", sm[2])
 		return pt
@@ -428,6 +573,30 @@ func (s *scanner) scanClassName() gadparser.PToken {
 	return gadparser.PToken{}
 }
 
+// classNameToken emits the ClassName token of a class name (or interpolated
+// expr) ending at end, taking an optional `?(cond)` toggle right after it.
+func (s *scanner) classNameToken(name, expr string, end int) gadparser.PToken {
+	var cond string
+	condOffset := 0
+	if strings.HasPrefix(s.buffer[end:], "?(") {
+		if group, e, ok := s.readBalanced(end+1, '(', ')'); ok {
+			cond, condOffset, end = group[1:len(group)-1], end+2, e
+		}
+	}
+	lit := s.buffer[:end]
+	s.consume(end)
+	s.tagHead = true
+	pt := s.newToken(giomtoken.ClassName, lit, name)
+	pt.Set("condition", cond)
+	if expr != "" {
+		pt.Set("expr", expr)
+	}
+	if condOffset > 0 {
+		pt.Set("condOffset", condOffset)
+	}
+	return pt
+}
+
 // scanAttribute scans an attribute group `[ … ]`. A group may hold one or many
 // attributes separated by commas or newlines, like a GAD KeyValueArray
 // `(; … )`, and may span multiple physical lines up to the closing `]`:
@@ -470,6 +639,7 @@ func (s *scanner) scanAttribute() gadparser.PToken {
 	innerPos := source.Pos(s.file.Base+s.offset-len(s.buffer)-1) + 1
 	lit := s.buffer[:consumed]
 	s.consume(consumed)
+	s.tagHead = true
 	pt := s.newToken(giomtoken.Attribute, lit, "")
 	pt.Set("inner", inner)
 	pt.Set("innerPos", innerPos)
@@ -477,6 +647,27 @@ func (s *scanner) scanAttribute() gadparser.PToken {
 	return pt
 }
 
+// attrSpreadPrefix opens an attribute spread in a tag head.
+const attrSpreadPrefix = "&attributes("
+
+// scanAttrSpread scans an attribute spread `&attributes(expr)` following a tag
+// head (`div.btn&attributes(attrs)`). It is emitted as an Attribute token with
+// the "spread" data holding expr, so the head may continue after it.
+func (s *scanner) scanAttrSpread() gadparser.PToken {
+	if !strings.HasPrefix(s.buffer, attrSpreadPrefix) {
+		return gadparser.PToken{}
+	}
+	group, end, ok := s.readBalanced(len(attrSpreadPrefix)-1, '(', ')')
+	if !ok {
+		return gadparser.PToken{}
+	}
+	lit := s.buffer[:end]
+	s.consume(end)
+	pt := s.newToken(giomtoken.Attribute, lit, "")
+	pt.Set("spread", group[1:len(group)-1])
+	return pt
+}
+
 // ensureBracketClosed appends subsequent physical lines to the buffer until the
 // bracket group starting at s.buffer[0] is balanced-closed, or input ends.
 func (s *scanner) ensureBracketClosed() { s.ensureBalanced(0, '[', ']') }
@@ -737,16 +928,56 @@ func (s *scanner) ensureHtmlComplete(start int) {
 	}
 }
 
-var rgxTag = regexp.MustCompile(`^(\w[-:/\w]*)`)
+// rgxTag matches a tag name. A name may contain `:` (`svg:rect`) but not end
+// with it, leaving `li: a` to scanInlineNest.
+var rgxTag = regexp.MustCompile(`^(\w(?:[-:/\w]*[-/\w])?)`)
 
 func (s *scanner) scanTag() gadparser.PToken {
 	if sm := rgxTag.FindStringSubmatch(s.buffer); len(sm) != 0 {
 		s.consume(len(sm[0]))
+		s.tagHead = true
 		return s.newToken(giomtoken.Tag, sm[0], sm[1])
 	}
 	return gadparser.PToken{}
 }
 
+var rgxTagSpace = regexp.MustCompile(`^(<>|><|<|>)(?:\s|:|$)`)
+
+// scanTagSpace scans the whitespace modifier ending a tag head: `>` (space
+// after the tag), `<` (space before) or `<>` (both); under
+// `@whitespace preserve` they trim instead. The head continues, so an inline
+// child may follow (`li>: a`).
+func (s *scanner) scanTagSpace() gadparser.PToken {
+	if sm := rgxTagSpace.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[1]))
+		return s.newToken(giomtoken.TagSpace, sm[1], sm[1])
+	}
+	return gadparser.PToken{}
+}
+
+var rgxWhitespace = regexp.MustCompile(`^@whitespace\s+(preserve|collapse)\s*$`)
+
+// scanWhitespace scans the `@whitespace preserve|collapse` pragma.
+func (s *scanner) scanWhitespace() gadparser.PToken {
+	if sm := rgxWhitespace.FindStringSubmatch(s.buffer); len(sm) != 0 {
+		s.consume(len(sm[0]))
+		return s.newToken(giomtoken.Whitespace, sm[0], sm[1])
+	}
+	return gadparser.PToken{}
+}
+
+var rgxInlineNest = regexp.MustCompile(`^:\s+`)
+
+// scanInlineNest scans the `: ` separating a tag head from a child tag written
+// on the same line (`li: a[href=url] Home`).
+func (s *scanner) scanInlineNest() gadparser.PToken {
+	if m := rgxInlineNest.FindString(s.buffer); m != "" {
+		s.consume(len(m))
+		return s.newToken(giomtoken.InlineNest, m, "")
+	}
+	return gadparser.PToken{}
+}
+
 var rgxExport = regexp.MustCompile(`^@export\s+([a-zA-Z_]\w*)(\s*=\s*(.+))?$`)
 
 func (s *scanner) scanExport() gadparser.PToken {
@@ -1071,17 +1302,21 @@ func (s *scanner) scanCompCall() gadparser.PToken {
 	return pt
 }
 
-var rgxText = regexp.MustCompile(`^(\|)? ?(.*)$`)
+// rgxText matches a text line; `|+` marks piped text with a leading space.
+var rgxText = regexp.MustCompile(`^(\|\+?)? ?(.*)$`)
 
 func (s *scanner) scanText() gadparser.PToken {
 	if sm := rgxText.FindStringSubmatch(s.buffer); len(sm) != 0 {
 		s.consume(len(sm[0]))
 		mode := "inline"
-		if sm[1] == "|" {
+		if sm[1] != "" {
 			mode = "piped"
 		}
 		pt := s.newToken(giomtoken.Text, sm[0], sm[2])
 		pt.Set("mode", mode)
+		if sm[1] == "|+" {
+			pt.Set("space", "true")
+		}
 		// Absolute position of the text content (sm[2], a suffix of sm[0]) so
 		// embedded {= expr } interpolations map back to the original source.
 		pt.Set("valuePos", []source.Pos{pt.Pos + source.Pos(len(sm[0])-len(sm[2]))})
@@ -1144,6 +1379,66 @@ func (s *scanner) NextRaw() gadparser.PToken {
 	}
 }
 
+// NextVerbatim collects the block indented under the current line (a
+// `@verbatim` or embed directive): every following line that is blank or
+// indented deeper than the current indentation. The block's own indentation
+// (that of its first line) is stripped, deeper indentation is kept and
+// trailing blank lines are dropped. No giom scanning happens inside the block,
+// so it may hold any text, and a trailing `\` does not continue a line. Each
+// line comes with the absolute position of its first byte after the stripped
+// indentation; end is the end of the last line.
+func (s *scanner) NextVerbatim() (lines []string, positions []source.Pos, end source.Pos) {
+	var (
+		outer  = s.Indentation()
+		indent string
+	)
+	end = s.lastTokenPos + source.Pos(s.lastTokenSize)
+	s.verbatim = true
+	defer func() {
+		s.verbatim = false
+		// The line after the block was read as verbatim; it is giom again.
+		for lq := lineQuote(s.buffer); lq >= 0; lq = lineQuote(s.buffer) {
+			buf, err := s.joinNext(s.buffer, lq)
+			if err != nil && err != io.EOF {
+				panic(err)
+			}
+			s.buffer = strings.TrimSuffix(buf, "\n")
+		}
+	}()
+	for {
+		s.ensureBuffer()
+		if s.state == giomtoken.ScnEOF {
+			break
+		}
+		line := s.buffer
+		if strings.TrimSpace(line) == "" {
+			s.consume(len(line))
+			lines = append(lines, "")
+			positions = append(positions, s.lastTokenPos)
+			continue
+		}
+		if indent == "" {
+			lead := rgxIndent.FindString(line)
+			if len(lead) <= len(outer) || !strings.HasPrefix(lead, outer) {
+				break
+			}
+			indent = lead
+		}
+		if !strings.HasPrefix(line, indent) {
+			break
+		}
+		s.consume(len(line))
+		lines = append(lines, line[len(indent):])
+		positions = append(positions, s.lastTokenPos+source.Pos(len(indent)))
+		end = s.lastTokenPos + source.Pos(len(line))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		positions = positions[:len(positions)-1]
+	}
+	return
+}
+
 // NextRawCode collects the raw lines of a multi-line code block up to the eof
 // marker. Lines are returned verbatim (indentation preserved) alongside the
 // absolute base position of each line, so the parser can map the parsed
@@ -1205,13 +1500,8 @@ process:
 			buf = buf[:len(buf)-1]
 		}
 
-		if lq := lineQuote(buf); lq >= 0 {
-			var tmp string
-			if tmp, err = s.reader.ReadString('\n'); err == nil || err == io.EOF {
-				s.line++
-				buf = buf[0:lq] + trimLeftSpace(tmp)
-			}
-			s.offset += len(buf)
+		if lq := lineQuote(buf); lq >= 0 && !s.verbatim {
+			buf, err = s.joinNext(buf, lq)
 			goto process
 		}
 
@@ -1222,6 +1512,18 @@ process:
 	}
 }
 
+// joinNext joins the line buf, continued by the `\` at lq, with the next line
+// of input.
+func (s *scanner) joinNext(buf string, lq int) (string, error) {
+	tmp, err := s.reader.ReadString('\n')
+	if err == nil || err == io.EOF {
+		s.line++
+		buf = buf[0:lq] + trimLeftSpace(tmp)
+	}
+	s.offset += len(buf)
+	return buf, err
+}
+
 func trimLeftSpace(s string) string {
 	start := 0
 	for ; start < len(s); start++ {
@@ -0,0 +1,399 @@
+package giom
+
+import (
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gad-lang/gad"
+)
+
+// RenderText writes root as plain text, e.g. the text/plain alternative of an
+// HTML email built with giom. Block elements become line breaks (paragraph-like
+// ones a blank line), links render as `text (url)`, list items get bullets
+// (`- ` or `1. `), tables are written as aligned columns and script, style,
+// head and template content is dropped. Whitespace collapses as in HTML except
+// inside pre. Raw HTML (RawStr) values are reduced to their text. The tree is
+// written as it is: call ResolveStacks first on a tree built by running a
+// template directly.
+func RenderText(vm *gad.VM, root Element, w io.Writer) (int64, error) {
+	tw := &textWriter{vm: vm}
+	tw.element(root)
+	n, err := io.WriteString(w, tw.String())
+	return int64(n), err
+}
+
+// textSkip holds the elements whose content is not part of the text.
+var textSkip = map[string]bool{
+	"script": true, "style": true, "head": true, "template": true,
+}
+
+// textParagraphs holds the block elements separated by a blank line.
+var textParagraphs = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "ul": true, "ol": true, "dl": true, "table": true,
+	"blockquote": true, "pre": true, "figure": true,
+}
+
+// textBlocks holds the block elements ending the current line.
+var textBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "body": true, "dd": true,
+	"details": true, "div": true, "dt": true, "fieldset": true,
+	"figcaption": true, "footer": true, "form": true, "header": true,
+	"html": true, "li": true, "main": true, "nav": true, "section": true,
+	"summary": true, "tr": true, "caption": true,
+}
+
+// textList is the state of an open ul/ol.
+type textList struct {
+	ordered bool
+	n       int
+}
+
+// textWriter accumulates the plain text of a render tree.
+type textWriter struct {
+	vm      *gad.VM
+	b       strings.Builder
+	space   bool   // a collapsed space is pending before the next word
+	atStart bool   // the next write starts a line (and gets the indent)
+	breaks  int    // trailing newlines written since the last content
+	pre     int    // depth of enclosing pre elements
+	indent  string // prefix of lines inside list items
+	lists   []textList
+	rawSkip string // raw HTML element whose content is being dropped
+}
+
+// String returns the text, without leading or trailing blank lines.
+func (tw *textWriter) String() string {
+	s := strings.TrimRight(tw.b.String(), "\n")
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
+
+func (tw *textWriter) element(el Element) {
+	switch e := el.(type) {
+	case Text:
+		for _, v := range e {
+			tw.value(v)
+		}
+	case *Tag:
+		tw.tag(e)
+	}
+}
+
+func (tw *textWriter) children(t *Tag) {
+	for _, c := range t.Children {
+		tw.element(c)
+	}
+}
+
+// value writes a text value; a RawStr is reduced to its text.
+func (tw *textWriter) value(v gad.Object) {
+	switch t := v.(type) {
+	case gad.RawStr:
+		tw.rawHTML(string(t))
+	case gad.Str:
+		tw.text(string(t))
+	default:
+		tw.text(xmlString(tw.vm, v))
+	}
+}
+
+func (tw *textWriter) tag(t *Tag) {
+	name := strings.ToLower(t.Name)
+	switch {
+	case name == "":
+		tw.children(t)
+	case textSkip[name]:
+	case name == "br":
+		tw.newline()
+	case name == "hr":
+		tw.block(2)
+		tw.write("----")
+		tw.block(2)
+	case name == "img":
+		tw.text(attrString(t, "alt"))
+	case name == "a":
+		tw.link(t)
+	case name == "ul" || name == "ol":
+		tw.list(t, name == "ol")
+	case name == "li":
+		tw.item(t)
+	case name == "table":
+		tw.table(t)
+	case name == "pre":
+		tw.block(2)
+		tw.pre++
+		tw.children(t)
+		tw.pre--
+		tw.block(2)
+	case textParagraphs[name]:
+		tw.block(2)
+		tw.children(t)
+		tw.block(2)
+	case textBlocks[name]:
+		tw.block(1)
+		tw.children(t)
+		tw.block(1)
+	default:
+		tw.children(t)
+	}
+}
+
+// link writes `text (url)`, or just the url for an empty link. The url is
+// omitted when it repeats the text or is a fragment/script link.
+func (tw *textWriter) link(t *Tag) {
+	href := attrString(t, "href")
+	start := tw.b.Len()
+	tw.children(t)
+	text := strings.TrimSpace(tw.b.String()[start:])
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return
+	}
+	if text == "" {
+		tw.text(href)
+		return
+	}
+	if text == href || text == strings.TrimPrefix(href, "mailto:") {
+		return
+	}
+	tw.space = true
+	tw.text("(" + href + ")")
+}
+
+func (tw *textWriter) list(t *Tag, ordered bool) {
+	if len(tw.lists) > 0 {
+		tw.block(1)
+	} else {
+		tw.block(2)
+	}
+	tw.lists = append(tw.lists, textList{ordered: ordered})
+	tw.children(t)
+	tw.lists = tw.lists[:len(tw.lists)-1]
+	if len(tw.lists) > 0 {
+		tw.block(1)
+	} else {
+		tw.block(2)
+	}
+}
+
+// item writes a list item behind its bullet, indenting continuation lines and
+// nested lists under the item text.
+func (tw *textWriter) item(t *Tag) {
+	tw.block(1)
+	bullet := "- "
+	if len(tw.lists) > 0 {
+		if l := &tw.lists[len(tw.lists)-1]; l.ordered {
+			l.n++
+			bullet = strconv.Itoa(l.n) + ". "
+		}
+	}
+	tw.write(bullet)
+	tw.space = false
+	indent := tw.indent
+	tw.indent += strings.Repeat(" ", len(bullet))
+	tw.children(t)
+	tw.indent = indent
+	tw.block(1)
+}
+
+// table writes the rows of t as columns padded to the widest cell, with a rule
+// under a leading header row.
+func (tw *textWriter) table(t *Tag) {
+	var (
+		rows   [][]string
+		header []bool
+	)
+	var collect func(t *Tag)
+	collect = func(t *Tag) {
+		for _, c := range t.Children {
+			ct, ok := c.(*Tag)
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(ct.Name) {
+			case "tr":
+				var (
+					row  []string
+					head = true
+				)
+				for _, cc := range ct.Children {
+					cell, ok := cc.(*Tag)
+					if !ok {
+						continue
+					}
+					switch strings.ToLower(cell.Name) {
+					case "th":
+					case "td":
+						head = false
+					default:
+						continue
+					}
+					sub := &textWriter{vm: tw.vm}
+					sub.children(cell)
+					row = append(row, strings.Join(strings.Fields(sub.String()), " "))
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+					header = append(header, head)
+				}
+			case "caption", "colgroup":
+			default:
+				collect(ct)
+			}
+		}
+	}
+	collect(t)
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	tw.block(2)
+	for i, row := range rows {
+		var line strings.Builder
+		for j, cell := range row {
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			if j < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+		}
+		tw.write(line.String())
+		tw.block(1)
+		if i == 0 && header[i] && len(rows) > 1 {
+			var rule []string
+			for _, w := range widths {
+				rule = append(rule, strings.Repeat("-", w))
+			}
+			tw.write(strings.Join(rule, "  "))
+			tw.block(1)
+		}
+	}
+	tw.block(2)
+}
+
+// text writes s, collapsing whitespace outside pre.
+func (tw *textWriter) text(s string) {
+	if s == "" || tw.rawSkip != "" {
+		return
+	}
+	if tw.pre > 0 {
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				tw.newline()
+			}
+			if line != "" {
+				tw.write(line)
+			}
+		}
+		return
+	}
+	if isTextSpace(s[0]) {
+		tw.space = true
+	}
+	for i, f := range strings.Fields(s) {
+		if i > 0 {
+			tw.space = true
+		}
+		if tw.space && !tw.atStart && tw.b.Len() > 0 {
+			tw.b.WriteByte(' ')
+		}
+		tw.space = false
+		tw.write(f)
+	}
+	if isTextSpace(s[len(s)-1]) {
+		tw.space = true
+	}
+}
+
+func isTextSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// write appends s to the current line, starting lines with the indent.
+func (tw *textWriter) write(s string) {
+	if tw.atStart || tw.b.Len() == 0 {
+		tw.b.WriteString(tw.indent)
+		tw.atStart = false
+	}
+	tw.b.WriteString(s)
+	tw.breaks = 0
+}
+
+func (tw *textWriter) newline() {
+	tw.b.WriteByte('\n')
+	tw.breaks++
+	tw.atStart = true
+	tw.space = false
+}
+
+// block ends the current line and, for n > 1, leaves a blank line; breaks do
+// not stack and never lead the output.
+func (tw *textWriter) block(n int) {
+	tw.space = false
+	if tw.b.Len() == 0 {
+		return
+	}
+	for tw.breaks < n {
+		tw.newline()
+	}
+}
+
+// rgxRawTag matches a tag (or comment/doctype) in raw HTML.
+var rgxRawTag = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([a-zA-Z][\w:-]*)?[^>]*>`)
+
+// rawHTML writes the text of a raw HTML fragment, honouring the block, line
+// break and skipped elements it contains.
+func (tw *textWriter) rawHTML(s string) {
+	last := 0
+	for _, m := range rgxRawTag.FindAllStringSubmatchIndex(s, -1) {
+		tw.text(html.UnescapeString(s[last:m[0]]))
+		last = m[1]
+		if m[4] < 0 {
+			continue
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+		switch {
+		case tw.rawSkip != "":
+			if closing && name == tw.rawSkip {
+				tw.rawSkip = ""
+			}
+		case textSkip[name]:
+			if !closing {
+				tw.rawSkip = name
+			}
+		case name == "br":
+			tw.newline()
+		case textParagraphs[name]:
+			tw.block(2)
+		case textBlocks[name]:
+			tw.block(1)
+		}
+	}
+	tw.text(html.UnescapeString(s[last:]))
+}
+
+// attrString returns the text of a regular attribute, or "" if unset.
+func attrString(t *Tag, name string) string {
+	if v, ok := t.Attrs[name]; ok && !v.IsFalsy() {
+		return v.ToString()
+	}
+	return ""
+}
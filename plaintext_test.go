@@ -0,0 +1,90 @@
+package giom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gad-lang/gad"
+)
+
+func renderText(t *testing.T, el Element) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := RenderText(newElementVM(), el, &buf); err != nil {
+		t.Fatalf("RenderText: %v", err)
+	}
+	return buf.String()
+}
+
+func TestRenderText(t *testing.T) {
+	el := func(name string, children ...Element) *Tag { return NewTag(nil, name, children, nil) }
+	txt := func(s string) Text { return Text{gad.Str(s)} }
+	cells := func(name string, values ...string) *Tag {
+		tr := el("tr")
+		for _, v := range values {
+			tr.Children = append(tr.Children, el(name, txt(v)))
+		}
+		return tr
+	}
+	link := NewTag(nil, "a", []Element{txt("docs")}, gad.KeyValueArray{{K: gad.Str("href"), V: gad.Str("/docs")}})
+
+	root := el("html",
+		el("head", el("title", txt("Title")), el("style", txt("p{}"))),
+		el("body",
+			el("h1", txt("Hello")),
+			el("p", txt("Read   the "), link, txt(".")),
+			el("ul",
+				el("li", txt("one")),
+				el("li", txt("two"), el("ul", el("li", txt("nested"))))),
+			el("ol", el("li", txt("a")), el("li", txt("b"))),
+			el("table",
+				cells("th", "Name", "Qty"),
+				el("tbody", cells("td", "Apple", "3"), cells("td", "Kiwi", "12"))),
+			el("script", txt("track()")),
+			el("p", txt("a"), el("br"), txt("b")),
+		),
+	)
+
+	want := "Hello\n\n" +
+		"Read the docs (/docs).\n\n" +
+		"- one\n- two\n  - nested\n\n" +
+		"1. a\n2. b\n\n" +
+		"Name   Qty\n-----  ---\nApple  3\nKiwi   12\n\n" +
+		"a\nb\n"
+	if got := renderText(t, root); got != want {
+		t.Fatalf("\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestRenderTextRawHTML(t *testing.T) {
+	root := NewTag(nil, "", []Element{
+		Text{gad.RawStr("<p>Hi &amp; bye</p><script>x()</script><p>Next</p>")},
+	}, nil)
+	if got, want := renderText(t, root), "Hi & bye\n\nNext\n"; got != want {
+		t.Fatalf("\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestRenderTextLinks(t *testing.T) {
+	a := func(href, text string) *Tag {
+		return NewTag(nil, "a", []Element{Text{gad.Str(text)}}, gad.KeyValueArray{{K: gad.Str("href"), V: gad.Str(href)}})
+	}
+	tests := []struct {
+		name string
+		el   *Tag
+		want string
+	}{
+		{"text and url", a("https://x.io", "Site"), "Site (https://x.io)\n"},
+		{"url as text", a("https://x.io", "https://x.io"), "https://x.io\n"},
+		{"mailto", a("mailto:me@x.io", "me@x.io"), "me@x.io\n"},
+		{"fragment", a("#top", "Top"), "Top\n"},
+		{"empty text", a("https://x.io", ""), "https://x.io\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderText(t, tt.el); got != tt.want {
+				t.Fatalf("\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
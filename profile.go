@@ -0,0 +1,446 @@
+package giom
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gad-lang/gad"
+	giomnode "github.com/gad-lang/gad/giom/node"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+)
+
+// Profiler records where rendering spends its time: the templates compiled
+// with it are instrumented so that each component call, @for and @while loop
+// and interpolated text line measures the wall time and heap allocations
+// spent in it. Set Render.Profiler (or use Compiler.WithProfiler) before the
+// first render, render, then write a pprof profile with WriteProfile or
+// folded stacks for flame graphs with WriteFolded. It is safe for concurrent
+// use.
+//
+// Allocations are read from the process-wide runtime metrics, so renders
+// running concurrently with other work see that work's allocations too.
+type Profiler struct {
+	mu      sync.Mutex
+	start   time.Time
+	stacks  map[*gad.VM][]*profileFrame
+	samples map[string]*ProfileSample
+	// sites indexes the instrumented sites by the id their giom.profEnter
+	// and giom.profExit calls pass, files the ids of each template's sites,
+	// and next is the id of the next site.
+	sites map[int]*ProfileSite
+	files map[string][]int
+	next  int
+}
+
+// NewProfiler returns a Profiler with no samples.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		start:   time.Now(),
+		stacks:  map[*gad.VM][]*profileFrame{},
+		samples: map[string]*ProfileSample{},
+		sites:   map[int]*ProfileSite{},
+		files:   map[string][]int{},
+	}
+}
+
+// ProfileSite is an instrumented statement of a template.
+type ProfileSite struct {
+	// Name is "+name" for a component call, "@for", "@while", or "{=}" for a
+	// text line with interpolations.
+	Name         string
+	File         string
+	Line, Column int
+	id           int
+}
+
+// String returns the site as "file:line name".
+func (s *ProfileSite) String() string {
+	return fmt.Sprintf("%s:%d %s", s.File, s.Line, s.Name)
+}
+
+// ProfileSample holds what a call stack of sites spent itself, excluding the
+// sites it called.
+type ProfileSample struct {
+	// Stack is the call stack, outermost site first.
+	Stack []*ProfileSite
+	// Calls counts the runs of the innermost site.
+	Calls        int64
+	Wall         time.Duration
+	AllocBytes   int64
+	AllocObjects int64
+}
+
+// ProfileValue selects the value WriteFolded writes.
+type ProfileValue uint8
+
+const (
+	// ProfileWall is the wall time, in microseconds.
+	ProfileWall ProfileValue = iota
+	// ProfileAllocBytes is the number of bytes the process allocated.
+	ProfileAllocBytes
+	// ProfileAllocObjects is the number of objects the process allocated.
+	ProfileAllocObjects
+	// ProfileCalls is the number of runs.
+	ProfileCalls
+)
+
+// profileFrame is a running site of a render.
+type profileFrame struct {
+	site           *ProfileSite
+	start          time.Time
+	bytes, objects int64
+	// Spent in the sites the frame called.
+	childWall                time.Duration
+	childBytes, childObjects int64
+}
+
+// unboundProfile returns the giom.<name>(id) builtin of the package
+// builtins, which the builtins of Render and Compiler.AppendBuiltins replace
+// with the one of their Profiler.
+func unboundProfile(name string) *gad.Function {
+	return &gad.Function{
+		FuncName: "giom." + name,
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (gad.Object, error) {
+			return nil, fmt.Errorf("giom.%s: no Profiler bound to the builtins (see Compiler.AppendBuiltins)", name)
+		},
+	}
+}
+
+var (
+	// BuiltinProfEnter implements giom.profEnter(id), called by profiling
+	// instrumentation before a site runs. It records nothing unless bound to a
+	// Profiler.
+	BuiltinProfEnter = unboundProfile("profEnter")
+	// BuiltinProfExit implements giom.profExit(id), called by profiling
+	// instrumentation after a site ran. It records nothing unless bound to a
+	// Profiler.
+	BuiltinProfExit = unboundProfile("profExit")
+)
+
+// builtin returns the giom.<name>(id) builtin of p calling f with the VM and
+// site. Sites dropped when their template was instrumented again are not
+// recorded.
+func (p *Profiler) builtin(name string, f func(p *Profiler, vm *gad.VM, site *ProfileSite)) *gad.Function {
+	return &gad.Function{
+		FuncName: "giom." + name,
+		Module:   ModuleSpec,
+		Value: func(call gad.Call) (gad.Object, error) {
+			id, err := counterID(call, "giom."+name, "site")
+			if err != nil {
+				return nil, err
+			}
+			p.mu.Lock()
+			site, next := p.sites[id], p.next
+			p.mu.Unlock()
+			if site == nil {
+				if id < 0 || id >= next {
+					return nil, fmt.Errorf("giom.%s: unknown site %d", name, id)
+				}
+				return gad.Nil, nil
+			}
+			f(p, call.VM, site)
+			return gad.Nil, nil
+		},
+	}
+}
+
+var allocMetrics = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+// allocs returns the bytes and objects allocated by the process so far.
+func allocs() (bytes, objects int64) {
+	samples := []metrics.Sample{{Name: allocMetrics[0]}, {Name: allocMetrics[1]}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		bytes = int64(samples[0].Value.Uint64())
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		objects = int64(samples[1].Value.Uint64())
+	}
+	return
+}
+
+func (p *Profiler) enter(vm *gad.VM, site *ProfileSite) {
+	f := &profileFrame{site: site}
+	f.bytes, f.objects = allocs()
+	f.start = time.Now()
+	p.mu.Lock()
+	p.stacks[vm] = append(p.stacks[vm], f)
+	p.mu.Unlock()
+}
+
+func (p *Profiler) exit(vm *gad.VM, site *ProfileSite) {
+	now := time.Now()
+	bytes, objects := allocs()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stack := p.stacks[vm]
+	// Frames above site did not exit, e.g. after an error; drop them.
+	i := len(stack) - 1
+	for i >= 0 && stack[i].site != site {
+		i--
+	}
+	if i < 0 {
+		return
+	}
+	f := stack[i]
+	stack = stack[:i]
+	if len(stack) == 0 {
+		delete(p.stacks, vm)
+	} else {
+		p.stacks[vm] = stack
+	}
+
+	wall, allocBytes, allocObjects := now.Sub(f.start), bytes-f.bytes, objects-f.objects
+	var key strings.Builder
+	sites := make([]*ProfileSite, 0, len(stack)+1)
+	for _, g := range stack {
+		sites = append(sites, g.site)
+		key.WriteString(strconv.Itoa(g.site.id) + ";")
+	}
+	sites = append(sites, site)
+	key.WriteString(strconv.Itoa(site.id))
+	s := p.samples[key.String()]
+	if s == nil {
+		s = &ProfileSample{Stack: sites}
+		p.samples[key.String()] = s
+	}
+	s.Calls++
+	s.Wall += wall - f.childWall
+	s.AllocBytes += allocBytes - f.childBytes
+	s.AllocObjects += allocObjects - f.childObjects
+	if len(stack) > 0 {
+		parent := stack[len(stack)-1]
+		parent.childWall += wall
+		parent.childBytes += allocBytes
+		parent.childObjects += allocObjects
+	}
+}
+
+// Samples returns a copy of the samples recorded so far, sorted by stack.
+func (p *Profiler) Samples() []ProfileSample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProfileSample, 0, len(p.samples))
+	for _, s := range p.samples {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return folded(out[i].Stack) < folded(out[j].Stack) })
+	return out
+}
+
+// folded returns a stack as "site;site;…", outermost first.
+func folded(stack []*ProfileSite) string {
+	names := make([]string, len(stack))
+	for i, s := range stack {
+		names[i] = s.String()
+	}
+	return strings.Join(names, ";")
+}
+
+// WriteFolded writes the samples as folded stacks, one "site;site;… value"
+// line per call stack, the input of flamegraph.pl, speedscope and inferno:
+//
+//	page.giom:3 +layout;layout.giom:8 @for;layout.giom:9 +card 1520
+func (p *Profiler) WriteFolded(w io.Writer, value ProfileValue) error {
+	var b strings.Builder
+	for _, s := range p.Samples() {
+		var v int64
+		switch value {
+		case ProfileWall:
+			v = s.Wall.Microseconds()
+		case ProfileAllocBytes:
+			v = s.AllocBytes
+		case ProfileAllocObjects:
+			v = s.AllocObjects
+		case ProfileCalls:
+			v = s.Calls
+		}
+		fmt.Fprintf(&b, "%s %d\n", folded(s.Stack), v)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteProfile writes the samples as a gzipped pprof profile, for
+// `go tool pprof`. Its sample types are calls/count, wall/nanoseconds,
+// process_alloc_space/bytes and process_alloc_objects/count, named so as the
+// allocations are the whole process's (see Profiler); each site is a function
+// named by the site, at its template file and line.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	samples := p.Samples()
+	strs := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = int64(len(table))
+		table = append(table, s)
+		return strs[s]
+	}
+	valueType := func(typ, unit string) []byte {
+		var m []byte
+		m = protoInt(m, 1, str(typ))
+		return protoInt(m, 2, str(unit))
+	}
+
+	var prof []byte
+	for _, t := range [][2]string{{"calls", "count"}, {"wall", "nanoseconds"}, {"process_alloc_space", "bytes"}, {"process_alloc_objects", "count"}} {
+		prof = protoBytes(prof, 1, valueType(t[0], t[1]))
+	}
+	// One function and location per site, both with the site's id + 1.
+	sites := map[int]*ProfileSite{}
+	for _, s := range samples {
+		var locs, values []byte
+		for i := len(s.Stack) - 1; i >= 0; i-- {
+			sites[s.Stack[i].id] = s.Stack[i]
+			locs = binary.AppendUvarint(locs, uint64(s.Stack[i].id+1))
+		}
+		for _, v := range []int64{s.Calls, s.Wall.Nanoseconds(), s.AllocBytes, s.AllocObjects} {
+			values = binary.AppendUvarint(values, uint64(v))
+		}
+		var sample []byte
+		sample = protoBytes(sample, 1, locs)
+		sample = protoBytes(sample, 2, values)
+		prof = protoBytes(prof, 2, sample)
+	}
+	ids := make([]int, 0, len(sites))
+	for id := range sites {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		site := sites[id]
+		var line, loc, fn []byte
+		line = protoInt(line, 1, int64(id+1))
+		line = protoInt(line, 2, int64(site.Line))
+		loc = protoInt(loc, 1, int64(id+1))
+		loc = protoBytes(loc, 4, line)
+		prof = protoBytes(prof, 4, loc)
+		fn = protoInt(fn, 1, int64(id+1))
+		fn = protoInt(fn, 2, str(site.String()))
+		fn = protoInt(fn, 3, str(site.String()))
+		fn = protoInt(fn, 4, str(site.File))
+		fn = protoInt(fn, 5, int64(site.Line))
+		prof = protoBytes(prof, 5, fn)
+	}
+	// The string table must follow every str call.
+	period := valueType("wall", "nanoseconds")
+	for _, s := range table {
+		prof = protoBytes(prof, 6, []byte(s))
+	}
+	prof = protoInt(prof, 9, p.start.UnixNano())
+	prof = protoInt(prof, 10, int64(time.Since(p.start)))
+	prof = protoBytes(prof, 11, period)
+	prof = protoInt(prof, 12, 1)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(prof); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// protoInt appends the varint field num = v to a protobuf message.
+func protoInt(m []byte, num int, v int64) []byte {
+	m = binary.AppendUvarint(m, uint64(num)<<3)
+	return binary.AppendUvarint(m, uint64(v))
+}
+
+// protoBytes appends the length-delimited field num = v to a protobuf message.
+func protoBytes(m []byte, num int, v []byte) []byte {
+	m = binary.AppendUvarint(m, uint64(num)<<3|2)
+	m = binary.AppendUvarint(m, uint64(len(v)))
+	return append(m, v...)
+}
+
+// instrument wraps the component calls, loops and interpolated texts of the
+// parsed template stmts, read from file, with profEnter and profExit calls
+// and returns the new statements. A nil Profiler returns stmts.
+// Instrumenting a template again, e.g. when Render recompiles it, replaces its
+// sites.
+func (p *Profiler) instrument(file *source.File, stmts gnode.Stmts) gnode.Stmts {
+	if p == nil {
+		return stmts
+	}
+	var ids []int
+	stmts = giomnode.Rewrite(stmts, func(stmts gnode.Stmts) gnode.Stmts {
+		var out gnode.Stmts
+		for _, stmt := range stmts {
+			name := profileName(stmt)
+			if name == "" || !stmt.Pos().IsValid() {
+				out = append(out, stmt)
+				continue
+			}
+			pos := source.MustFilePosition(file, stmt.Pos())
+			site := &ProfileSite{Name: name, File: file.Name, Line: pos.Line, Column: pos.Column}
+			p.mu.Lock()
+			site.id = p.next
+			p.next++
+			if p.sites == nil {
+				p.sites = map[int]*ProfileSite{}
+			}
+			p.sites[site.id] = site
+			p.mu.Unlock()
+			ids = append(ids, site.id)
+			out = append(out,
+				profileStmt("profEnter", site.id, stmt.Pos()),
+				stmt,
+				profileStmt("profExit", site.id, stmt.End()))
+		}
+		return out
+	})
+
+	p.mu.Lock()
+	if p.files == nil {
+		p.files = map[string][]int{}
+	}
+	for _, id := range p.files[file.Name] {
+		delete(p.sites, id)
+	}
+	p.files[file.Name] = ids
+	p.mu.Unlock()
+	return stmts
+}
+
+// profileName returns the site name of stmt, or "" when it is not profiled.
+func profileName(stmt gnode.Stmt) string {
+	switch s := stmt.(type) {
+	case *giomnode.CompCallStmt:
+		return "+" + s.Name
+	case *giomnode.ForStmt:
+		return "@for"
+	case *giomnode.WhileStmt:
+		return "@while"
+	case *giomnode.TextStmt:
+		for _, st := range s.Stmts {
+			if _, ok := st.(*gnode.MixedValueStmt); ok {
+				return "{=}"
+			}
+		}
+	}
+	return ""
+}
+
+// profileStmt builds the `giom.<fn>(id)` call of site id.
+func profileStmt(fn string, id int, pos source.Pos) gnode.Stmt {
+	call := gnode.ECall(gnode.ESelector(gnode.EIdent("giom", pos), gnode.Str(fn, 0)), 0, 0)
+	call.Args.Values = []gnode.Expr{&gnode.IntLit{Value: int64(id), ValuePos: pos, Literal: strconv.Itoa(id)}}
+	return &giomnode.CodeStmt{NodePos: pos, NodeEnd: pos, Stmts: gnode.Stmts{gnode.SExpr(call)}}
+}
@@ -0,0 +1,106 @@
+package giom
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiler(t *testing.T) {
+	src := "@comp card(title)\n" +
+		"    div.card\n" +
+		"        h2 {= title}\n" +
+		"@main\n" +
+		"    ul\n" +
+		"        @for x in xs\n" +
+		"            li {= x}\n" +
+		"    +card(\"A\")\n"
+	dir := t.TempDir()
+	p := filepath.Join(dir, "t.giom")
+	require.NoError(t, os.WriteFile(p, []byte(src), 0644))
+
+	r := newTestRender(t, dir)
+	r.Profiler = NewProfiler()
+	out, err := renderString(r, p, gad.Dict{"xs": gad.Array{gad.Str("a"), gad.Str("b")}})
+	require.NoError(t, err)
+	require.Equal(t, `<ul><li>a</li><li>b</li></ul><div class="card"><h2>A</h2></div>`, out)
+
+	var folded bytes.Buffer
+	require.NoError(t, r.Profiler.WriteFolded(&folded, ProfileCalls))
+	require.Equal(t, p+":6 @for 1\n"+
+		p+":6 @for;"+p+":7 {=} 2\n"+
+		p+":8 +card 1\n"+
+		p+":8 +card;"+p+":3 {=} 1\n", folded.String())
+
+	for _, s := range r.Profiler.Samples() {
+		require.GreaterOrEqual(t, s.Wall, time.Duration(0), s.Stack)
+	}
+
+	var profile bytes.Buffer
+	require.NoError(t, r.Profiler.WriteProfile(&profile))
+	zr, err := gzip.NewReader(&profile)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	for _, s := range []string{"calls", "wall", "nanoseconds", "process_alloc_space", p + ":8 +card"} {
+		require.True(t, bytes.Contains(raw, []byte(s)), s)
+	}
+}
+
+func TestProfilerImports(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.giom"),
+		[]byte("@export comp list(xs)\n    @for x in xs\n        +item(x)\n@comp item(x)\n    i {= x}\n"), 0644))
+	p := filepath.Join(dir, "t.giom")
+	require.NoError(t, os.WriteFile(p, []byte("@import \"lib.giom\" as lib\n@main\n    +lib.list([1, 2])\n"), 0644))
+
+	r := newTestRender(t, dir)
+	r.Profiler = NewProfiler()
+	out, err := renderString(r, p, nil)
+	require.NoError(t, err)
+	require.Equal(t, "<i>1</i><i>2</i>", out)
+
+	var folded bytes.Buffer
+	require.NoError(t, r.Profiler.WriteFolded(&folded, ProfileCalls))
+	require.Equal(t, p+":3 +lib.list 1\n"+
+		p+":3 +lib.list;lib.giom:2 @for 1\n"+
+		p+":3 +lib.list;lib.giom:2 @for;lib.giom:3 +item 2\n"+
+		p+":3 +lib.list;lib.giom:2 @for;lib.giom:3 +item;lib.giom:5 {=} 2\n", folded.String())
+}
+
+func TestProfilerInstances(t *testing.T) {
+	src := []byte("@main\n    @for x in xs\n        i {= x}\n")
+	run := func(c *Compiler, xs ...gad.Object) {
+		t.Helper()
+		builtins := c.AppendBuiltins(gad.NewBuiltins())
+		_, bc, err := c.Compile(src)
+		require.NoError(t, err)
+		_, err = gad.NewVM(builtins.Build(), bc).RunOpts(&gad.RunOpts{Globals: gad.Dict{"xs": gad.Array(xs)}})
+		require.NoError(t, err)
+	}
+	calls := func(p *Profiler) (out []string) {
+		for _, s := range p.Samples() {
+			out = append(out, fmt.Sprintf("%s %d", s.Stack[len(s.Stack)-1].Name, s.Calls))
+		}
+		return
+	}
+	opts := gad.CompileOptions{CompilerOptions: gad.CompilerOptions{FallbackFunc: CompileFallback}}
+	a, b := NewProfiler(), NewProfiler()
+	ca := NewCompiler(nil, opts).WithProfiler(a)
+	run(ca, gad.Int(1))
+	run(NewCompiler(nil, opts).WithProfiler(b), gad.Int(1), gad.Int(2))
+	require.Equal(t, []string{"@for 1", "{=} 1"}, calls(a))
+	require.Equal(t, []string{"@for 1", "{=} 2"}, calls(b))
+
+	// Compiling the template again replaces its sites.
+	run(ca)
+	require.Len(t, a.sites, 2)
+}
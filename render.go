@@ -60,6 +60,19 @@ type Render struct {
 
 	ModuleMapFunc func(mm *gad.ModuleMap) *gad.ModuleMap
 
+	// Markup selects how the render tree is serialised. If nil, it is detected
+	// from the tree (see DetectMarkup): XML after an `!!! xml` prolog, HTML
+	// otherwise.
+	Markup *Markup
+
+	// Cover, when set before the first render, instruments the templates and
+	// the templates they import for coverage (see Cover).
+	Cover *Cover
+
+	// Profiler, when set before the first render, instruments the templates
+	// and the templates they import for profiling (see Profiler).
+	Profiler *Profiler
+
 	mu             sync.Mutex
 	compileMu      sync.Mutex
 	templateCache  map[string]*templateCacheEntry
@@ -185,15 +198,39 @@ func (r *Render) Render(out io.Writer, filePath string, globals gad.Dict) error
 		return fmt.Errorf("render %s: %w", filePath, err)
 	}
 	// The compiled template builds a render tree and returns its root element;
-	// walk it to write the HTML output.
+	// resolve its stacks once, then walk it to write the HTML output.
 	if el, ok := ret.(Element); ok {
-		if _, err = el.WriteTo(e.VM, out); err != nil {
+		ResolveStacks(el)
+		m := DetectMarkup(el)
+		if r.Markup != nil {
+			m = *r.Markup
+		}
+		if _, err = m.Write(e.VM, out, el); err != nil {
 			return fmt.Errorf("render %s: %w", filePath, err)
 		}
 	}
 	return nil
 }
 
+// Check compiles the template at filePath and the modules it imports without
+// running it, returning the first parse or compile error. globalNames are
+// declared as globals, as the keys of the globals passed to Render are. The
+// result is not cached.
+func (r *Render) Check(filePath string, globalNames ...string) error {
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filePath, err)
+	}
+	return r.CheckSource(filePath, src, globalNames...)
+}
+
+// CheckSource is like Check for the template src, e.g. the unsaved content of
+// an editor; filePath names it and locates its imports.
+func (r *Render) CheckSource(filePath string, src []byte, globalNames ...string) error {
+	_, err := r.compile(filePath, src, globalNames)
+	return err
+}
+
 func (r *Render) compile(filePath string, src []byte, globalNames []string) (*templateCacheEntry, error) {
 	r.compileMu.Lock()
 	defer r.compileMu.Unlock()
@@ -203,7 +240,7 @@ func (r *Render) compile(filePath string, src []byte, globalNames []string) (*te
 		if builtinsFn == nil {
 			builtinsFn = func() *gad.Builtins { return gad.NewBuiltins() }
 		}
-		r.cachedBuiltins = AppendBuiltins(builtinsFn())
+		r.cachedBuiltins = appendBuiltins(builtinsFn(), r.Cover, r.Profiler)
 	})
 
 	tr := newTrackingReader()
@@ -216,6 +253,8 @@ func (r *Render) compile(filePath string, src []byte, globalNames []string) (*te
 		WorkDir:       workDir,
 		FileReader:    tr.Read,
 		TranspilePath: r.TranspilePath,
+		Cover:         r.Cover,
+		Profiler:      r.Profiler,
 	})
 
 	if r.ModuleMapFunc != nil {
@@ -242,7 +281,7 @@ func (r *Render) compile(filePath string, src []byte, globalNames []string) (*te
 	if filepath.Ext(filePath) != ".giom" {
 		_, bc, err = gad.Compile(st, src, opts)
 	} else {
-		_, bc, err = Compile(st, src, opts)
+		_, bc, err = NewCompiler(st, opts).WithCover(r.Cover).WithProfiler(r.Profiler).Compile(src)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("compile %s: %+v", filePath, err)
@@ -622,3 +622,32 @@ func TestRenderOnRenderReturnsRender(t *testing.T) {
 		t.Fatal("OnRender should return the Render for chaining")
 	}
 }
+
+func TestRenderCheck(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.giom")
+	bad := filepath.Join(dir, "bad.giom")
+	if err := os.WriteFile(good, []byte("@main\n    h1 {= Model.Title}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("@main\n    p {= undefinedVar }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRender(t, dir)
+	if err := r.Check(good, "Model"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Check(good); err == nil {
+		t.Fatal("expected compile error for undeclared global")
+	}
+	if err := r.Check(bad); err == nil {
+		t.Fatal("expected compile error for undefined variable")
+	}
+	if err := r.CheckSource(bad, []byte("@main\n    p {= Model.Title }"), "Model"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.templateCache) != 0 {
+		t.Fatalf("expected Check not to cache, got %d entries", len(r.templateCache))
+	}
+}
@@ -0,0 +1,329 @@
+package giom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	"github.com/gad-lang/gad/parser/ast"
+	gnode "github.com/gad-lang/gad/parser/node"
+	"github.com/gad-lang/gad/parser/source"
+)
+
+// SourceMap maps positions of the Gad source a template transpiles to back to
+// the template. It is a version 3 source map, as used for JavaScript, so it is
+// written and read as JSON with the same fields; Transpile writes it to the
+// `.gad.map` file next to the `.gad` file.
+type SourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent,omitempty"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+
+	// lines holds the decoded Mappings, by 0-based Gad line.
+	lines [][]mapSegment
+}
+
+// mapSegment maps a 0-based Gad column on to a 0-based template position.
+type mapSegment struct {
+	col, source, line, srcCol int
+}
+
+// SourcePosition is a position in a template. Line and Column are 1-based.
+type SourcePosition struct {
+	File         string
+	Line, Column int
+}
+
+// String returns the position as "file:line:column".
+func (p SourcePosition) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// ReadSourceMap reads the source map file at path.
+func ReadSourceMap(path string) (*SourceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseSourceMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("source map %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseSourceMap parses a version 3 source map.
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	var m SourceMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Version != 3 {
+		return nil, fmt.Errorf("unsupported version %d", m.Version)
+	}
+	var err error
+	if m.lines, err = decodeMappings(m.Mappings); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Source returns the template position of the 1-based line and column of the
+// Gad source: the position of the template statement that line was generated
+// from. It reports false when the line maps to no template statement.
+func (m *SourceMap) Source(line, column int) (SourcePosition, bool) {
+	if line < 1 || line > len(m.lines) || len(m.lines[line-1]) == 0 {
+		return SourcePosition{}, false
+	}
+	segs := m.lines[line-1]
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].col > column-1 }) - 1
+	if i < 0 {
+		i = 0
+	}
+	seg := segs[i]
+	if seg.source < 0 || seg.source >= len(m.Sources) {
+		return SourcePosition{}, false
+	}
+	return SourcePosition{File: m.Sources[seg.source], Line: seg.line + 1, Column: seg.srcCol + 1}, true
+}
+
+// sourceMark stands for the start of a template statement, or the end of the
+// last one started, in the Gad source: it is written as a marker that
+// mapSource removes once the source is complete.
+type sourceMark struct {
+	ast.NodeData
+	NodePos source.Pos
+	id      int // index of the statement position, or -1 for an end
+}
+
+func (m *sourceMark) Pos() source.Pos { return m.NodePos }
+func (m *sourceMark) End() source.Pos { return m.NodePos }
+func (m *sourceMark) StmtNode()       {}
+func (m *sourceMark) String() string  { return "" }
+
+func (m *sourceMark) WriteCode(ctx *gnode.CodeWriteContext) {
+	if m.id < 0 {
+		ctx.WriteString("\x00/\x00")
+	} else {
+		ctx.WriteString("\x00" + strconv.Itoa(m.id) + "\x00")
+	}
+}
+
+// markSource surrounds each statement of stmts, read from file, and of the
+// bodies nested in them with sourceMarks, and returns the new statements and
+// the 0-based template positions of the marks.
+func markSource(file *source.File, stmts gnode.Stmts) (gnode.Stmts, [][2]int) {
+	var positions [][2]int
+	stmts = giomnode.Rewrite(stmts, func(stmts gnode.Stmts) gnode.Stmts {
+		if len(stmts) == 0 {
+			return stmts
+		}
+		var (
+			out  gnode.Stmts
+			prev gnode.Stmt
+		)
+		for _, stmt := range stmts {
+			// Consecutive declarations merge into one grouped declaration
+			// (see giomnode.Convert), which marks would split.
+			if !stmt.Pos().IsValid() || isDeclStmt(stmt) && prev != nil && isDeclStmt(prev) {
+				out = append(out, stmt)
+				prev = stmt
+				continue
+			}
+			pos := source.MustFilePosition(file, stmt.Pos())
+			positions = append(positions, [2]int{pos.Line - 1, pos.Column - 1})
+			out = append(out,
+				&sourceMark{NodePos: stmt.Pos(), id: len(positions) - 1},
+				stmt,
+				&sourceMark{NodePos: stmt.Pos(), id: -1})
+			prev = stmt
+		}
+		return out
+	})
+	return stmts, positions
+}
+
+func isDeclStmt(stmt gnode.Stmt) bool {
+	switch stmt.(type) {
+	case *giomnode.VarStmt, *giomnode.ConstStmt, *giomnode.GlobalStmt:
+		return true
+	}
+	return false
+}
+
+// mapSource removes the sourceMarks from the Gad source src
+// and returns the source and the segments of its lines: each line maps, from
+// its first non-blank column, to the innermost statement it was generated
+// within, and further where another statement starts on the line. Lines left
+// blank by the removal are dropped.
+func mapSource(src []byte, positions [][2]int) ([]byte, [][]mapSegment) {
+	var (
+		out   bytes.Buffer
+		lines [][]mapSegment
+		stack []int
+	)
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		if line == "" {
+			continue
+		}
+		var (
+			text   strings.Builder
+			segs   []mapSegment
+			marked bool
+			last   = -1
+		)
+		for rest := line; rest != ""; {
+			i := strings.IndexByte(rest, 0)
+			if i < 0 {
+				i = len(rest)
+			}
+			for _, r := range rest[:i] {
+				if r != ' ' && r != '\t' && r != '\n' && len(stack) > 0 && stack[len(stack)-1] != last {
+					last = stack[len(stack)-1]
+					p := positions[last]
+					segs = append(segs, mapSegment{col: text.Len(), line: p[0], srcCol: p[1]})
+				}
+				text.WriteRune(r)
+			}
+			if i == len(rest) {
+				break
+			}
+			marked = true
+			j := strings.IndexByte(rest[i+1:], 0)
+			if j < 0 {
+				break
+			}
+			mark := rest[i+1 : i+1+j]
+			rest = rest[i+j+2:]
+			if mark == "/" {
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			} else if id, err := strconv.Atoi(mark); err == nil && id < len(positions) {
+				stack = append(stack, id)
+			}
+		}
+		if marked && strings.Trim(text.String(), " \t;\n") == "" {
+			continue
+		}
+		out.WriteString(text.String())
+		lines = append(lines, segs)
+	}
+	return out.Bytes(), lines
+}
+
+// encodeMappings returns the "mappings" field of a source map for lines.
+func encodeMappings(lines [][]mapSegment) string {
+	var (
+		b                          strings.Builder
+		source, srcLine, srcColumn int
+	)
+	for i, segs := range lines {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		col := 0
+		for j, s := range segs {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			for _, v := range []int{s.col - col, s.source - source, s.line - srcLine, s.srcCol - srcColumn} {
+				writeVLQ(&b, v)
+			}
+			col, source, srcLine, srcColumn = s.col, s.source, s.line, s.srcCol
+		}
+	}
+	return b.String()
+}
+
+// decodeMappings decodes the "mappings" field of a source map. Segments
+// without a source position are skipped.
+func decodeMappings(mappings string) ([][]mapSegment, error) {
+	var (
+		lines                      [][]mapSegment
+		source, srcLine, srcColumn int
+	)
+	for _, line := range strings.Split(mappings, ";") {
+		var segs []mapSegment
+		col := 0
+		for _, field := range strings.Split(line, ",") {
+			if field == "" {
+				continue
+			}
+			values, err := readVLQs(field)
+			if err != nil {
+				return nil, err
+			}
+			col += values[0]
+			if len(values) < 4 {
+				continue
+			}
+			source += values[1]
+			srcLine += values[2]
+			srcColumn += values[3]
+			segs = append(segs, mapSegment{col: col, source: source, line: srcLine, srcCol: srcColumn})
+		}
+		lines = append(lines, segs)
+	}
+	return lines, nil
+}
+
+const vlqDigits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// writeVLQ writes v as a base64 VLQ: 5 bits per digit, least significant
+// first, with the sign in the lowest bit of the first.
+func writeVLQ(b *strings.Builder, v int) {
+	u := v << 1
+	if v < 0 {
+		u = -v<<1 | 1
+	}
+	for {
+		digit := u & 31
+		u >>= 5
+		if u > 0 {
+			digit |= 32
+		}
+		b.WriteByte(vlqDigits[digit])
+		if u == 0 {
+			return
+		}
+	}
+}
+
+// readVLQs decodes the base64 VLQs of a segment.
+func readVLQs(s string) ([]int, error) {
+	var (
+		values      []int
+		value, bits int
+	)
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(vlqDigits, s[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid mapping character %q", s[i])
+		}
+		value |= digit & 31 << bits
+		bits += 5
+		if digit&32 != 0 {
+			continue
+		}
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, bits = 0, 0
+	}
+	if bits != 0 {
+		return nil, errors.New("truncated mapping")
+	}
+	return values, nil
+}
@@ -0,0 +1,96 @@
+package giom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceMap(t *testing.T) {
+	src := "@main\n" +
+		"    ul\n" +
+		"        @for x in xs\n" +
+		"            li {= x}\n" +
+		"    p done\n"
+	out, m, err := TranspileMap("page.giom", []byte(src))
+	require.NoError(t, err)
+
+	var plain bytes.Buffer
+	require.NoError(t, TranspileTo(&plain, "page.giom", []byte(src)))
+	require.Equal(t, plain.String(), string(out))
+	require.NotContains(t, string(out), "\x00")
+
+	// source returns the template position of the first Gad line holding s.
+	source := func(s string) string {
+		t.Helper()
+		for i, line := range strings.Split(string(out), "\n") {
+			if col := strings.Index(line, s); col >= 0 {
+				pos, ok := m.Source(i+1, col+1)
+				require.True(t, ok, line)
+				return pos.String()
+			}
+		}
+		t.Fatalf("%q not found in:\n%s", s, out)
+		return ""
+	}
+	require.Equal(t, "page.giom:2:5", source(`"ul"`))
+	require.Equal(t, "page.giom:3:9", source("xs"))
+	require.Equal(t, "page.giom:4:13", source(`"li"`))
+	require.Equal(t, "page.giom:5:5", source(`"done"`))
+
+	_, ok := m.Source(0, 1)
+	require.False(t, ok)
+}
+
+func TestTranspileSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	src := []byte("@main\n    p\n        b {= 1 + 2}\n")
+	srcPath := filepath.Join(dir, "page.giom")
+	require.NoError(t, os.WriteFile(srcPath, src, 0644))
+	outPath := filepath.Join(dir, ".transpiled", "page.gad")
+	require.NoError(t, Transpile(srcPath, src, outPath))
+
+	gadSrc, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	m, err := ReadSourceMap(outPath + ".map")
+	require.NoError(t, err)
+	require.Equal(t, "page.gad", m.File)
+	require.Equal(t, []string{"../page.giom"}, m.Sources)
+	require.Equal(t, []string{string(src)}, m.SourcesContent)
+
+	lines := strings.Split(string(gadSrc), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, `"b"`) {
+			pos, ok := m.Source(i+1, 1)
+			require.True(t, ok)
+			require.Equal(t, SourcePosition{File: "../page.giom", Line: 3, Column: 9}, pos)
+			return
+		}
+	}
+	t.Fatalf("no b tag in:\n%s", gadSrc)
+}
+
+func TestParseSourceMap(t *testing.T) {
+	m, err := ParseSourceMap([]byte(`{"version":3,"sources":["a.giom"],"names":[],"mappings":";AACI;CAAA,EAAE;;AAAF"}`))
+	require.NoError(t, err)
+	pos, ok := m.Source(3, 4)
+	require.True(t, ok)
+	require.Equal(t, "a.giom:2:7", pos.String())
+	pos, ok = m.Source(3, 2)
+	require.True(t, ok)
+	require.Equal(t, "a.giom:2:5", pos.String())
+	_, ok = m.Source(4, 1)
+	require.False(t, ok)
+	pos, ok = m.Source(5, 1)
+	require.True(t, ok)
+	require.Equal(t, "a.giom:2:5", pos.String())
+
+	_, err = ParseSourceMap([]byte(`{"version":2}`))
+	require.Error(t, err)
+	_, err = ParseSourceMap([]byte(`{"version":3,"mappings":"A*"}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package giom
+
+import (
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineTagSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "link in prose",
+			src:  "p Read the #[a[href=url] full docs] before starting\n",
+			want: `<p>Read the <a href="/docs">full docs</a> before starting</p>`,
+		},
+		{
+			name: "interpolation and nesting",
+			src:  "p #[em {= n}] items and #[b: i x]\n",
+			want: `<p><em>3</em> items and <b><i>x</i></b></p>`,
+		},
+		{
+			name: "span inside a span",
+			src:  "| see #[span.note the #[code go] tool]\n",
+			want: `see <span class="note">the <code>go</code> tool</span>`,
+		},
+		{
+			name: "interpolated brackets are text",
+			src:  "p {= \"#[x]\"} ok\n",
+			want: `<p>#[x] ok</p>`,
+		},
+		{
+			name: "escaped span",
+			src:  "| Issue \\#[123], see #[b \\#[x]]\n",
+			want: `Issue #[123], see <b>#[x]</b>`,
+		},
+		{
+			name: "prose brackets are text",
+			src:  "| Issue #[123], #[-x] and #[ ] stay\n",
+			want: `Issue #[123], #[-x] and #[ ] stay`,
+		},
+	}
+	globals := gad.Dict{"url": gad.Str("/docs"), "n": gad.Int(3)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := portRun(t, tt.src, globals, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInlineTagSpanNotATag(t *testing.T) {
+	// A span must start with a tag name; anything else stays text.
+	got, err := portRun(t, "p see #[| text]\n", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "<p>see #[| text]</p>", got)
+}
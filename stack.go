@@ -0,0 +1,123 @@
+package giom
+
+import (
+	"fmt"
+
+	"github.com/gad-lang/gad"
+)
+
+// Stacks collect content pushed from anywhere in a page into a place of the
+// layout rendered earlier, typically `<head>` or the end of `<body>`:
+//
+//	@push "scripts"        // giom.push(tag, "scripts"): a fragment holding the body
+//	@stack "scripts"       // giom.stack(tag, "scripts"): an empty placeholder
+//	@once                  // giom.once(tag, key): a fragment kept only the first time
+//
+// The three are anonymous fragments marked with their role. The template builds
+// them in place, and ResolveStacks moves the content once the tree is complete.
+
+// ResolveStacks resolves the stacks of the tree rooted at root, in document
+// order: repeated @once fragments (by key) are removed, every @push fragment is
+// moved out of its place and the pushed fragments are appended, in order, to
+// the first @stack of their name. Content pushed to a stack the tree does not
+// have is dropped. Render calls it once the template has built the tree; the
+// writers, such as Markup.Write and RenderText, take the tree as it is and
+// never change it.
+func ResolveStacks(root Element) {
+	t, ok := root.(*Tag)
+	if !ok {
+		return
+	}
+	r := stackResolver{pushes: map[string][]*Tag{}, once: map[string]bool{}}
+	r.walk(t)
+	for _, s := range r.stacks {
+		for _, p := range r.pushes[s.stack] {
+			p.push = ""
+			s.Children = append(s.Children, s.adopt(p))
+		}
+		delete(r.pushes, s.stack)
+	}
+}
+
+type stackResolver struct {
+	pushes map[string][]*Tag
+	once   map[string]bool
+	stacks []*Tag
+}
+
+func (r *stackResolver) walk(t *Tag) {
+	for _, c := range append([]Element(nil), t.Children...) {
+		ct, ok := c.(*Tag)
+		if !ok {
+			continue
+		}
+		switch {
+		case ct.once != "":
+			if r.once[ct.once] {
+				t.Remove(ct)
+				continue
+			}
+			r.once[ct.once] = true
+		case ct.push != "":
+			t.Remove(ct)
+			r.pushes[ct.push] = append(r.pushes[ct.push], ct)
+		case ct.stack != "":
+			if !r.hasStack(ct.stack) {
+				r.stacks = append(r.stacks, ct)
+			}
+		}
+		r.walk(ct)
+	}
+}
+
+func (r *stackResolver) hasStack(name string) bool {
+	for _, s := range r.stacks {
+		if s.stack == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stackMarker returns the Value of a giom.stack/push/once builtin: called as
+// fn(parent, name), it appends an anonymous fragment marked by set to parent.
+func stackMarker(fn string, set func(t *Tag, name string)) func(gad.Call) (gad.Object, error) {
+	return func(c gad.Call) (gad.Object, error) {
+		if err := c.Args.CheckLen(2); err != nil {
+			return nil, err
+		}
+		parent, _ := parentArg(c)
+		name := c.Args.GetOnly(1).ToString()
+		if name == "" {
+			return nil, fmt.Errorf("%s: empty name", fn)
+		}
+		t := NewTag(parent, "", nil, nil)
+		set(t, name)
+		return t, nil
+	}
+}
+
+var (
+	// BuiltinStack implements giom.stack(parent, name), the @stack placeholder.
+	BuiltinStack = &gad.Function{
+		FuncName: "giom.stack",
+		Module:   ModuleSpec,
+		Value:    stackMarker("giom.stack", func(t *Tag, name string) { t.stack = name }),
+	}
+
+	// BuiltinPush implements giom.push(parent, name), the fragment of a @push
+	// block.
+	BuiltinPush = &gad.Function{
+		FuncName: "giom.push",
+		Module:   ModuleSpec,
+		Value:    stackMarker("giom.push", func(t *Tag, name string) { t.push = name }),
+	}
+
+	// BuiltinOnce implements giom.once(parent, key), the fragment of a @once
+	// block.
+	BuiltinOnce = &gad.Function{
+		FuncName: "giom.once",
+		Module:   ModuleSpec,
+		Value:    stackMarker("giom.once", func(t *Tag, name string) { t.once = name }),
+	}
+)
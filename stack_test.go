@@ -0,0 +1,114 @@
+package giom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gad-lang/gad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStacks(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "layout head collects pushes from the body",
+			src: "@comp layout()\n" +
+				"    html\n" +
+				"        head\n" +
+				"            title T\n" +
+				"            @stack \"scripts\"\n" +
+				"        body\n" +
+				"            @slot main\n" +
+				"@comp chart()\n" +
+				"    @push \"scripts\"\n" +
+				"        @once\n" +
+				"            script[src=\"chart.js\"]\n" +
+				"    canvas\n" +
+				"@main\n" +
+				"    +layout\n" +
+				"        +chart\n" +
+				"        @push \"scripts\"\n" +
+				"            script page()\n" +
+				"        +chart\n",
+			want: "<html><head><title>T</title><script src=\"chart.js\"></script><script>page()</script></head>" +
+				"<body><canvas></canvas><canvas></canvas></body></html>",
+		},
+		{
+			name: "keyed once and unused push",
+			src: "@main\n" +
+				"    div\n" +
+				"        @stack \"css\"\n" +
+				"    @push \"css\"\n" +
+				"        @once \"base\"\n" +
+				"            link[href=\"a.css\"]\n" +
+				"    @push \"css\"\n" +
+				"        @once \"base\"\n" +
+				"            link[href=\"b.css\"]\n" +
+				"    @push \"nowhere\"\n" +
+				"        p lost\n" +
+				"    p end\n",
+			want: "<div><link href=\"a.css\" /></div><p>end</p>",
+		},
+		{
+			name: "empty stack",
+			src:  "@main\n    head\n        @stack \"scripts\"\n",
+			want: "<head></head>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := portRun(t, tt.src, nil, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveStacks(t *testing.T) {
+	root := NewTag(nil, "", nil, nil)
+	head := NewTag(root, "head", nil, nil)
+	stack := NewTag(head, "", nil, nil)
+	stack.stack = "s"
+	body := NewTag(root, "body", nil, nil)
+	for _, name := range []string{"a", "b"} {
+		p := NewTag(body, "", nil, nil)
+		p.push = "s"
+		NewTag(p, name, nil, nil)
+	}
+
+	ResolveStacks(root)
+	require.Empty(t, body.Children)
+	require.Len(t, stack.Children, 2)
+	require.Equal(t, "a", stack.Children[0].(*Tag).Children[0].(*Tag).Name)
+	require.Equal(t, "b", stack.Children[1].(*Tag).Children[0].(*Tag).Name)
+	require.Same(t, stack, stack.Children[0].(*Tag).parent)
+}
+
+func TestWritersKeepStacks(t *testing.T) {
+	// <stack s> a <push s>b</push>: resolved, b moves before a; the writers
+	// leave the tree unresolved.
+	build := func() *Tag {
+		root := NewTag(nil, "p", nil, nil)
+		NewTag(root, "", nil, nil).stack = "s"
+		root.Children = append(root.Children, Text{gad.Str("a")})
+		NewTag(root, "", []Element{Text{gad.Str("b")}}, nil).push = "s"
+		return root
+	}
+
+	root := build()
+	require.Equal(t, "<p>ab</p>", writeMarkup(t, Markup{}, root))
+	var buf bytes.Buffer
+	_, err := RenderText(newElementVM(), root, &buf)
+	require.NoError(t, err)
+	require.Equal(t, "ab", strings.TrimSpace(buf.String()))
+
+	ResolveStacks(root)
+	require.Equal(t, "<p>ba</p>", writeMarkup(t, Markup{}, root))
+	ResolveStacks(root)
+	require.Equal(t, "<p>ba</p>", writeMarkup(t, Markup{}, root))
+}
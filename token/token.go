@@ -37,6 +37,14 @@ const (
 	Const
 	Enum
 	Html
+	While
+	Branch
+	InlineNest
+	TagSpace
+	Whitespace
+	Verbatim
+	Embed
+	Stack
 	tokMax
 )
 
@@ -73,6 +81,14 @@ var tokNames = [...]string{
 	Const:        "CONST",
 	Enum:         "ENUM",
 	Html:         "HTML",
+	While:        "WHILE",
+	Branch:       "BRANCH",
+	InlineNest:   "INLINE_NEST",
+	TagSpace:     "TAG_SPACE",
+	Whitespace:   "WHITESPACE",
+	Verbatim:     "VERBATIM",
+	Embed:        "EMBED",
+	Stack:        "STACK",
 }
 
 // String returns a human-readable name for a giom token.
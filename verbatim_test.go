@@ -0,0 +1,82 @@
+package giom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gad-lang/gad/parser/source"
+	"github.com/stretchr/testify/require"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+func TestVerbatim(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "escaped giom sample",
+			src: "@main\n" +
+				"    pre\n" +
+				"        @verbatim\n" +
+				"            ul.menu\n" +
+				"                li {name} & <b>\n" +
+				"\n" +
+				"            @if x\n" +
+				"\n" +
+				"    p after\n",
+			want: "<pre>ul.menu\n    li {name} &amp; &lt;b&gt;\n\n@if x</pre><p>after</p>",
+		},
+		{
+			name: "raw",
+			src: "@main\n" +
+				"    div\n" +
+				"        @verbatim raw\n" +
+				"            <b>{x}</b>\n",
+			want: "<div><b>{x}</b></div>",
+		},
+		{
+			name: "trailing backslash",
+			src: "@main\n" +
+				"    pre\n" +
+				"        @verbatim\n" +
+				"            a \\\n" +
+				"            b\n" +
+				"    p x \\\n" +
+				"      y\n",
+			want: "<pre>a \\\nb</pre><p>x y</p>",
+		},
+		{
+			name: "empty",
+			src:  "@main\n    div\n        @verbatim\n    p\n",
+			want: "<div></div><p></p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := portRun(t, tt.src, nil, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerbatimGiomRoundTrip(t *testing.T) {
+	src := "pre\n" +
+		"    @verbatim raw\n" +
+		"        a {b}\n" +
+		"\n" +
+		"          c\n"
+	f := source.NewFileSet().AddFileData("verbatim.giom", -1, []byte(src))
+	file, err := giomparser.NewParser(f).ParseFile()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	ctx := giomnode.NewGiomCodeContext(&buf)
+	ctx.Prefix = "    "
+	file.WriteGiom(ctx)
+	require.Equal(t, src, buf.String())
+}
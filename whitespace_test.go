@@ -0,0 +1,77 @@
+package giom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gad-lang/gad/parser/source"
+	"github.com/stretchr/testify/require"
+
+	giomnode "github.com/gad-lang/gad/giom/node"
+	giomparser "github.com/gad-lang/gad/giom/parser"
+)
+
+func TestWhitespaceModifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "space after and leading space text",
+			src: "nav\n" +
+				"    a[href=\"/\"]> Home\n" +
+				"    a[href=\"/about\"] About\n" +
+				"    |+ end\n",
+			want: `<nav><a href="/">Home</a> <a href="/about">About</a> end</nav>`,
+		},
+		{
+			name: "both sides",
+			src:  "p\n    | x\n    b<> y\n    | z\n",
+			want: `<p>x <b>y</b> z</p>`,
+		},
+		{
+			name: "inline child",
+			src:  "p\n    span>: b x\n    | y\n",
+			want: `<p><span><b>x</b></span> y</p>`,
+		},
+		{
+			name: "preserve with trims",
+			src: "@whitespace preserve\n" +
+				"p\n" +
+				"    a Home\n" +
+				"    a About\n" +
+				"    b> x\n" +
+				"    | y\n" +
+				"    i< z\n",
+			want: `<p><a>Home</a> <a>About</a> <b>x</b>y<i>z</i></p>`,
+		},
+		{
+			name: "collapse pragma",
+			src:  "@whitespace collapse\np\n    a Home\n    a About\n",
+			want: `<p><a>Home</a><a>About</a></p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := portRun(t, tt.src, nil, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWhitespacePragmaPlacement(t *testing.T) {
+	_, err := portRun(t, "p x\n@whitespace preserve\n", nil, nil)
+	require.ErrorContains(t, err, "@whitespace must come first")
+}
+
+func TestWhitespaceGiomRoundTrip(t *testing.T) {
+	src := "@whitespace preserve\np<>\n    |+ y\n"
+	file, err := giomparser.NewParser(source.NewFileSet().AddFileData("ws.giom", -1, []byte(src))).ParseFile()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	file.WriteGiom(giomnode.NewGiomCodeContext(&buf))
+	require.Equal(t, "@whitespace preserve\np<>\n\t|+ y\n", buf.String())
+}